@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -13,6 +15,8 @@ import (
 	"log/slog"
 
 	"github.com/svoboond/spinus/internal/conf"
+	"github.com/svoboond/spinus/internal/modbus"
+	"github.com/svoboond/spinus/internal/mqtt"
 	"github.com/svoboond/spinus/internal/server"
 )
 
@@ -50,6 +54,42 @@ func setupLogger(level, handler string) {
 	slog.SetDefault(slog.New(slogHandler))
 }
 
+func newMQTTSubscriber(config *conf.Conf, appServer *server.Server) (*mqtt.Subscriber, error) {
+	mqttConfig := config.MQTT
+	mappings := make([]mqtt.Mapping, 0, len(mqttConfig.Mappings))
+	for _, mapping := range mqttConfig.Mappings {
+		mappings = append(mappings, mqtt.Mapping(mapping))
+	}
+	return mqtt.NewSubscriber(
+		mqtt.Options{
+			Broker:               mqttConfig.Broker,
+			ClientID:             mqttConfig.ClientID,
+			Username:             mqttConfig.Username,
+			Password:             mqttConfig.Password,
+			QoS:                  mqttConfig.QoS,
+			MaxReconnectInterval: mqttConfig.MaxReconnectInterval,
+			Mappings:             mappings,
+		},
+		appServer.StoreDailyReading,
+	)
+}
+
+func newModbusPoller(config *conf.Conf, appServer *server.Server) (*modbus.Poller, error) {
+	modbusConfig := config.Modbus
+	meters := make([]modbus.Meter, 0, len(modbusConfig.Meters))
+	for _, meter := range modbusConfig.Meters {
+		meters = append(meters, modbus.Meter(meter))
+	}
+	return modbus.NewPoller(
+		modbus.Options{
+			Interval: modbusConfig.Interval,
+			Timeout:  modbusConfig.Timeout,
+			Meters:   meters,
+		},
+		appServer.StoreDailyReading,
+	)
+}
+
 func run() error {
 	slog.Debug("configuring...")
 	localConfPath := flag.String("config", "", "configuration file path")
@@ -69,6 +109,41 @@ func run() error {
 		return fmt.Errorf("could not create server: %w", err)
 	}
 
+	if config.MQTT.Enabled {
+		slog.Debug("setting up mqtt subscriber...")
+		subscriber, err := newMQTTSubscriber(config, appServer)
+		if err != nil {
+			return fmt.Errorf("could not create mqtt subscriber: %w", err)
+		}
+		subscriber.Start()
+		defer subscriber.Stop()
+	}
+
+	if config.Modbus.Enabled {
+		slog.Debug("setting up modbus poller...")
+		poller, err := newModbusPoller(config, appServer)
+		if err != nil {
+			return fmt.Errorf("could not create modbus poller: %w", err)
+		}
+		poller.Start()
+		defer poller.Stop()
+	}
+
+	if metricsPort := config.Service.MetricsPort; metricsPort != 0 {
+		metricsServer := &http.Server{
+			Addr:              fmt.Sprintf(":%d", metricsPort),
+			Handler:           expvar.Handler(),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			slog.Info("metrics startup", "port", metricsPort)
+			if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				slog.Error("metrics server error", "err", err)
+			}
+		}()
+		defer metricsServer.Close()
+	}
+
 	// graceful shutdown
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
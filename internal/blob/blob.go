@@ -0,0 +1,20 @@
+// Package blob stores binary objects like uploaded photos outside of the
+// database.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Storage stores objects under slash separated keys.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get returns ErrNotFound if there is no object with the key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete does not fail if there is no object with the key.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,349 @@
+// Package chart renders simple SVG charts on the server, so that pages need
+// no JavaScript. All texts are escaped, so the output can be embedded in HTML.
+package chart
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	width        = 640
+	height       = 240
+	marginLeft   = 72
+	marginRight  = 16
+	marginTop    = 32
+	marginBottom = 40
+	legendHeight = 20
+
+	yTickCount = 4
+	xTickCount = 4
+
+	dateFormat = "2006-01-02"
+)
+
+var palette = []string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f",
+	"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac",
+}
+
+func color(i int) string { return palette[i%len(palette)] }
+
+// Point is a value at a time, e.g. a meter reading.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Span is a value over a time interval, e.g. average consumption between two
+// readings.
+type Span struct {
+	Begin time.Time
+	End   time.Time
+	Value float64
+}
+
+// Stack is a bar made of values of all series.
+type Stack struct {
+	Label  string
+	Values []float64
+}
+
+// Line returns chart of points connected with a line over time.
+func Line(title, unit string, points []Point) string {
+	if len(points) == 0 {
+		return ""
+	}
+	minValue, maxValue := points[0].Value, points[0].Value
+	times := make([]time.Time, 0, len(points))
+	for _, p := range points {
+		minValue, maxValue = min(minValue, p.Value), max(maxValue, p.Value)
+		times = append(times, p.Time)
+	}
+	c := newCanvas(title, 0)
+	ys := newValueScale(minValue, maxValue, false)
+	xs := newTimeScale(times...)
+	c.valueAxis(ys, unit)
+	c.timeAxis(xs)
+
+	coords := make([]string, 0, len(points))
+	for _, p := range points {
+		coords = append(coords, formatCoord(xs.x(p.Time), ys.y(p.Value)))
+	}
+	fmt.Fprintf(c, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`,
+		strings.Join(coords, " "), color(0))
+	for _, p := range points {
+		fmt.Fprintf(c, `<circle cx="%s" cy="%s" r="3" fill="%s"><title>%s</title></circle>`,
+			formatFloat(xs.x(p.Time)), formatFloat(ys.y(p.Value)), color(0),
+			escape(p.Time.Format(dateFormat)+": "+formatValue(p.Value)+" "+unit))
+	}
+	return c.end()
+}
+
+// Spans returns chart of values drawn as bars over their time intervals.
+func Spans(title, unit string, spans []Span) string {
+	if len(spans) == 0 {
+		return ""
+	}
+	var maxValue float64
+	times := make([]time.Time, 0, 2*len(spans))
+	for _, span := range spans {
+		maxValue = max(maxValue, span.Value)
+		times = append(times, span.Begin, span.End)
+	}
+	c := newCanvas(title, 0)
+	ys := newValueScale(0, maxValue, true)
+	xs := newTimeScale(times...)
+	c.valueAxis(ys, unit)
+	c.timeAxis(xs)
+
+	for _, span := range spans {
+		x0, x1 := xs.x(span.Begin), xs.x(span.End)
+		y := ys.y(max(0, span.Value))
+		fmt.Fprintf(c,
+			`<rect x="%s" y="%s" width="%s" height="%s" fill="%s" fill-opacity="0.8">`+
+				`<title>%s</title></rect>`,
+			formatFloat(x0), formatFloat(y),
+			formatFloat(max(1, x1-x0)), formatFloat(ys.y(0)-y), color(0),
+			escape(span.Begin.Format(dateFormat)+" – "+span.End.Format(dateFormat)+": "+
+				formatValue(span.Value)+" "+unit))
+	}
+	return c.end()
+}
+
+// StackedBars returns chart of bars stacked from values of named series.
+func StackedBars(title, unit string, seriesNames []string, stacks []Stack) string {
+	if len(stacks) == 0 {
+		return ""
+	}
+	var maxValue float64
+	for _, stack := range stacks {
+		var total float64
+		for _, v := range stack.Values {
+			total += max(0, v)
+		}
+		maxValue = max(maxValue, total)
+	}
+	c := newCanvas(title, legendHeight*len(legendRows(seriesNames)))
+	ys := newValueScale(0, maxValue, true)
+	c.valueAxis(ys, unit)
+
+	slot := float64(c.plotWidth()) / float64(len(stacks))
+	barWidth := slot * 0.6
+	for i, stack := range stacks {
+		x := marginLeft + slot*float64(i) + (slot-barWidth)/2
+		var total float64
+		for _, v := range stack.Values {
+			total += max(0, v)
+		}
+		var bottom float64
+		for j, v := range stack.Values {
+			if v <= 0 {
+				continue
+			}
+			var name string
+			if j < len(seriesNames) {
+				name = seriesNames[j]
+			}
+			yTop, yBottom := ys.y(bottom+v), ys.y(bottom)
+			fmt.Fprintf(c,
+				`<rect x="%s" y="%s" width="%s" height="%s" fill="%s">`+
+					`<title>%s</title></rect>`,
+				formatFloat(x), formatFloat(yTop),
+				formatFloat(barWidth), formatFloat(yBottom-yTop), color(j),
+				escape(fmt.Sprintf("%s, %s: %s %s (%.1f %%)",
+					stack.Label, name, formatValue(v), unit, 100*v/total)))
+			bottom += v
+		}
+		fmt.Fprintf(c, `<text x="%s" y="%d" text-anchor="middle">%s</text>`,
+			formatFloat(x+barWidth/2), height-marginBottom+16, escape(stack.Label))
+	}
+	c.legend(seriesNames)
+	return c.end()
+}
+
+type canvas struct {
+	strings.Builder
+	height int
+}
+
+// newCanvas starts chart with extra space below the plot, e.g. for a legend.
+func newCanvas(title string, extraHeight int) *canvas {
+	c := &canvas{height: height + extraHeight}
+	fmt.Fprintf(c,
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" `+
+			`font-family="sans-serif" font-size="11" role="img">`,
+		width, c.height, width, c.height)
+	fmt.Fprintf(c, `<title>%s</title>`, escape(title))
+	fmt.Fprintf(c, `<text x="%d" y="18" font-size="13" font-weight="bold">%s</text>`,
+		marginLeft, escape(title))
+	return c
+}
+
+func (c *canvas) plotWidth() int { return width - marginLeft - marginRight }
+
+func (c *canvas) end() string {
+	c.WriteString("</svg>")
+	return c.String()
+}
+
+func (c *canvas) valueAxis(s valueScale, unit string) {
+	for _, v := range s.ticks() {
+		y := formatFloat(s.y(v))
+		fmt.Fprintf(c, `<line x1="%d" y1="%s" x2="%d" y2="%s" stroke="#ddd"/>`,
+			marginLeft, y, width-marginRight, y)
+		fmt.Fprintf(c, `<text x="%d" y="%s" text-anchor="end" dominant-baseline="middle">%s</text>`,
+			marginLeft-6, y, escape(formatValue(v)))
+	}
+	fmt.Fprintf(c, `<text x="12" y="%d" text-anchor="middle" transform="rotate(-90 12 %d)">%s</text>`,
+		marginTop+(height-marginTop-marginBottom)/2,
+		marginTop+(height-marginTop-marginBottom)/2,
+		escape(unit))
+}
+
+func (c *canvas) timeAxis(s timeScale) {
+	for _, t := range s.ticks() {
+		fmt.Fprintf(c, `<text x="%s" y="%d" text-anchor="middle">%s</text>`,
+			formatFloat(s.x(t)), height-marginBottom+16, escape(t.Format(dateFormat)))
+	}
+}
+
+// legendItemWidth estimates width of legend item from its name.
+func legendItemWidth(name string) int { return 24 + 7*len([]rune(name)) }
+
+// legendRows splits indexes of names into rows fitting the chart width.
+func legendRows(names []string) [][]int {
+	var rows [][]int
+	var row []int
+	x := marginLeft
+	for i, name := range names {
+		itemWidth := legendItemWidth(name)
+		if len(row) > 0 && x+itemWidth > width-marginRight {
+			rows = append(rows, row)
+			row, x = nil, marginLeft
+		}
+		row = append(row, i)
+		x += itemWidth
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (c *canvas) legend(names []string) {
+	for r, row := range legendRows(names) {
+		x := marginLeft
+		y := height + r*legendHeight
+		for _, i := range row {
+			fmt.Fprintf(c, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`,
+				x, y-9, color(i))
+			fmt.Fprintf(c, `<text x="%d" y="%d">%s</text>`, x+14, y, escape(names[i]))
+			x += legendItemWidth(names[i])
+		}
+	}
+}
+
+type valueScale struct {
+	min, max, step float64
+}
+
+// newValueScale returns scale with round ticks covering values. Scale starts
+// at zero if fromZero is set, e.g. for bars.
+func newValueScale(minValue, maxValue float64, fromZero bool) valueScale {
+	if fromZero {
+		minValue = min(0, minValue)
+	}
+	if maxValue <= minValue {
+		maxValue = minValue + 1
+	}
+	step := niceStep((maxValue - minValue) / yTickCount)
+	return valueScale{
+		min:  math.Floor(minValue/step) * step,
+		max:  math.Ceil(maxValue/step) * step,
+		step: step,
+	}
+}
+
+func (s valueScale) y(v float64) float64 {
+	plotHeight := float64(height - marginTop - marginBottom)
+	return float64(height-marginBottom) - (v-s.min)/(s.max-s.min)*plotHeight
+}
+
+func (s valueScale) ticks() []float64 {
+	var ticks []float64
+	count := int(math.Round((s.max - s.min) / s.step))
+	for i := 0; i <= count; i++ {
+		ticks = append(ticks, s.min+float64(i)*s.step)
+	}
+	return ticks
+}
+
+// niceStep rounds step up to 1, 2 or 5 times a power of ten.
+func niceStep(step float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(step)))
+	switch fraction := step / magnitude; {
+	case fraction <= 1:
+		return magnitude
+	case fraction <= 2:
+		return 2 * magnitude
+	case fraction <= 5:
+		return 5 * magnitude
+	default:
+		return 10 * magnitude
+	}
+}
+
+type timeScale struct {
+	min, max time.Time
+}
+
+func newTimeScale(times ...time.Time) timeScale {
+	s := timeScale{min: times[0], max: times[0]}
+	for _, t := range times {
+		if t.Before(s.min) {
+			s.min = t
+		}
+		if t.After(s.max) {
+			s.max = t
+		}
+	}
+	if !s.max.After(s.min) {
+		s.min, s.max = s.min.AddDate(0, 0, -1), s.max.AddDate(0, 0, 1)
+	}
+	return s
+}
+
+func (s timeScale) x(t time.Time) float64 {
+	plotWidth := float64(width - marginLeft - marginRight)
+	return marginLeft + float64(t.Sub(s.min))/float64(s.max.Sub(s.min))*plotWidth
+}
+
+func (s timeScale) ticks() []time.Time {
+	ticks := make([]time.Time, 0, xTickCount+1)
+	step := s.max.Sub(s.min) / xTickCount
+	for i := 0; i <= xTickCount; i++ {
+		ticks = append(ticks, s.min.Add(time.Duration(i)*step))
+	}
+	return ticks
+}
+
+func formatFloat(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+
+func formatCoord(x, y float64) string { return formatFloat(x) + "," + formatFloat(y) }
+
+// formatValue formats value with as many decimals as needed, up to three.
+func formatValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	// Writing to strings.Builder never fails.
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
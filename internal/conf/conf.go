@@ -3,6 +3,7 @@ package conf
 import (
 	"fmt"
 	"os"
+	"time"
 
 	_ "embed"
 
@@ -14,7 +15,12 @@ var baseData []byte
 
 type Conf struct {
 	Service struct {
-		Port uint16 `yaml:"port"`
+		Port           uint16   `yaml:"port"`
+		MetricsPort    uint16   `yaml:"metrics_port"`
+		BaseURL        string   `yaml:"base_url"`
+		SecretKey      string   `yaml:"secret_key"`
+		TimeZone       string   `yaml:"time_zone"`
+		TrustedProxies []string `yaml:"trusted_proxies"`
 	} `yaml:"service"`
 	Postgres struct {
 		Host     string `yaml:"host"`
@@ -30,6 +36,60 @@ type Conf struct {
 		Level   string `yaml:"level"`
 		Handler string `yaml:"handler"`
 	} `yaml:"log"`
+	Storage struct {
+		Type  string `yaml:"type"`
+		Local struct {
+			Path string `yaml:"path"`
+		} `yaml:"local"`
+		S3 struct {
+			Endpoint  string `yaml:"endpoint"`
+			Region    string `yaml:"region"`
+			Bucket    string `yaml:"bucket"`
+			AccessKey string `yaml:"access_key"`
+			SecretKey string `yaml:"secret_key"`
+		} `yaml:"s3"`
+	} `yaml:"storage"`
+	Mail struct {
+		Type string `yaml:"type"`
+		From string `yaml:"from"`
+		SMTP struct {
+			Host     string `yaml:"host"`
+			Port     uint16 `yaml:"port"`
+			Username string `yaml:"username"`
+			Password string `yaml:"password"`
+		} `yaml:"smtp"`
+	} `yaml:"mail"`
+	MQTT struct {
+		Enabled              bool          `yaml:"enabled"`
+		Broker               string        `yaml:"broker"`
+		ClientID             string        `yaml:"client_id"`
+		Username             string        `yaml:"username"`
+		Password             string        `yaml:"password"`
+		QoS                  byte          `yaml:"qos"`
+		MaxReconnectInterval time.Duration `yaml:"max_reconnect_interval"`
+		Mappings             []struct {
+			Topic       string `yaml:"topic"`
+			JSONPath    string `yaml:"json_path"`
+			MainMeterID int32  `yaml:"main_meter_id"`
+			MeterID     string `yaml:"meter_id"`
+		} `yaml:"mappings"`
+	} `yaml:"mqtt"`
+	Modbus struct {
+		Enabled  bool          `yaml:"enabled"`
+		Interval time.Duration `yaml:"interval"`
+		Timeout  time.Duration `yaml:"timeout"`
+		Meters   []struct {
+			MainMeterID  int32   `yaml:"main_meter_id"`
+			MeterID      string  `yaml:"meter_id"`
+			Address      string  `yaml:"address"`
+			UnitID       byte    `yaml:"unit_id"`
+			Register     uint16  `yaml:"register"`
+			RegisterType string  `yaml:"register_type"`
+			DataType     string  `yaml:"data_type"`
+			WordOrder    string  `yaml:"word_order"`
+			Scale        float64 `yaml:"scale"`
+		} `yaml:"meters"`
+	} `yaml:"modbus"`
 }
 
 func New(localPath string) (*Conf, error) {
@@ -0,0 +1,49 @@
+-- +goose Up
+ALTER TABLE sub_meter
+	ADD COLUMN floor_area DOUBLE PRECISION CHECK (floor_area >= 0),
+	ADD COLUMN occupants INT CHECK (occupants >= 0),
+	ADD COLUMN cost_share DOUBLE PRECISION CHECK (cost_share >= 0);
+
+ALTER TABLE main_meter_billing
+	ADD COLUMN cost_item_price DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE sub_meter_billing
+	ADD COLUMN cost_item_price DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE TYPE allocation_key AS ENUM (
+	'equal',
+	'floor_area',
+	'occupants',
+	'custom'
+);
+CREATE TABLE main_meter_billing_cost_item (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_main_billing INT NOT NULL REFERENCES main_meter_billing(id),
+	subid INT NOT NULL,
+	name VARCHAR(128) NOT NULL CHECK (LENGTH(TRIM(name)) >= 1),
+	allocation_key ALLOCATION_KEY NOT NULL,
+	price DOUBLE PRECISION NOT NULL CHECK (price >= 0),
+	PRIMARY KEY(id),
+	UNIQUE(fk_main_billing, subid)
+);
+CREATE TABLE sub_meter_billing_cost_item (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_sub_billing INT NOT NULL REFERENCES sub_meter_billing(id),
+	fk_main_billing_cost_item INT NOT NULL REFERENCES main_meter_billing_cost_item(id),
+	share DOUBLE PRECISION NOT NULL,
+	price DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY(id),
+	UNIQUE(fk_sub_billing, fk_main_billing_cost_item)
+);
+
+-- +goose Down
+DROP TABLE sub_meter_billing_cost_item;
+DROP TABLE main_meter_billing_cost_item;
+DROP TYPE IF EXISTS allocation_key;
+
+ALTER TABLE sub_meter_billing DROP COLUMN cost_item_price;
+ALTER TABLE main_meter_billing DROP COLUMN cost_item_price;
+
+ALTER TABLE sub_meter
+	DROP COLUMN floor_area,
+	DROP COLUMN occupants,
+	DROP COLUMN cost_share;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE statement (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_user INT NOT NULL REFERENCES spinus_user(id),
+	subid INT NOT NULL,
+	fk_tenant INT NOT NULL REFERENCES spinus_user(id),
+	begin_date DATE NOT NULL,
+	end_date DATE NOT NULL CHECK (end_date >= begin_date),
+	advance_price DOUBLE PRECISION NOT NULL,
+	total_price DOUBLE PRECISION NOT NULL,
+	balance DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY(id),
+	UNIQUE(fk_user, subid)
+);
+CREATE TABLE statement_sub_meter_billing (
+	fk_statement INT NOT NULL REFERENCES statement(id),
+	fk_sub_billing INT NOT NULL REFERENCES sub_meter_billing(id),
+	PRIMARY KEY(fk_statement, fk_sub_billing)
+);
+
+-- +goose Down
+DROP TABLE statement_sub_meter_billing;
+DROP TABLE statement;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE bank_account (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_user INT UNIQUE NOT NULL REFERENCES spinus_user(id),
+	iban VARCHAR(34) NOT NULL,
+	bic VARCHAR(11),
+	PRIMARY KEY(id)
+);
+
+-- +goose Down
+DROP TABLE bank_account;
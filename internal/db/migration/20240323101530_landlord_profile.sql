@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE landlord_profile (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_user INT UNIQUE NOT NULL REFERENCES spinus_user(id),
+	name VARCHAR(255) NOT NULL CHECK (LENGTH(TRIM(name)) >= 1),
+	company_id VARCHAR(16),
+	vat_id VARCHAR(16),
+	address VARCHAR(255) NOT NULL CHECK (LENGTH(TRIM(address)) >= 8),
+	PRIMARY KEY(id)
+);
+
+CREATE TABLE numbering_series (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_user INT UNIQUE NOT NULL REFERENCES spinus_user(id),
+	pattern VARCHAR(64) NOT NULL DEFAULT '{year}-{seq:04}',
+	seq_year INT NOT NULL DEFAULT 0,
+	last_seq INT NOT NULL DEFAULT 0,
+	PRIMARY KEY(id)
+);
+
+ALTER TABLE sub_meter_billing ADD COLUMN number VARCHAR(128);
+
+-- +goose Down
+ALTER TABLE sub_meter_billing DROP COLUMN number;
+DROP TABLE numbering_series;
+DROP TABLE landlord_profile;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE sub_meter_reading_photo (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_reading INT NOT NULL REFERENCES sub_meter_reading(id) ON DELETE CASCADE,
+	subid INT NOT NULL,
+	content_type VARCHAR(32) NOT NULL,
+	size INT NOT NULL,
+	PRIMARY KEY(id),
+	UNIQUE(fk_reading, subid)
+);
+
+-- +goose Down
+DROP TABLE sub_meter_reading_photo;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TYPE reading_source AS ENUM (
+	'manual',
+	'import',
+	'device',
+	'estimate'
+);
+ALTER TABLE sub_meter_reading
+	ADD COLUMN source READING_SOURCE NOT NULL DEFAULT 'manual',
+	ADD COLUMN fk_user INT REFERENCES spinus_user(id),
+	ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	ADD COLUMN estimated BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD CHECK (source != 'estimate' OR estimated);
+
+-- +goose Down
+ALTER TABLE sub_meter_reading
+	DROP COLUMN source,
+	DROP COLUMN fk_user,
+	DROP COLUMN created_at,
+	DROP COLUMN estimated;
+DROP TYPE IF EXISTS reading_source;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE sub_meter_interval_reading (
+	fk_sub_meter INT NOT NULL REFERENCES sub_meter(id),
+	begin_time TIMESTAMPTZ NOT NULL,
+	end_time TIMESTAMPTZ NOT NULL CHECK (end_time > begin_time),
+	consumption DOUBLE PRECISION NOT NULL CHECK (consumption >= 0),
+	PRIMARY KEY(fk_sub_meter, begin_time)
+);
+
+-- +goose Down
+DROP TABLE sub_meter_interval_reading;
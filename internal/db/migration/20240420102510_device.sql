@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE device (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_sub_meter INT NOT NULL REFERENCES sub_meter(id),
+	subid INT NOT NULL,
+	name VARCHAR(64) NOT NULL CHECK (LENGTH(TRIM(name)) >= 1),
+	token_hash BYTEA UNIQUE NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_used_at TIMESTAMPTZ,
+	PRIMARY KEY(id),
+	UNIQUE(fk_sub_meter, subid)
+);
+ALTER TABLE sub_meter_reading
+	ADD COLUMN fk_device INT REFERENCES device(id) ON DELETE SET NULL,
+	ADD COLUMN reading_time TIMESTAMPTZ,
+	ADD UNIQUE(fk_device, reading_time);
+
+-- +goose Down
+ALTER TABLE sub_meter_reading
+	DROP COLUMN fk_device,
+	DROP COLUMN reading_time;
+DROP TABLE device;
@@ -0,0 +1,12 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TYPE energy ADD VALUE IF NOT EXISTS 'heat';
+CREATE TABLE sub_meter_wmbus_key (
+	fk_sub_meter INT REFERENCES sub_meter(id),
+	aes_key BYTEA NOT NULL CHECK (LENGTH(aes_key) = 16),
+	PRIMARY KEY(fk_sub_meter)
+);
+
+-- +goose Down
+-- Values cannot be removed from enum types.
+DROP TABLE sub_meter_wmbus_key;
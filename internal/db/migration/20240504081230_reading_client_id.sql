@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE sub_meter_reading
+	ADD COLUMN client_id VARCHAR(64),
+	ADD UNIQUE(fk_user, client_id);
+
+-- +goose Down
+ALTER TABLE sub_meter_reading
+	DROP COLUMN client_id;
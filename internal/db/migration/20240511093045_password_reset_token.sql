@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE password_reset_token (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_user INT NOT NULL REFERENCES spinus_user(id),
+	token_hash BYTEA UNIQUE NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ,
+	PRIMARY KEY(id)
+);
+
+-- +goose Down
+DROP TABLE password_reset_token;
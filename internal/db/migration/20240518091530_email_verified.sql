@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE spinus_user
+	ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE spinus_user
+	DROP COLUMN email_verified;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE user_totp (
+	fk_user INT REFERENCES spinus_user(id),
+	secret BYTEA NOT NULL,
+	-- NULL until the user enters the first code.
+	enabled_at TIMESTAMPTZ,
+	last_counter BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY(fk_user)
+);
+
+CREATE TABLE user_recovery_code (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_user INT NOT NULL REFERENCES spinus_user(id),
+	code_hash BYTEA NOT NULL,
+	used_at TIMESTAMPTZ,
+	PRIMARY KEY(id),
+	UNIQUE(fk_user, code_hash)
+);
+
+-- +goose Down
+DROP TABLE user_recovery_code;
+DROP TABLE user_totp;
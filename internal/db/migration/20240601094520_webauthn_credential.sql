@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE webauthn_credential (
+	id INT GENERATED ALWAYS AS IDENTITY,
+	fk_user INT NOT NULL REFERENCES spinus_user(id),
+	credential_id BYTEA UNIQUE NOT NULL,
+	public_key BYTEA NOT NULL,
+	attestation_type VARCHAR(32) NOT NULL,
+	transports TEXT[] NOT NULL,
+	aaguid BYTEA NOT NULL,
+	sign_count BIGINT NOT NULL,
+	backup_eligible BOOLEAN NOT NULL,
+	backup_state BOOLEAN NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_used_at TIMESTAMPTZ,
+	PRIMARY KEY(id)
+);
+
+-- +goose Down
+DROP TABLE webauthn_credential;
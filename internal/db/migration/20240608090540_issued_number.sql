@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE issued_number (
+	fk_user INT NOT NULL REFERENCES spinus_user(id),
+	number VARCHAR(128) NOT NULL,
+	PRIMARY KEY(fk_user, number)
+);
+
+INSERT INTO issued_number (fk_user, number)
+SELECT DISTINCT main_meter.fk_user, sub_meter_billing.number
+FROM sub_meter_billing
+JOIN main_meter_billing
+	ON sub_meter_billing.fk_main_billing = main_meter_billing.id
+JOIN main_meter
+	ON main_meter_billing.fk_main_meter = main_meter.id
+WHERE sub_meter_billing.number IS NOT NULL;
+
+-- +goose Down
+DROP TABLE issued_number;
@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE numbering_sequence (
+	fk_user INT NOT NULL REFERENCES spinus_user(id),
+	seq_year INT NOT NULL,
+	last_seq INT NOT NULL DEFAULT 0,
+	PRIMARY KEY(fk_user, seq_year)
+);
+
+INSERT INTO numbering_sequence (fk_user, seq_year, last_seq)
+SELECT
+	fk_user,
+	CASE WHEN POSITION('{year}' IN pattern) = 0 THEN 0 ELSE seq_year END,
+	last_seq
+FROM numbering_series
+WHERE last_seq > 0;
+
+ALTER TABLE numbering_series DROP COLUMN seq_year, DROP COLUMN last_seq;
+
+-- +goose Down
+ALTER TABLE numbering_series
+	ADD COLUMN seq_year INT NOT NULL DEFAULT 0,
+	ADD COLUMN last_seq INT NOT NULL DEFAULT 0;
+
+UPDATE numbering_series
+SET seq_year = latest.seq_year, last_seq = latest.last_seq
+FROM (
+	SELECT DISTINCT ON (fk_user) fk_user, seq_year, last_seq
+	FROM numbering_sequence
+	ORDER BY fk_user, seq_year DESC
+) AS latest
+WHERE numbering_series.fk_user = latest.fk_user;
+
+DROP TABLE numbering_sequence;
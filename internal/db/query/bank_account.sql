@@ -0,0 +1,14 @@
+-- name: GetBankAccount :one
+SELECT * FROM bank_account
+WHERE fk_user = $1
+LIMIT 1;
+
+-- name: UpsertBankAccount :one
+INSERT INTO bank_account (
+	fk_user, iban, bic
+) VALUES (
+	$1, $2, $3
+)
+ON CONFLICT (fk_user) DO UPDATE
+	SET iban = EXCLUDED.iban, bic = EXCLUDED.bic
+RETURNING *;
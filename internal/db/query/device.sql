@@ -0,0 +1,25 @@
+-- name: CreateDevice :one
+INSERT INTO device (
+	fk_sub_meter, subid, name, token_hash
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3
+	FROM device
+	WHERE fk_sub_meter = $1
+RETURNING *;
+
+-- name: ListDevices :many
+SELECT * FROM device
+WHERE fk_sub_meter = $1
+ORDER BY subid;
+
+-- name: GetDeviceByTokenHash :one
+SELECT * FROM device
+WHERE token_hash = $1
+LIMIT 1;
+
+-- name: UpdateDeviceLastUsedAt :exec
+UPDATE device SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteDevice :exec
+DELETE FROM device
+WHERE fk_sub_meter = $1 AND subid = $2;
@@ -0,0 +1,18 @@
+-- name: GetLandlordProfile :one
+SELECT * FROM landlord_profile
+WHERE fk_user = $1
+LIMIT 1;
+
+-- name: UpsertLandlordProfile :one
+INSERT INTO landlord_profile (
+	fk_user, name, company_id, vat_id, address
+) VALUES (
+	$1, $2, $3, $4, $5
+)
+ON CONFLICT (fk_user) DO UPDATE
+	SET
+		name = EXCLUDED.name,
+		company_id = EXCLUDED.company_id,
+		vat_id = EXCLUDED.vat_id,
+		address = EXCLUDED.address
+RETURNING *;
@@ -0,0 +1,93 @@
+-- name: GetMainMeterBilling :one
+SELECT main_meter_billing.*, main_meter.fk_user AS main_user_id
+FROM main_meter_billing
+JOIN main_meter
+	ON main_meter_billing.fk_main_meter = main_meter.id
+WHERE fk_main_meter = $1 AND subid = $2
+LIMIT 1;
+
+-- name: GetLastMainMeterBillingPeriod :one
+SELECT main_meter_billing_period.*
+FROM main_meter_billing_period
+JOIN main_meter_billing
+	ON main_meter_billing_period.fk_main_billing = main_meter_billing.id
+WHERE main_meter_billing.fk_main_meter = $1
+ORDER BY main_meter_billing_period.end_date DESC
+LIMIT 1;
+
+-- name: ListMainMeterBillings :many
+SELECT * FROM main_meter_billing
+WHERE fk_main_meter = $1
+ORDER BY subid DESC;
+
+-- name: ListMainMeterBillingPeriods :many
+SELECT * FROM main_meter_billing_period
+WHERE fk_main_billing = $1
+ORDER BY subid;
+
+-- name: ListMainMeterBillingCostItems :many
+SELECT * FROM main_meter_billing_cost_item
+WHERE fk_main_billing = $1
+ORDER BY subid;
+
+-- name: ListSubMeterBillings :many
+SELECT
+	sub_meter_billing.*,
+	sub_meter.subid AS sub_meter_subid,
+	sub_meter.meter_id,
+	spinus_user.email
+FROM sub_meter_billing
+JOIN sub_meter
+	ON sub_meter_billing.fk_sub_meter = sub_meter.id
+JOIN spinus_user
+	ON sub_meter.fk_user = spinus_user.id
+WHERE fk_main_billing = $1
+ORDER BY sub_meter.subid;
+
+-- name: ListSubMeterBillingCostItems :many
+SELECT
+	sub_meter_billing_cost_item.fk_sub_billing,
+	main_meter_billing_cost_item.name,
+	main_meter_billing_cost_item.allocation_key,
+	sub_meter_billing_cost_item.share,
+	sub_meter_billing_cost_item.price
+FROM sub_meter_billing_cost_item
+JOIN main_meter_billing_cost_item
+	ON sub_meter_billing_cost_item.fk_main_billing_cost_item =
+		main_meter_billing_cost_item.id
+WHERE main_meter_billing_cost_item.fk_main_billing = $1
+ORDER BY sub_meter_billing_cost_item.fk_sub_billing, main_meter_billing_cost_item.subid;
+
+-- name: ListSubMeterBillingPeriods :many
+SELECT
+	main_meter_billing_period.begin_date,
+	main_meter_billing_period.end_date,
+	sub_meter_billing_period.energy_consumption,
+	sub_meter_billing_period.consumed_energy_price,
+	sub_meter_billing_period.service_price,
+	sub_meter_billing_period.advance_price,
+	sub_meter_billing_period.total_price
+FROM sub_meter_billing_period
+JOIN main_meter_billing_period
+	ON sub_meter_billing_period.fk_main_billing_period = main_meter_billing_period.id
+WHERE sub_meter_billing_period.fk_sub_billing = $1
+ORDER BY main_meter_billing_period.subid;
+
+-- name: ListSubMeterBillingPeriodConsumptions :many
+SELECT
+	main_meter_billing.subid AS billing_subid,
+	main_meter_billing_period.begin_date,
+	main_meter_billing_period.end_date,
+	sub_meter.subid AS sub_meter_subid,
+	sub_meter_billing_period.energy_consumption
+FROM sub_meter_billing_period
+JOIN main_meter_billing_period
+	ON sub_meter_billing_period.fk_main_billing_period = main_meter_billing_period.id
+JOIN main_meter_billing
+	ON main_meter_billing_period.fk_main_billing = main_meter_billing.id
+JOIN sub_meter_billing
+	ON sub_meter_billing_period.fk_sub_billing = sub_meter_billing.id
+JOIN sub_meter
+	ON sub_meter_billing.fk_sub_meter = sub_meter.id
+WHERE main_meter_billing.fk_main_meter = $1
+ORDER BY main_meter_billing.subid, main_meter_billing_period.subid, sub_meter.subid;
@@ -6,7 +6,8 @@ WITH	selected_sub_meter AS (
 )
 SELECT		later_reading.sub_meter_id,
 		sub_meter_reading.reading_value,
-		sub_meter_reading.reading_date
+		sub_meter_reading.reading_date,
+		sub_meter_reading.estimated
 FROM (
 	SELECT		selected_sub_meter.id AS sub_meter_id,
 			min(sub_meter_reading.reading_date) AS reading_date
@@ -22,7 +23,8 @@ ON		later_reading.sub_meter_id = sub_meter_reading.fk_sub_meter AND
 UNION
 SELECT	selected_sub_meter.id AS sub_meter_id,
 	sub_meter_reading.reading_value,
-	sub_meter_reading.reading_date
+	sub_meter_reading.reading_date,
+	sub_meter_reading.estimated
 FROM	selected_sub_meter
 JOIN	sub_meter_reading
 ON	selected_sub_meter.id = sub_meter_reading.fk_sub_meter
@@ -31,7 +33,8 @@ WHERE	sub_meter_reading.reading_date BETWEEN
 UNION
 SELECT		selected_sub_meter.id AS sub_meter_id,
 		sub_meter_reading.reading_value,
-		earlier_reading.reading_date
+		earlier_reading.reading_date,
+		sub_meter_reading.estimated
 FROM 		selected_sub_meter
 LEFT JOIN (
 	SELECT		selected_sub_meter.id AS sub_meter_id,
@@ -59,8 +62,9 @@ INSERT INTO main_meter_billing (
 	consumed_energy_price,
 	service_price,
 	advance_price,
-	total_price
-) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9
+	total_price,
+	cost_item_price
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 	FROM main_meter_billing
 	WHERE fk_main_meter = $1
 RETURNING *;
@@ -92,8 +96,10 @@ INSERT INTO sub_meter_billing (
 	consumed_energy_price,
 	service_price,
 	advance_price,
-	total_price
-) SELECT $1, $2, COALESCE(MAX(subid), 0) + 1, $3, $4, $5, $6, $7
+	total_price,
+	cost_item_price,
+	number
+) SELECT $1, $2, COALESCE(MAX(subid), 0) + 1, $3, $4, $5, $6, $7, $8, $9
 	FROM sub_meter_billing
 	WHERE fk_sub_meter = $1
 RETURNING *;
@@ -111,3 +117,32 @@ INSERT INTO sub_meter_billing_period (
 	$1, $2, $3, $4, $5, $6, $7
 )
 RETURNING *;
+
+-- name: ListSubMeterAllocations :many
+SELECT id, floor_area, occupants, cost_share
+FROM sub_meter
+WHERE fk_main_meter = $1
+ORDER BY subid;
+
+-- name: CreateMainMeterBillingCostItem :one
+INSERT INTO main_meter_billing_cost_item (
+	fk_main_billing,
+	subid,
+	name,
+	allocation_key,
+	price
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4
+	FROM main_meter_billing_cost_item
+	WHERE fk_main_billing = $1
+RETURNING *;
+
+-- name: CreateSubMeterBillingCostItem :one
+INSERT INTO sub_meter_billing_cost_item (
+	fk_sub_billing,
+	fk_main_billing_cost_item,
+	share,
+	price
+) VALUES (
+	$1, $2, $3, $4
+)
+RETURNING *;
@@ -0,0 +1,37 @@
+-- name: GetNumberingSeries :one
+SELECT * FROM numbering_series
+WHERE fk_user = $1
+LIMIT 1;
+
+-- name: UpsertNumberingSeries :one
+INSERT INTO numbering_series (
+	fk_user, pattern
+) VALUES (
+	$1, $2
+)
+ON CONFLICT (fk_user) DO UPDATE
+	SET pattern = EXCLUDED.pattern
+RETURNING *;
+
+-- name: AllocateNumber :one
+-- Every year has its own sequence, patterns without year use year 0. The
+-- sequence row stays locked until the end of the transaction, so concurrent
+-- billings never get the same number.
+INSERT INTO numbering_sequence (
+	fk_user, seq_year, last_seq
+) VALUES (
+	$1, $2, 1
+)
+ON CONFLICT (fk_user, seq_year) DO UPDATE
+	SET last_seq = numbering_sequence.last_seq + 1
+RETURNING last_seq;
+
+-- name: IssueNumber :execrows
+-- Numbers are unique per issuer. Number already issued is not inserted, even
+-- after its billing was deleted.
+INSERT INTO issued_number (
+	fk_user, number
+) VALUES (
+	$1, $2
+)
+ON CONFLICT DO NOTHING;
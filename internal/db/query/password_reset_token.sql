@@ -0,0 +1,20 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_token (
+	fk_user, token_hash, expires_at
+) VALUES (
+	$1, $2, $3
+);
+
+-- name: GetPasswordResetToken :one
+SELECT * FROM password_reset_token
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+LIMIT 1;
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_token SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING fk_user;
+
+-- name: UseUserPasswordResetTokens :exec
+UPDATE password_reset_token SET used_at = NOW()
+WHERE fk_user = $1 AND used_at IS NULL;
@@ -0,0 +1,92 @@
+-- name: GetStatement :one
+SELECT statement.*, tenant.email AS tenant_email
+FROM statement
+JOIN spinus_user AS tenant
+	ON statement.fk_tenant = tenant.id
+WHERE fk_user = $1 AND subid = $2
+LIMIT 1;
+
+-- name: ListStatements :many
+SELECT statement.*, tenant.email AS tenant_email
+FROM statement
+JOIN spinus_user AS tenant
+	ON statement.fk_tenant = tenant.id
+WHERE fk_user = $1
+ORDER BY subid DESC;
+
+-- name: ListStatementTenants :many
+SELECT DISTINCT spinus_user.id, spinus_user.email
+FROM sub_meter
+JOIN main_meter
+	ON sub_meter.fk_main_meter = main_meter.id
+JOIN spinus_user
+	ON sub_meter.fk_user = spinus_user.id
+WHERE main_meter.fk_user = $1
+ORDER BY spinus_user.email;
+
+-- name: ListTenantSubMeterBillings :many
+SELECT
+	sub_meter_billing.id,
+	sub_meter_billing.advance_price,
+	sub_meter_billing.total_price
+FROM sub_meter_billing
+JOIN main_meter_billing
+	ON sub_meter_billing.fk_main_billing = main_meter_billing.id
+JOIN sub_meter
+	ON sub_meter_billing.fk_sub_meter = sub_meter.id
+JOIN main_meter
+	ON sub_meter.fk_main_meter = main_meter.id
+WHERE	main_meter.fk_user = sqlc.arg(main_user_id) AND
+	sub_meter.fk_user = sqlc.arg(tenant_id) AND
+	main_meter_billing.begin_date >= sqlc.arg(begin_date) AND
+	main_meter_billing.end_date <= sqlc.arg(end_date)
+ORDER BY sub_meter_billing.id;
+
+-- name: CreateStatement :one
+INSERT INTO statement (
+	fk_user,
+	subid,
+	fk_tenant,
+	begin_date,
+	end_date,
+	advance_price,
+	total_price,
+	balance
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6, $7
+	FROM statement
+	WHERE fk_user = $1
+RETURNING *;
+
+-- name: CreateStatementSubMeterBilling :exec
+INSERT INTO statement_sub_meter_billing (
+	fk_statement, fk_sub_billing
+) VALUES (
+	$1, $2
+);
+
+-- name: ListStatementSubMeterBillings :many
+SELECT
+	main_meter.energy,
+	main_meter.meter_id AS main_meter_id,
+	main_meter.address,
+	sub_meter.subid AS sub_meter_subid,
+	sub_meter.meter_id AS sub_meter_id,
+	main_meter_billing.begin_date,
+	main_meter_billing.end_date,
+	sub_meter_billing.energy_consumption,
+	sub_meter_billing.consumed_energy_price,
+	sub_meter_billing.service_price,
+	sub_meter_billing.cost_item_price,
+	sub_meter_billing.advance_price,
+	sub_meter_billing.total_price
+FROM statement_sub_meter_billing
+JOIN sub_meter_billing
+	ON statement_sub_meter_billing.fk_sub_billing = sub_meter_billing.id
+JOIN main_meter_billing
+	ON sub_meter_billing.fk_main_billing = main_meter_billing.id
+JOIN sub_meter
+	ON sub_meter_billing.fk_sub_meter = sub_meter.id
+JOIN main_meter
+	ON sub_meter.fk_main_meter = main_meter.id
+WHERE statement_sub_meter_billing.fk_statement = $1
+ORDER BY main_meter.energy, main_meter.id, main_meter_billing.begin_date, sub_meter.subid;
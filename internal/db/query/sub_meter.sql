@@ -4,9 +4,13 @@ SELECT
 	sub_meter.fk_main_meter AS main_meter_id,
 	sub_meter.subid,
 	sub_meter.meter_id AS sub_meter_id,
+	sub_meter.floor_area,
+	sub_meter.occupants,
+	sub_meter.cost_share,
 	sub_meter.fk_user AS sub_user_id,
 	sub_user.email AS sub_user_email,
 	main_meter.address,
+	main_meter.energy,
 	main_meter.fk_user AS main_user_id,
 	main_user.email AS main_user_email
 FROM sub_meter
@@ -20,17 +24,28 @@ WHERE fk_main_meter = $1 AND subid = $2
 LIMIT 1;
 
 -- name: ListSubMeters :many
-SELECT subid, meter_id, email
+SELECT sub_meter.id, subid, meter_id, floor_area, occupants, cost_share, email
 FROM sub_meter
 JOIN spinus_user
 	ON sub_meter.fk_user = spinus_user.id
 WHERE fk_main_meter = $1
 ORDER BY subid;
 
+-- name: GetSubMeterEnergy :one
+SELECT main_meter.energy
+FROM sub_meter
+JOIN main_meter
+	ON sub_meter.fk_main_meter = main_meter.id
+WHERE sub_meter.id = $1;
+
+-- name: ListSubMeterIDsByMeterID :many
+SELECT id FROM sub_meter
+WHERE fk_main_meter = $1 AND meter_id = $2;
+
 -- name: CreateSubMeter :one
 INSERT INTO sub_meter (
-	fk_main_meter, subid, meter_id, fk_user
-) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3
+	fk_main_meter, subid, meter_id, fk_user, floor_area, occupants, cost_share
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6
 	FROM sub_meter
 	WHERE fk_main_meter = $1
 RETURNING *;
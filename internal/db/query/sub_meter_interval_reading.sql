@@ -0,0 +1,40 @@
+-- name: CreateSubMeterIntervalReadings :copyfrom
+INSERT INTO sub_meter_interval_reading (
+	fk_sub_meter, begin_time, end_time, consumption
+) VALUES (
+	$1, $2, $3, $4
+);
+
+-- name: DeleteSubMeterIntervalReadings :exec
+DELETE FROM sub_meter_interval_reading
+WHERE	fk_sub_meter = sqlc.arg(fk_sub_meter) AND
+	begin_time >= sqlc.arg(begin_time) AND
+	begin_time < sqlc.arg(end_time);
+
+-- name: ListSubMeterIntervalDays :many
+SELECT
+	(begin_time AT TIME ZONE 'UTC')::DATE AS day,
+	COUNT(*)::INT AS interval_count,
+	SUM(consumption)::DOUBLE PRECISION AS consumption,
+	SUM(EXTRACT(EPOCH FROM end_time - begin_time))::INT AS covered_seconds
+FROM sub_meter_interval_reading
+WHERE fk_sub_meter = $1
+GROUP BY day
+ORDER BY day DESC;
+
+-- name: ListMainMeterIntervalDays :many
+SELECT
+	sub_meter_interval_reading.fk_sub_meter,
+	(sub_meter_interval_reading.begin_time AT TIME ZONE 'UTC')::DATE AS day,
+	SUM(sub_meter_interval_reading.consumption)::DOUBLE PRECISION AS consumption,
+	SUM(EXTRACT(EPOCH FROM
+		sub_meter_interval_reading.end_time -
+		sub_meter_interval_reading.begin_time))::INT AS covered_seconds
+FROM sub_meter_interval_reading
+JOIN sub_meter
+	ON sub_meter_interval_reading.fk_sub_meter = sub_meter.id
+WHERE	sub_meter.fk_main_meter = sqlc.arg(fk_main_meter) AND
+	sub_meter_interval_reading.begin_time >= sqlc.arg(begin_time) AND
+	sub_meter_interval_reading.begin_time < sqlc.arg(end_time)
+GROUP BY sub_meter_interval_reading.fk_sub_meter, day
+ORDER BY sub_meter_interval_reading.fk_sub_meter, day;
@@ -3,15 +3,133 @@ SELECT * FROM sub_meter_reading
 WHERE fk_sub_meter = $1
 ORDER BY reading_date DESC;
 
+-- name: ListSubMeterReadingsWithUser :many
+SELECT
+	sub_meter_reading.id,
+	sub_meter_reading.fk_sub_meter,
+	sub_meter_reading.subid,
+	sub_meter_reading.reading_value,
+	sub_meter_reading.reading_date,
+	sub_meter_reading.source,
+	sub_meter_reading.fk_user,
+	sub_meter_reading.created_at,
+	sub_meter_reading.estimated,
+	spinus_user.email AS user_email,
+	device.name AS device_name
+FROM sub_meter_reading
+LEFT JOIN spinus_user
+	ON sub_meter_reading.fk_user = spinus_user.id
+LEFT JOIN device
+	ON sub_meter_reading.fk_device = device.id
+WHERE sub_meter_reading.fk_sub_meter = $1
+ORDER BY sub_meter_reading.reading_date DESC;
+
+-- name: CreateDeviceReading :one
+-- Reading sent again while the first one is being stored is not inserted, no
+-- row is returned then.
+INSERT INTO sub_meter_reading (
+	fk_sub_meter,
+	subid,
+	reading_value,
+	reading_date,
+	source,
+	fk_device,
+	reading_time
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6
+	FROM sub_meter_reading
+	WHERE fk_sub_meter = $1
+ON CONFLICT (fk_device, reading_time) DO NOTHING
+RETURNING *;
+
 -- name: CreateSubMeterReading :one
 INSERT INTO sub_meter_reading (
-	fk_sub_meter, subid, reading_value, reading_date
-) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3
+	fk_sub_meter,
+	subid,
+	reading_value,
+	reading_date,
+	source,
+	fk_user,
+	estimated,
+	fk_device,
+	reading_time,
+	client_id
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9
 	FROM sub_meter_reading
 	WHERE fk_sub_meter = $1
 RETURNING *;
 
+-- name: GetDeviceReading :one
+SELECT * FROM sub_meter_reading
+WHERE fk_device = $1 AND reading_time = $2
+LIMIT 1;
+
 -- name: GetSubMeterReadingForDate :one
-SELECT 1 FROM sub_meter_reading
+SELECT * FROM sub_meter_reading
 WHERE fk_sub_meter = $1 AND reading_date = $2
 LIMIT 1;
+
+-- name: ListSubMeterReadingsBetween :many
+SELECT * FROM sub_meter_reading
+WHERE	fk_sub_meter = sqlc.arg(fk_sub_meter) AND
+	reading_date BETWEEN sqlc.arg(date_min) AND sqlc.arg(date_max)
+ORDER BY reading_date;
+
+-- name: ListSubMeterLastReadings :many
+SELECT
+	sub_meter.id,
+	sub_meter.subid,
+	sub_meter.meter_id,
+	last_reading.reading_date,
+	last_reading.reading_value
+FROM sub_meter
+LEFT JOIN LATERAL (
+	SELECT reading_date, reading_value
+	FROM sub_meter_reading
+	WHERE	fk_sub_meter = sub_meter.id AND
+		reading_date < sqlc.arg(before_date)
+	ORDER BY reading_date DESC
+	LIMIT 1
+) AS last_reading ON true
+WHERE sub_meter.fk_main_meter = sqlc.arg(fk_main_meter)
+ORDER BY sub_meter.subid;
+
+-- name: ListMainMeterReadings :many
+SELECT
+	sub_meter.subid AS sub_meter_subid,
+	sub_meter.meter_id,
+	sub_meter_reading.subid,
+	sub_meter_reading.reading_value,
+	sub_meter_reading.reading_date,
+	sub_meter_reading.source,
+	sub_meter_reading.estimated
+FROM sub_meter_reading
+JOIN sub_meter
+	ON sub_meter_reading.fk_sub_meter = sub_meter.id
+WHERE sub_meter.fk_main_meter = $1
+ORDER BY sub_meter.subid, sub_meter_reading.reading_date;
+
+-- name: GetSubMeterReading :one
+SELECT * FROM sub_meter_reading
+WHERE fk_sub_meter = $1 AND subid = $2
+LIMIT 1;
+
+-- name: GetClientReading :one
+SELECT * FROM sub_meter_reading
+WHERE fk_user = $1 AND client_id = $2
+LIMIT 1;
+
+-- name: UpdateSubMeterReadingValue :one
+UPDATE sub_meter_reading SET
+	reading_value = $2,
+	source = $3,
+	fk_user = $4,
+	estimated = $5,
+	fk_device = NULL,
+	reading_time = $6,
+	client_id = $7
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteSubMeterReading :exec
+DELETE FROM sub_meter_reading
+WHERE id = $1;
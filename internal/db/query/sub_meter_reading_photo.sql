@@ -0,0 +1,27 @@
+-- name: CreateSubMeterReadingPhoto :one
+INSERT INTO sub_meter_reading_photo (
+	fk_reading, subid, content_type, size
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3
+	FROM sub_meter_reading_photo
+	WHERE fk_reading = $1
+RETURNING *;
+
+-- name: GetSubMeterReadingPhoto :one
+SELECT * FROM sub_meter_reading_photo
+WHERE fk_reading = $1 AND id = $2
+LIMIT 1;
+
+-- name: ListSubMeterReadingPhotos :many
+SELECT
+	sub_meter_reading_photo.id,
+	sub_meter_reading_photo.fk_reading,
+	sub_meter_reading_photo.subid
+FROM sub_meter_reading_photo
+JOIN sub_meter_reading
+	ON sub_meter_reading_photo.fk_reading = sub_meter_reading.id
+WHERE sub_meter_reading.fk_sub_meter = $1
+ORDER BY sub_meter_reading_photo.fk_reading, sub_meter_reading_photo.subid;
+
+-- name: ListSubMeterReadingPhotoIDs :many
+SELECT id FROM sub_meter_reading_photo
+WHERE fk_reading = $1;
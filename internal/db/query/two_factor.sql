@@ -0,0 +1,45 @@
+-- name: GetUserTotp :one
+SELECT * FROM user_totp
+WHERE fk_user = $1
+LIMIT 1;
+
+-- name: UpsertUserTotp :exec
+INSERT INTO user_totp (
+	fk_user, secret
+) VALUES (
+	$1, $2
+)
+ON CONFLICT (fk_user) DO UPDATE
+SET secret = EXCLUDED.secret, enabled_at = NULL, last_counter = 0
+WHERE user_totp.enabled_at IS NULL;
+
+-- name: EnableUserTotp :execrows
+UPDATE user_totp SET enabled_at = NOW(), last_counter = $2
+WHERE fk_user = $1 AND enabled_at IS NULL;
+
+-- name: UpdateUserTotpCounter :execrows
+UPDATE user_totp SET last_counter = $2
+WHERE fk_user = $1 AND last_counter < $2;
+
+-- name: DeleteUserTotp :exec
+DELETE FROM user_totp
+WHERE fk_user = $1;
+
+-- name: CreateUserRecoveryCode :exec
+INSERT INTO user_recovery_code (
+	fk_user, code_hash
+) VALUES (
+	$1, $2
+);
+
+-- name: GetUnusedUserRecoveryCodeCount :one
+SELECT COUNT(*) FROM user_recovery_code
+WHERE fk_user = $1 AND used_at IS NULL;
+
+-- name: UseUserRecoveryCode :execrows
+UPDATE user_recovery_code SET used_at = NOW()
+WHERE fk_user = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM user_recovery_code
+WHERE fk_user = $1;
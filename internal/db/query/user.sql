@@ -9,14 +9,30 @@ WHERE username = $1
 LIMIT 1;
 
 -- name: GetUserByEmail :one
-SELECT 1 FROM spinus_user
+SELECT * FROM spinus_user
 WHERE email = $1
 LIMIT 1;
 
+-- name: HashPassword :one
+SELECT crypt(@password::TEXT, gen_salt('bf'))::TEXT AS password_hash;
+
 -- name: CreateUser :one
 INSERT INTO spinus_user (
-	username, email, password
+	username, email, password, email_verified
 ) VALUES (
-	$1, $2, crypt($3, gen_salt('bf'))
+	$1, $2, $3, TRUE
 )
 RETURNING *;
+
+-- name: UpdateUserPassword :exec
+UPDATE spinus_user SET password = crypt($2, gen_salt('bf'))
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM spinus_user
+WHERE id = $1
+LIMIT 1;
+
+-- name: VerifyUserEmail :exec
+UPDATE spinus_user SET email_verified = TRUE
+WHERE id = $1 AND email = $2;
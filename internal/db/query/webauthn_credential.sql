@@ -0,0 +1,21 @@
+-- name: ListUserWebauthnCredentials :many
+SELECT * FROM webauthn_credential
+WHERE fk_user = $1
+ORDER BY id;
+
+-- name: CreateWebauthnCredential :exec
+INSERT INTO webauthn_credential (
+	fk_user, credential_id, public_key, attestation_type, transports, aaguid,
+	sign_count, backup_eligible, backup_state, name
+) VALUES (
+	$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+);
+
+-- name: UpdateWebauthnCredentialUse :exec
+UPDATE webauthn_credential
+SET sign_count = $2, backup_state = $3, last_used_at = NOW()
+WHERE credential_id = $1;
+
+-- name: DeleteWebauthnCredential :exec
+DELETE FROM webauthn_credential
+WHERE id = $1 AND fk_user = $2;
@@ -0,0 +1,17 @@
+-- name: ListSubMeterWmbusKeys :many
+SELECT sub_meter_wmbus_key.*
+FROM sub_meter_wmbus_key
+JOIN sub_meter
+	ON sub_meter_wmbus_key.fk_sub_meter = sub_meter.id
+WHERE sub_meter.fk_main_meter = $1;
+
+-- name: UpsertSubMeterWmbusKey :exec
+INSERT INTO sub_meter_wmbus_key (
+	fk_sub_meter, aes_key
+) VALUES (
+	$1, $2
+) ON CONFLICT (fk_sub_meter) DO UPDATE SET aes_key = EXCLUDED.aes_key;
+
+-- name: DeleteSubMeterWmbusKey :exec
+DELETE FROM sub_meter_wmbus_key
+WHERE fk_sub_meter = $1;
@@ -11,12 +11,68 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AllocationKey string
+
+const (
+	AllocationKeyEqual     AllocationKey = "equal"
+	AllocationKeyFloorArea AllocationKey = "floor_area"
+	AllocationKeyOccupants AllocationKey = "occupants"
+	AllocationKeyCustom    AllocationKey = "custom"
+)
+
+func (e *AllocationKey) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = AllocationKey(s)
+	case string:
+		*e = AllocationKey(s)
+	default:
+		return fmt.Errorf("unsupported scan type for AllocationKey: %T", src)
+	}
+	return nil
+}
+
+type NullAllocationKey struct {
+	AllocationKey AllocationKey
+	Valid         bool // Valid is true if AllocationKey is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullAllocationKey) Scan(value interface{}) error {
+	if value == nil {
+		ns.AllocationKey, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.AllocationKey.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullAllocationKey) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.AllocationKey), nil
+}
+
+func (e AllocationKey) Valid() bool {
+	switch e {
+	case AllocationKeyEqual,
+		AllocationKeyFloorArea,
+		AllocationKeyOccupants,
+		AllocationKeyCustom:
+		return true
+	}
+	return false
+}
+
 type Energy string
 
 const (
 	EnergyElectricity Energy = "electricity"
 	EnergyGas         Energy = "gas"
 	EnergyWater       Energy = "water"
+	EnergyHeat        Energy = "heat"
 )
 
 func (e *Energy) Scan(src interface{}) error {
@@ -58,12 +114,99 @@ func (e Energy) Valid() bool {
 	switch e {
 	case EnergyElectricity,
 		EnergyGas,
-		EnergyWater:
+		EnergyWater,
+		EnergyHeat:
+		return true
+	}
+	return false
+}
+
+type ReadingSource string
+
+const (
+	ReadingSourceManual   ReadingSource = "manual"
+	ReadingSourceImport   ReadingSource = "import"
+	ReadingSourceDevice   ReadingSource = "device"
+	ReadingSourceEstimate ReadingSource = "estimate"
+)
+
+func (e *ReadingSource) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ReadingSource(s)
+	case string:
+		*e = ReadingSource(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ReadingSource: %T", src)
+	}
+	return nil
+}
+
+type NullReadingSource struct {
+	ReadingSource ReadingSource
+	Valid         bool // Valid is true if ReadingSource is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullReadingSource) Scan(value interface{}) error {
+	if value == nil {
+		ns.ReadingSource, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.ReadingSource.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullReadingSource) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.ReadingSource), nil
+}
+
+func (e ReadingSource) Valid() bool {
+	switch e {
+	case ReadingSourceManual,
+		ReadingSourceImport,
+		ReadingSourceDevice,
+		ReadingSourceEstimate:
 		return true
 	}
 	return false
 }
 
+type BankAccount struct {
+	ID     int32
+	FkUser int32
+	Iban   string
+	Bic    pgtype.Text
+}
+
+type Device struct {
+	ID         int32
+	FkSubMeter int32
+	Subid      int32
+	Name       string
+	TokenHash  []byte
+	CreatedAt  pgtype.Timestamptz
+	LastUsedAt pgtype.Timestamptz
+}
+
+type IssuedNumber struct {
+	FkUser int32
+	Number string
+}
+
+type LandlordProfile struct {
+	ID        int32
+	FkUser    int32
+	Name      string
+	CompanyID pgtype.Text
+	VatID     pgtype.Text
+	Address   string
+}
+
 type MainMeter struct {
 	ID      int32
 	MeterID string
@@ -84,6 +227,16 @@ type MainMeterBilling struct {
 	ServicePrice        pgtype.Float8
 	AdvancePrice        float64
 	TotalPrice          float64
+	CostItemPrice       float64
+}
+
+type MainMeterBillingCostItem struct {
+	ID            int32
+	FkMainBilling int32
+	Subid         int32
+	Name          string
+	AllocationKey AllocationKey
+	Price         float64
 }
 
 type MainMeterBillingPeriod struct {
@@ -101,11 +254,50 @@ type MainMeterBillingPeriod struct {
 	TotalPrice          float64
 }
 
+type NumberingSeries struct {
+	ID      int32
+	FkUser  int32
+	Pattern string
+}
+
+type NumberingSequence struct {
+	FkUser  int32
+	SeqYear int32
+	LastSeq int32
+}
+
+type PasswordResetToken struct {
+	ID        int32
+	FkUser    int32
+	TokenHash []byte
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+}
+
 type SpinusUser struct {
-	ID       int32
-	Username string
-	Email    string
-	Password string
+	ID            int32
+	Username      string
+	Email         string
+	Password      string
+	EmailVerified bool
+}
+
+type Statement struct {
+	ID           int32
+	FkUser       int32
+	Subid        int32
+	FkTenant     int32
+	BeginDate    pgtype.Date
+	EndDate      pgtype.Date
+	AdvancePrice float64
+	TotalPrice   float64
+	Balance      float64
+}
+
+type StatementSubMeterBilling struct {
+	FkStatement  int32
+	FkSubBilling int32
 }
 
 type SubMeter struct {
@@ -114,6 +306,9 @@ type SubMeter struct {
 	Subid       int32
 	MeterID     pgtype.Text
 	FkUser      int32
+	FloorArea   pgtype.Float8
+	Occupants   pgtype.Int4
+	CostShare   pgtype.Float8
 }
 
 type SubMeterBilling struct {
@@ -126,6 +321,16 @@ type SubMeterBilling struct {
 	ServicePrice        pgtype.Float8
 	AdvancePrice        float64
 	TotalPrice          float64
+	CostItemPrice       float64
+	Number              pgtype.Text
+}
+
+type SubMeterBillingCostItem struct {
+	ID                    int32
+	FkSubBilling          int32
+	FkMainBillingCostItem int32
+	Share                 float64
+	Price                 float64
 }
 
 type SubMeterBillingPeriod struct {
@@ -139,10 +344,67 @@ type SubMeterBillingPeriod struct {
 	TotalPrice          float64
 }
 
+type SubMeterIntervalReading struct {
+	FkSubMeter  int32
+	BeginTime   pgtype.Timestamptz
+	EndTime     pgtype.Timestamptz
+	Consumption float64
+}
+
 type SubMeterReading struct {
 	ID           int32
 	FkSubMeter   int32
 	Subid        int32
 	ReadingValue float64
 	ReadingDate  pgtype.Date
+	Source       ReadingSource
+	FkUser       pgtype.Int4
+	CreatedAt    pgtype.Timestamptz
+	Estimated    bool
+	FkDevice     pgtype.Int4
+	ReadingTime  pgtype.Timestamptz
+	ClientID     pgtype.Text
+}
+
+type SubMeterReadingPhoto struct {
+	ID          int32
+	FkReading   int32
+	Subid       int32
+	ContentType string
+	Size        int32
+}
+
+type SubMeterWmbusKey struct {
+	FkSubMeter int32
+	AesKey     []byte
+}
+
+type UserRecoveryCode struct {
+	ID       int32
+	FkUser   int32
+	CodeHash []byte
+	UsedAt   pgtype.Timestamptz
+}
+
+type UserTotp struct {
+	FkUser      int32
+	Secret      []byte
+	EnabledAt   pgtype.Timestamptz
+	LastCounter int64
+}
+
+type WebauthnCredential struct {
+	ID              int32
+	FkUser          int32
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	Aaguid          []byte
+	SignCount       int64
+	BackupEligible  bool
+	BackupState     bool
+	Name            string
+	CreatedAt       pgtype.Timestamptz
+	LastUsedAt      pgtype.Timestamptz
 }
@@ -22,11 +22,12 @@ INSERT INTO main_meter_billing (
 	consumed_energy_price,
 	service_price,
 	advance_price,
-	total_price
-) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9
+	total_price,
+	cost_item_price
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 	FROM main_meter_billing
 	WHERE fk_main_meter = $1
-RETURNING id, fk_main_meter, subid, max_day_diff, begin_date, end_date, energy_consumption, consumed_energy_price, service_price, advance_price, total_price
+RETURNING id, fk_main_meter, subid, max_day_diff, begin_date, end_date, energy_consumption, consumed_energy_price, service_price, advance_price, total_price, cost_item_price
 `
 
 type CreateMainMeterBillingParams struct {
@@ -39,6 +40,7 @@ type CreateMainMeterBillingParams struct {
 	ServicePrice        pgtype.Float8
 	AdvancePrice        float64
 	TotalPrice          float64
+	CostItemPrice       float64
 }
 
 func (q *Queries) CreateMainMeterBilling(ctx context.Context, arg CreateMainMeterBillingParams) (MainMeterBilling, error) {
@@ -52,6 +54,7 @@ func (q *Queries) CreateMainMeterBilling(ctx context.Context, arg CreateMainMete
 		arg.ServicePrice,
 		arg.AdvancePrice,
 		arg.TotalPrice,
+		arg.CostItemPrice,
 	)
 	var i MainMeterBilling
 	err := row.Scan(
@@ -66,6 +69,46 @@ func (q *Queries) CreateMainMeterBilling(ctx context.Context, arg CreateMainMete
 		&i.ServicePrice,
 		&i.AdvancePrice,
 		&i.TotalPrice,
+		&i.CostItemPrice,
+	)
+	return i, err
+}
+
+const createMainMeterBillingCostItem = `-- name: CreateMainMeterBillingCostItem :one
+INSERT INTO main_meter_billing_cost_item (
+	fk_main_billing,
+	subid,
+	name,
+	allocation_key,
+	price
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4
+	FROM main_meter_billing_cost_item
+	WHERE fk_main_billing = $1
+RETURNING id, fk_main_billing, subid, name, allocation_key, price
+`
+
+type CreateMainMeterBillingCostItemParams struct {
+	FkMainBilling int32
+	Name          string
+	AllocationKey AllocationKey
+	Price         float64
+}
+
+func (q *Queries) CreateMainMeterBillingCostItem(ctx context.Context, arg CreateMainMeterBillingCostItemParams) (MainMeterBillingCostItem, error) {
+	row := q.db.QueryRow(ctx, createMainMeterBillingCostItem,
+		arg.FkMainBilling,
+		arg.Name,
+		arg.AllocationKey,
+		arg.Price,
+	)
+	var i MainMeterBillingCostItem
+	err := row.Scan(
+		&i.ID,
+		&i.FkMainBilling,
+		&i.Subid,
+		&i.Name,
+		&i.AllocationKey,
+		&i.Price,
 	)
 	return i, err
 }
@@ -142,11 +185,13 @@ INSERT INTO sub_meter_billing (
 	consumed_energy_price,
 	service_price,
 	advance_price,
-	total_price
-) SELECT $1, $2, COALESCE(MAX(subid), 0) + 1, $3, $4, $5, $6, $7
+	total_price,
+	cost_item_price,
+	number
+) SELECT $1, $2, COALESCE(MAX(subid), 0) + 1, $3, $4, $5, $6, $7, $8, $9
 	FROM sub_meter_billing
 	WHERE fk_sub_meter = $1
-RETURNING id, fk_sub_meter, fk_main_billing, subid, energy_consumption, consumed_energy_price, service_price, advance_price, total_price
+RETURNING id, fk_sub_meter, fk_main_billing, subid, energy_consumption, consumed_energy_price, service_price, advance_price, total_price, cost_item_price, number
 `
 
 type CreateSubMeterBillingParams struct {
@@ -157,6 +202,8 @@ type CreateSubMeterBillingParams struct {
 	ServicePrice        pgtype.Float8
 	AdvancePrice        float64
 	TotalPrice          float64
+	CostItemPrice       float64
+	Number              pgtype.Text
 }
 
 func (q *Queries) CreateSubMeterBilling(ctx context.Context, arg CreateSubMeterBillingParams) (SubMeterBilling, error) {
@@ -168,6 +215,8 @@ func (q *Queries) CreateSubMeterBilling(ctx context.Context, arg CreateSubMeterB
 		arg.ServicePrice,
 		arg.AdvancePrice,
 		arg.TotalPrice,
+		arg.CostItemPrice,
+		arg.Number,
 	)
 	var i SubMeterBilling
 	err := row.Scan(
@@ -180,6 +229,45 @@ func (q *Queries) CreateSubMeterBilling(ctx context.Context, arg CreateSubMeterB
 		&i.ServicePrice,
 		&i.AdvancePrice,
 		&i.TotalPrice,
+		&i.CostItemPrice,
+		&i.Number,
+	)
+	return i, err
+}
+
+const createSubMeterBillingCostItem = `-- name: CreateSubMeterBillingCostItem :one
+INSERT INTO sub_meter_billing_cost_item (
+	fk_sub_billing,
+	fk_main_billing_cost_item,
+	share,
+	price
+) VALUES (
+	$1, $2, $3, $4
+)
+RETURNING id, fk_sub_billing, fk_main_billing_cost_item, share, price
+`
+
+type CreateSubMeterBillingCostItemParams struct {
+	FkSubBilling          int32
+	FkMainBillingCostItem int32
+	Share                 float64
+	Price                 float64
+}
+
+func (q *Queries) CreateSubMeterBillingCostItem(ctx context.Context, arg CreateSubMeterBillingCostItemParams) (SubMeterBillingCostItem, error) {
+	row := q.db.QueryRow(ctx, createSubMeterBillingCostItem,
+		arg.FkSubBilling,
+		arg.FkMainBillingCostItem,
+		arg.Share,
+		arg.Price,
+	)
+	var i SubMeterBillingCostItem
+	err := row.Scan(
+		&i.ID,
+		&i.FkSubBilling,
+		&i.FkMainBillingCostItem,
+		&i.Share,
+		&i.Price,
 	)
 	return i, err
 }
@@ -241,7 +329,8 @@ WITH	selected_sub_meter AS (
 )
 SELECT		later_reading.sub_meter_id,
 		sub_meter_reading.reading_value,
-		sub_meter_reading.reading_date
+		sub_meter_reading.reading_date,
+		sub_meter_reading.estimated
 FROM (
 	SELECT		selected_sub_meter.id AS sub_meter_id,
 			min(sub_meter_reading.reading_date) AS reading_date
@@ -257,7 +346,8 @@ ON		later_reading.sub_meter_id = sub_meter_reading.fk_sub_meter AND
 UNION
 SELECT	selected_sub_meter.id AS sub_meter_id,
 	sub_meter_reading.reading_value,
-	sub_meter_reading.reading_date
+	sub_meter_reading.reading_date,
+	sub_meter_reading.estimated
 FROM	selected_sub_meter
 JOIN	sub_meter_reading
 ON	selected_sub_meter.id = sub_meter_reading.fk_sub_meter
@@ -266,7 +356,8 @@ WHERE	sub_meter_reading.reading_date BETWEEN
 UNION
 SELECT		selected_sub_meter.id AS sub_meter_id,
 		sub_meter_reading.reading_value,
-		earlier_reading.reading_date
+		earlier_reading.reading_date,
+		sub_meter_reading.estimated
 FROM 		selected_sub_meter
 LEFT JOIN (
 	SELECT		selected_sub_meter.id AS sub_meter_id,
@@ -294,6 +385,7 @@ type GetSubMeterReadingsRow struct {
 	SubMeterID   int32
 	ReadingValue pgtype.Float8
 	ReadingDate  pgtype.Date
+	Estimated    pgtype.Bool
 }
 
 func (q *Queries) GetSubMeterReadings(ctx context.Context, arg GetSubMeterReadingsParams) ([]GetSubMeterReadingsRow, error) {
@@ -305,7 +397,51 @@ func (q *Queries) GetSubMeterReadings(ctx context.Context, arg GetSubMeterReadin
 	var items []GetSubMeterReadingsRow
 	for rows.Next() {
 		var i GetSubMeterReadingsRow
-		if err := rows.Scan(&i.SubMeterID, &i.ReadingValue, &i.ReadingDate); err != nil {
+		if err := rows.Scan(
+			&i.SubMeterID,
+			&i.ReadingValue,
+			&i.ReadingDate,
+			&i.Estimated,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSubMeterAllocations = `-- name: ListSubMeterAllocations :many
+SELECT id, floor_area, occupants, cost_share
+FROM sub_meter
+WHERE fk_main_meter = $1
+ORDER BY subid
+`
+
+type ListSubMeterAllocationsRow struct {
+	ID        int32
+	FloorArea pgtype.Float8
+	Occupants pgtype.Int4
+	CostShare pgtype.Float8
+}
+
+func (q *Queries) ListSubMeterAllocations(ctx context.Context, fkMainMeter int32) ([]ListSubMeterAllocationsRow, error) {
+	rows, err := q.db.Query(ctx, listSubMeterAllocations, fkMainMeter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSubMeterAllocationsRow
+	for rows.Next() {
+		var i ListSubMeterAllocationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FloorArea,
+			&i.Occupants,
+			&i.CostShare,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
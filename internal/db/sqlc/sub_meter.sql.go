@@ -13,21 +13,31 @@ import (
 
 const createSubMeter = `-- name: CreateSubMeter :one
 INSERT INTO sub_meter (
-	fk_main_meter, subid, meter_id, fk_user
-) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3
+	fk_main_meter, subid, meter_id, fk_user, floor_area, occupants, cost_share
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6
 	FROM sub_meter
 	WHERE fk_main_meter = $1
-RETURNING id, fk_main_meter, subid, meter_id, fk_user
+RETURNING id, fk_main_meter, subid, meter_id, fk_user, floor_area, occupants, cost_share
 `
 
 type CreateSubMeterParams struct {
 	FkMainMeter int32
 	MeterID     pgtype.Text
 	FkUser      int32
+	FloorArea   pgtype.Float8
+	Occupants   pgtype.Int4
+	CostShare   pgtype.Float8
 }
 
 func (q *Queries) CreateSubMeter(ctx context.Context, arg CreateSubMeterParams) (SubMeter, error) {
-	row := q.db.QueryRow(ctx, createSubMeter, arg.FkMainMeter, arg.MeterID, arg.FkUser)
+	row := q.db.QueryRow(ctx, createSubMeter,
+		arg.FkMainMeter,
+		arg.MeterID,
+		arg.FkUser,
+		arg.FloorArea,
+		arg.Occupants,
+		arg.CostShare,
+	)
 	var i SubMeter
 	err := row.Scan(
 		&i.ID,
@@ -35,6 +45,9 @@ func (q *Queries) CreateSubMeter(ctx context.Context, arg CreateSubMeterParams)
 		&i.Subid,
 		&i.MeterID,
 		&i.FkUser,
+		&i.FloorArea,
+		&i.Occupants,
+		&i.CostShare,
 	)
 	return i, err
 }
@@ -45,9 +58,13 @@ SELECT
 	sub_meter.fk_main_meter AS main_meter_id,
 	sub_meter.subid,
 	sub_meter.meter_id AS sub_meter_id,
+	sub_meter.floor_area,
+	sub_meter.occupants,
+	sub_meter.cost_share,
 	sub_meter.fk_user AS sub_user_id,
 	sub_user.email AS sub_user_email,
 	main_meter.address,
+	main_meter.energy,
 	main_meter.fk_user AS main_user_id,
 	main_user.email AS main_user_email
 FROM sub_meter
@@ -71,9 +88,13 @@ type GetSubMeterRow struct {
 	MainMeterID   int32
 	Subid         int32
 	SubMeterID    pgtype.Text
+	FloorArea     pgtype.Float8
+	Occupants     pgtype.Int4
+	CostShare     pgtype.Float8
 	SubUserID     int32
 	SubUserEmail  string
 	Address       string
+	Energy        Energy
 	MainUserID    int32
 	MainUserEmail string
 }
@@ -86,17 +107,66 @@ func (q *Queries) GetSubMeter(ctx context.Context, arg GetSubMeterParams) (GetSu
 		&i.MainMeterID,
 		&i.Subid,
 		&i.SubMeterID,
+		&i.FloorArea,
+		&i.Occupants,
+		&i.CostShare,
 		&i.SubUserID,
 		&i.SubUserEmail,
 		&i.Address,
+		&i.Energy,
 		&i.MainUserID,
 		&i.MainUserEmail,
 	)
 	return i, err
 }
 
+const getSubMeterEnergy = `-- name: GetSubMeterEnergy :one
+SELECT main_meter.energy
+FROM sub_meter
+JOIN main_meter
+	ON sub_meter.fk_main_meter = main_meter.id
+WHERE sub_meter.id = $1
+`
+
+func (q *Queries) GetSubMeterEnergy(ctx context.Context, id int32) (Energy, error) {
+	row := q.db.QueryRow(ctx, getSubMeterEnergy, id)
+	var energy Energy
+	err := row.Scan(&energy)
+	return energy, err
+}
+
+const listSubMeterIDsByMeterID = `-- name: ListSubMeterIDsByMeterID :many
+SELECT id FROM sub_meter
+WHERE fk_main_meter = $1 AND meter_id = $2
+`
+
+type ListSubMeterIDsByMeterIDParams struct {
+	FkMainMeter int32
+	MeterID     pgtype.Text
+}
+
+func (q *Queries) ListSubMeterIDsByMeterID(ctx context.Context, arg ListSubMeterIDsByMeterIDParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listSubMeterIDsByMeterID, arg.FkMainMeter, arg.MeterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listSubMeters = `-- name: ListSubMeters :many
-SELECT subid, meter_id, email
+SELECT sub_meter.id, subid, meter_id, floor_area, occupants, cost_share, email
 FROM sub_meter
 JOIN spinus_user
 	ON sub_meter.fk_user = spinus_user.id
@@ -105,9 +175,13 @@ ORDER BY subid
 `
 
 type ListSubMetersRow struct {
-	Subid   int32
-	MeterID pgtype.Text
-	Email   string
+	ID        int32
+	Subid     int32
+	MeterID   pgtype.Text
+	FloorArea pgtype.Float8
+	Occupants pgtype.Int4
+	CostShare pgtype.Float8
+	Email     string
 }
 
 func (q *Queries) ListSubMeters(ctx context.Context, fkMainMeter int32) ([]ListSubMetersRow, error) {
@@ -119,7 +193,15 @@ func (q *Queries) ListSubMeters(ctx context.Context, fkMainMeter int32) ([]ListS
 	var items []ListSubMetersRow
 	for rows.Next() {
 		var i ListSubMetersRow
-		if err := rows.Scan(&i.Subid, &i.MeterID, &i.Email); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Subid,
+			&i.MeterID,
+			&i.FloorArea,
+			&i.Occupants,
+			&i.CostShare,
+			&i.Email,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
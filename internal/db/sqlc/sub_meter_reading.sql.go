@@ -11,23 +11,174 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const createDeviceReading = `-- name: CreateDeviceReading :one
+INSERT INTO sub_meter_reading (
+	fk_sub_meter,
+	subid,
+	reading_value,
+	reading_date,
+	source,
+	fk_device,
+	reading_time
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6
+	FROM sub_meter_reading
+	WHERE fk_sub_meter = $1
+ON CONFLICT (fk_device, reading_time) DO NOTHING
+RETURNING id, fk_sub_meter, subid, reading_value, reading_date, source, fk_user, created_at, estimated, fk_device, reading_time, client_id
+`
+
+type CreateDeviceReadingParams struct {
+	FkSubMeter   int32
+	ReadingValue float64
+	ReadingDate  pgtype.Date
+	Source       ReadingSource
+	FkDevice     pgtype.Int4
+	ReadingTime  pgtype.Timestamptz
+}
+
+// Reading sent again while the first one is being stored is not inserted, no
+// row is returned then.
+func (q *Queries) CreateDeviceReading(ctx context.Context, arg CreateDeviceReadingParams) (SubMeterReading, error) {
+	row := q.db.QueryRow(ctx, createDeviceReading,
+		arg.FkSubMeter,
+		arg.ReadingValue,
+		arg.ReadingDate,
+		arg.Source,
+		arg.FkDevice,
+		arg.ReadingTime,
+	)
+	var i SubMeterReading
+	err := row.Scan(
+		&i.ID,
+		&i.FkSubMeter,
+		&i.Subid,
+		&i.ReadingValue,
+		&i.ReadingDate,
+		&i.Source,
+		&i.FkUser,
+		&i.CreatedAt,
+		&i.Estimated,
+		&i.FkDevice,
+		&i.ReadingTime,
+		&i.ClientID,
+	)
+	return i, err
+}
+
 const createSubMeterReading = `-- name: CreateSubMeterReading :one
 INSERT INTO sub_meter_reading (
-	fk_sub_meter, subid, reading_value, reading_date
-) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3
+	fk_sub_meter,
+	subid,
+	reading_value,
+	reading_date,
+	source,
+	fk_user,
+	estimated,
+	fk_device,
+	reading_time,
+	client_id
+) SELECT $1, COALESCE(MAX(subid), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9
 	FROM sub_meter_reading
 	WHERE fk_sub_meter = $1
-RETURNING id, fk_sub_meter, subid, reading_value, reading_date
+RETURNING id, fk_sub_meter, subid, reading_value, reading_date, source, fk_user, created_at, estimated, fk_device, reading_time, client_id
 `
 
 type CreateSubMeterReadingParams struct {
 	FkSubMeter   int32
 	ReadingValue float64
 	ReadingDate  pgtype.Date
+	Source       ReadingSource
+	FkUser       pgtype.Int4
+	Estimated    bool
+	FkDevice     pgtype.Int4
+	ReadingTime  pgtype.Timestamptz
+	ClientID     pgtype.Text
 }
 
 func (q *Queries) CreateSubMeterReading(ctx context.Context, arg CreateSubMeterReadingParams) (SubMeterReading, error) {
-	row := q.db.QueryRow(ctx, createSubMeterReading, arg.FkSubMeter, arg.ReadingValue, arg.ReadingDate)
+	row := q.db.QueryRow(ctx, createSubMeterReading,
+		arg.FkSubMeter,
+		arg.ReadingValue,
+		arg.ReadingDate,
+		arg.Source,
+		arg.FkUser,
+		arg.Estimated,
+		arg.FkDevice,
+		arg.ReadingTime,
+		arg.ClientID,
+	)
+	var i SubMeterReading
+	err := row.Scan(
+		&i.ID,
+		&i.FkSubMeter,
+		&i.Subid,
+		&i.ReadingValue,
+		&i.ReadingDate,
+		&i.Source,
+		&i.FkUser,
+		&i.CreatedAt,
+		&i.Estimated,
+		&i.FkDevice,
+		&i.ReadingTime,
+		&i.ClientID,
+	)
+	return i, err
+}
+
+const deleteSubMeterReading = `-- name: DeleteSubMeterReading :exec
+DELETE FROM sub_meter_reading
+WHERE id = $1
+`
+
+func (q *Queries) DeleteSubMeterReading(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteSubMeterReading, id)
+	return err
+}
+
+const getClientReading = `-- name: GetClientReading :one
+SELECT id, fk_sub_meter, subid, reading_value, reading_date, source, fk_user, created_at, estimated, fk_device, reading_time, client_id FROM sub_meter_reading
+WHERE fk_user = $1 AND client_id = $2
+LIMIT 1
+`
+
+type GetClientReadingParams struct {
+	FkUser   pgtype.Int4
+	ClientID pgtype.Text
+}
+
+func (q *Queries) GetClientReading(ctx context.Context, arg GetClientReadingParams) (SubMeterReading, error) {
+	row := q.db.QueryRow(ctx, getClientReading, arg.FkUser, arg.ClientID)
+	var i SubMeterReading
+	err := row.Scan(
+		&i.ID,
+		&i.FkSubMeter,
+		&i.Subid,
+		&i.ReadingValue,
+		&i.ReadingDate,
+		&i.Source,
+		&i.FkUser,
+		&i.CreatedAt,
+		&i.Estimated,
+		&i.FkDevice,
+		&i.ReadingTime,
+		&i.ClientID,
+	)
+	return i, err
+}
+
+const getDeviceReading = `-- name: GetDeviceReading :one
+SELECT id, fk_sub_meter, subid, reading_value, reading_date, source, fk_user, created_at, estimated, fk_device, reading_time, client_id FROM sub_meter_reading
+WHERE fk_device = $1 AND reading_time = $2
+LIMIT 1
+`
+
+type GetDeviceReadingParams struct {
+	FkDevice    pgtype.Int4
+	ReadingTime pgtype.Timestamptz
+}
+
+func (q *Queries) GetDeviceReading(ctx context.Context, arg GetDeviceReadingParams) (SubMeterReading, error) {
+	row := q.db.QueryRow(ctx, getDeviceReading, arg.FkDevice, arg.ReadingTime)
 	var i SubMeterReading
 	err := row.Scan(
 		&i.ID,
@@ -35,12 +186,50 @@ func (q *Queries) CreateSubMeterReading(ctx context.Context, arg CreateSubMeterR
 		&i.Subid,
 		&i.ReadingValue,
 		&i.ReadingDate,
+		&i.Source,
+		&i.FkUser,
+		&i.CreatedAt,
+		&i.Estimated,
+		&i.FkDevice,
+		&i.ReadingTime,
+		&i.ClientID,
+	)
+	return i, err
+}
+
+const getSubMeterReading = `-- name: GetSubMeterReading :one
+SELECT id, fk_sub_meter, subid, reading_value, reading_date, source, fk_user, created_at, estimated, fk_device, reading_time, client_id FROM sub_meter_reading
+WHERE fk_sub_meter = $1 AND subid = $2
+LIMIT 1
+`
+
+type GetSubMeterReadingParams struct {
+	FkSubMeter int32
+	Subid      int32
+}
+
+func (q *Queries) GetSubMeterReading(ctx context.Context, arg GetSubMeterReadingParams) (SubMeterReading, error) {
+	row := q.db.QueryRow(ctx, getSubMeterReading, arg.FkSubMeter, arg.Subid)
+	var i SubMeterReading
+	err := row.Scan(
+		&i.ID,
+		&i.FkSubMeter,
+		&i.Subid,
+		&i.ReadingValue,
+		&i.ReadingDate,
+		&i.Source,
+		&i.FkUser,
+		&i.CreatedAt,
+		&i.Estimated,
+		&i.FkDevice,
+		&i.ReadingTime,
+		&i.ClientID,
 	)
 	return i, err
 }
 
 const getSubMeterReadingForDate = `-- name: GetSubMeterReadingForDate :one
-SELECT 1 FROM sub_meter_reading
+SELECT id, fk_sub_meter, subid, reading_value, reading_date, source, fk_user, created_at, estimated, fk_device, reading_time, client_id FROM sub_meter_reading
 WHERE fk_sub_meter = $1 AND reading_date = $2
 LIMIT 1
 `
@@ -50,15 +239,141 @@ type GetSubMeterReadingForDateParams struct {
 	ReadingDate pgtype.Date
 }
 
-func (q *Queries) GetSubMeterReadingForDate(ctx context.Context, arg GetSubMeterReadingForDateParams) (int32, error) {
+func (q *Queries) GetSubMeterReadingForDate(ctx context.Context, arg GetSubMeterReadingForDateParams) (SubMeterReading, error) {
 	row := q.db.QueryRow(ctx, getSubMeterReadingForDate, arg.FkSubMeter, arg.ReadingDate)
-	var column_1 int32
-	err := row.Scan(&column_1)
-	return column_1, err
+	var i SubMeterReading
+	err := row.Scan(
+		&i.ID,
+		&i.FkSubMeter,
+		&i.Subid,
+		&i.ReadingValue,
+		&i.ReadingDate,
+		&i.Source,
+		&i.FkUser,
+		&i.CreatedAt,
+		&i.Estimated,
+		&i.FkDevice,
+		&i.ReadingTime,
+		&i.ClientID,
+	)
+	return i, err
+}
+
+const listMainMeterReadings = `-- name: ListMainMeterReadings :many
+SELECT
+	sub_meter.subid AS sub_meter_subid,
+	sub_meter.meter_id,
+	sub_meter_reading.subid,
+	sub_meter_reading.reading_value,
+	sub_meter_reading.reading_date,
+	sub_meter_reading.source,
+	sub_meter_reading.estimated
+FROM sub_meter_reading
+JOIN sub_meter
+	ON sub_meter_reading.fk_sub_meter = sub_meter.id
+WHERE sub_meter.fk_main_meter = $1
+ORDER BY sub_meter.subid, sub_meter_reading.reading_date
+`
+
+type ListMainMeterReadingsRow struct {
+	SubMeterSubid int32
+	MeterID       pgtype.Text
+	Subid         int32
+	ReadingValue  float64
+	ReadingDate   pgtype.Date
+	Source        ReadingSource
+	Estimated     bool
+}
+
+func (q *Queries) ListMainMeterReadings(ctx context.Context, fkMainMeter int32) ([]ListMainMeterReadingsRow, error) {
+	rows, err := q.db.Query(ctx, listMainMeterReadings, fkMainMeter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMainMeterReadingsRow
+	for rows.Next() {
+		var i ListMainMeterReadingsRow
+		if err := rows.Scan(
+			&i.SubMeterSubid,
+			&i.MeterID,
+			&i.Subid,
+			&i.ReadingValue,
+			&i.ReadingDate,
+			&i.Source,
+			&i.Estimated,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSubMeterLastReadings = `-- name: ListSubMeterLastReadings :many
+SELECT
+	sub_meter.id,
+	sub_meter.subid,
+	sub_meter.meter_id,
+	last_reading.reading_date,
+	last_reading.reading_value
+FROM sub_meter
+LEFT JOIN LATERAL (
+	SELECT reading_date, reading_value
+	FROM sub_meter_reading
+	WHERE	fk_sub_meter = sub_meter.id AND
+		reading_date < $1
+	ORDER BY reading_date DESC
+	LIMIT 1
+) AS last_reading ON true
+WHERE sub_meter.fk_main_meter = $2
+ORDER BY sub_meter.subid
+`
+
+type ListSubMeterLastReadingsParams struct {
+	BeforeDate  pgtype.Date
+	FkMainMeter int32
+}
+
+type ListSubMeterLastReadingsRow struct {
+	ID           int32
+	Subid        int32
+	MeterID      pgtype.Text
+	ReadingDate  pgtype.Date
+	ReadingValue pgtype.Float8
+}
+
+func (q *Queries) ListSubMeterLastReadings(ctx context.Context, arg ListSubMeterLastReadingsParams) ([]ListSubMeterLastReadingsRow, error) {
+	rows, err := q.db.Query(ctx, listSubMeterLastReadings, arg.BeforeDate, arg.FkMainMeter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSubMeterLastReadingsRow
+	for rows.Next() {
+		var i ListSubMeterLastReadingsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Subid,
+			&i.MeterID,
+			&i.ReadingDate,
+			&i.ReadingValue,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const listSubMeterReadings = `-- name: ListSubMeterReadings :many
-SELECT id, fk_sub_meter, subid, reading_value, reading_date FROM sub_meter_reading
+SELECT id, fk_sub_meter, subid, reading_value, reading_date, source, fk_user, created_at, estimated, fk_device, reading_time, client_id FROM sub_meter_reading
 WHERE fk_sub_meter = $1
 ORDER BY reading_date DESC
 `
@@ -78,6 +393,13 @@ func (q *Queries) ListSubMeterReadings(ctx context.Context, fkSubMeter int32) ([
 			&i.Subid,
 			&i.ReadingValue,
 			&i.ReadingDate,
+			&i.Source,
+			&i.FkUser,
+			&i.CreatedAt,
+			&i.Estimated,
+			&i.FkDevice,
+			&i.ReadingTime,
+			&i.ClientID,
 		); err != nil {
 			return nil, err
 		}
@@ -88,3 +410,168 @@ func (q *Queries) ListSubMeterReadings(ctx context.Context, fkSubMeter int32) ([
 	}
 	return items, nil
 }
+
+const listSubMeterReadingsBetween = `-- name: ListSubMeterReadingsBetween :many
+SELECT id, fk_sub_meter, subid, reading_value, reading_date, source, fk_user, created_at, estimated, fk_device, reading_time, client_id FROM sub_meter_reading
+WHERE	fk_sub_meter = $1 AND
+	reading_date BETWEEN $2 AND $3
+ORDER BY reading_date
+`
+
+type ListSubMeterReadingsBetweenParams struct {
+	FkSubMeter int32
+	DateMin    pgtype.Date
+	DateMax    pgtype.Date
+}
+
+func (q *Queries) ListSubMeterReadingsBetween(ctx context.Context, arg ListSubMeterReadingsBetweenParams) ([]SubMeterReading, error) {
+	rows, err := q.db.Query(ctx, listSubMeterReadingsBetween, arg.FkSubMeter, arg.DateMin, arg.DateMax)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SubMeterReading
+	for rows.Next() {
+		var i SubMeterReading
+		if err := rows.Scan(
+			&i.ID,
+			&i.FkSubMeter,
+			&i.Subid,
+			&i.ReadingValue,
+			&i.ReadingDate,
+			&i.Source,
+			&i.FkUser,
+			&i.CreatedAt,
+			&i.Estimated,
+			&i.FkDevice,
+			&i.ReadingTime,
+			&i.ClientID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSubMeterReadingsWithUser = `-- name: ListSubMeterReadingsWithUser :many
+SELECT
+	sub_meter_reading.id,
+	sub_meter_reading.fk_sub_meter,
+	sub_meter_reading.subid,
+	sub_meter_reading.reading_value,
+	sub_meter_reading.reading_date,
+	sub_meter_reading.source,
+	sub_meter_reading.fk_user,
+	sub_meter_reading.created_at,
+	sub_meter_reading.estimated,
+	spinus_user.email AS user_email,
+	device.name AS device_name
+FROM sub_meter_reading
+LEFT JOIN spinus_user
+	ON sub_meter_reading.fk_user = spinus_user.id
+LEFT JOIN device
+	ON sub_meter_reading.fk_device = device.id
+WHERE sub_meter_reading.fk_sub_meter = $1
+ORDER BY sub_meter_reading.reading_date DESC
+`
+
+type ListSubMeterReadingsWithUserRow struct {
+	ID           int32
+	FkSubMeter   int32
+	Subid        int32
+	ReadingValue float64
+	ReadingDate  pgtype.Date
+	Source       ReadingSource
+	FkUser       pgtype.Int4
+	CreatedAt    pgtype.Timestamptz
+	Estimated    bool
+	UserEmail    pgtype.Text
+	DeviceName   pgtype.Text
+}
+
+func (q *Queries) ListSubMeterReadingsWithUser(ctx context.Context, fkSubMeter int32) ([]ListSubMeterReadingsWithUserRow, error) {
+	rows, err := q.db.Query(ctx, listSubMeterReadingsWithUser, fkSubMeter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSubMeterReadingsWithUserRow
+	for rows.Next() {
+		var i ListSubMeterReadingsWithUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FkSubMeter,
+			&i.Subid,
+			&i.ReadingValue,
+			&i.ReadingDate,
+			&i.Source,
+			&i.FkUser,
+			&i.CreatedAt,
+			&i.Estimated,
+			&i.UserEmail,
+			&i.DeviceName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateSubMeterReadingValue = `-- name: UpdateSubMeterReadingValue :one
+UPDATE sub_meter_reading SET
+	reading_value = $2,
+	source = $3,
+	fk_user = $4,
+	estimated = $5,
+	fk_device = NULL,
+	reading_time = $6,
+	client_id = $7
+WHERE id = $1
+RETURNING id, fk_sub_meter, subid, reading_value, reading_date, source, fk_user, created_at, estimated, fk_device, reading_time, client_id
+`
+
+type UpdateSubMeterReadingValueParams struct {
+	ID           int32
+	ReadingValue float64
+	Source       ReadingSource
+	FkUser       pgtype.Int4
+	Estimated    bool
+	ReadingTime  pgtype.Timestamptz
+	ClientID     pgtype.Text
+}
+
+func (q *Queries) UpdateSubMeterReadingValue(ctx context.Context, arg UpdateSubMeterReadingValueParams) (SubMeterReading, error) {
+	row := q.db.QueryRow(ctx, updateSubMeterReadingValue,
+		arg.ID,
+		arg.ReadingValue,
+		arg.Source,
+		arg.FkUser,
+		arg.Estimated,
+		arg.ReadingTime,
+		arg.ClientID,
+	)
+	var i SubMeterReading
+	err := row.Scan(
+		&i.ID,
+		&i.FkSubMeter,
+		&i.Subid,
+		&i.ReadingValue,
+		&i.ReadingDate,
+		&i.Source,
+		&i.FkUser,
+		&i.CreatedAt,
+		&i.Estimated,
+		&i.FkDevice,
+		&i.ReadingTime,
+		&i.ClientID,
+	)
+	return i, err
+}
@@ -11,33 +11,34 @@ import (
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO spinus_user (
-	username, email, password
+	username, email, password, email_verified
 ) VALUES (
-	$1, $2, crypt($3, gen_salt('bf'))
+	$1, $2, $3, TRUE
 )
-RETURNING id, username, email, password
+RETURNING id, username, email, password, email_verified
 `
 
 type CreateUserParams struct {
 	Username string
 	Email    string
-	Crypt    string
+	Password string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (SpinusUser, error) {
-	row := q.db.QueryRow(ctx, createUser, arg.Username, arg.Email, arg.Crypt)
+	row := q.db.QueryRow(ctx, createUser, arg.Username, arg.Email, arg.Password)
 	var i SpinusUser
 	err := row.Scan(
 		&i.ID,
 		&i.Username,
 		&i.Email,
 		&i.Password,
+		&i.EmailVerified,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, username, email, password FROM spinus_user
+SELECT id, username, email, password, email_verified FROM spinus_user
 WHERE username = $1 AND password = crypt($2, password)
 LIMIT 1
 `
@@ -55,21 +56,47 @@ func (q *Queries) GetUser(ctx context.Context, arg GetUserParams) (SpinusUser, e
 		&i.Username,
 		&i.Email,
 		&i.Password,
+		&i.EmailVerified,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT 1 FROM spinus_user
+SELECT id, username, email, password, email_verified FROM spinus_user
 WHERE email = $1
 LIMIT 1
 `
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (int32, error) {
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (SpinusUser, error) {
 	row := q.db.QueryRow(ctx, getUserByEmail, email)
-	var column_1 int32
-	err := row.Scan(&column_1)
-	return column_1, err
+	var i SpinusUser
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.Password,
+		&i.EmailVerified,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, username, email, password, email_verified FROM spinus_user
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id int32) (SpinusUser, error) {
+	row := q.db.QueryRow(ctx, getUserByID, id)
+	var i SpinusUser
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.Password,
+		&i.EmailVerified,
+	)
+	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
@@ -84,3 +111,44 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (int32
 	err := row.Scan(&column_1)
 	return column_1, err
 }
+
+const hashPassword = `-- name: HashPassword :one
+SELECT crypt($1::TEXT, gen_salt('bf'))::TEXT AS password_hash
+`
+
+func (q *Queries) HashPassword(ctx context.Context, password string) (string, error) {
+	row := q.db.QueryRow(ctx, hashPassword, password)
+	var password_hash string
+	err := row.Scan(&password_hash)
+	return password_hash, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE spinus_user SET password = crypt($2, gen_salt('bf'))
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID    int32
+	Crypt string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.ID, arg.Crypt)
+	return err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :exec
+UPDATE spinus_user SET email_verified = TRUE
+WHERE id = $1 AND email = $2
+`
+
+type VerifyUserEmailParams struct {
+	ID    int32
+	Email string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) error {
+	_, err := q.db.Exec(ctx, verifyUserEmail, arg.ID, arg.Email)
+	return err
+}
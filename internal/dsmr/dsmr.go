@@ -0,0 +1,333 @@
+// Package dsmr parses telegrams sent by the P1 port of Dutch and Belgian smart
+// meters following DSMR 4 and 5 and e-MUCS.
+package dsmr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OBIS codes of objects read into Telegram fields.
+const (
+	obisVersion        = "1-3:0.2.8"
+	obisVersionBelgium = "0-0:96.1.4"
+	obisTime           = "0-0:1.0.0"
+	obisEquipmentID    = "0-0:96.1.1"
+	obisImportTariff1  = "1-0:1.8.1"
+	obisImportTariff2  = "1-0:1.8.2"
+	obisExportTariff1  = "1-0:2.8.1"
+	obisExportTariff2  = "1-0:2.8.2"
+)
+
+// M-Bus device types of meters connected to the electricity meter.
+const (
+	DeviceTypeGas   = 3
+	DeviceTypeWater = 7
+)
+
+var (
+	// Timestamps are in local time of the Netherlands and Belgium, with daylight
+	// saving time marked by the last letter.
+	winterTime = time.FixedZone("CET", 1*60*60)
+	summerTime = time.FixedZone("CEST", 2*60*60)
+)
+
+// MBusReading is the last value of a gas, water or heat meter connected to
+// the electricity meter on an M-Bus channel.
+type MBusReading struct {
+	Channel     int
+	DeviceType  int
+	EquipmentID string
+	Time        time.Time
+	Value       float64
+	Unit        string
+}
+
+type Telegram struct {
+	// Header identifies the manufacturer and meter type.
+	Header      string
+	Version     string
+	Time        time.Time
+	EquipmentID string
+	// Electricity registers in kWh.
+	ImportTariff1 float64
+	ImportTariff2 float64
+	ExportTariff1 float64
+	ExportTariff2 float64
+	MBus          []MBusReading
+	// Objects holds values of all objects by OBIS code.
+	Objects map[string][]string
+}
+
+// Import returns electricity delivered to the client in both tariffs.
+func (t *Telegram) Import() float64 { return t.ImportTariff1 + t.ImportTariff2 }
+
+// Export returns electricity delivered by the client in both tariffs.
+func (t *Telegram) Export() float64 { return t.ExportTariff1 + t.ExportTariff2 }
+
+// FindMBus returns reading of the first M-Bus device of the given type.
+func (t *Telegram) FindMBus(deviceType int) (MBusReading, bool) {
+	for _, reading := range t.MBus {
+		if reading.DeviceType == deviceType {
+			return reading, true
+		}
+	}
+	return MBusReading{}, false
+}
+
+// Split splits data read from the P1 port to telegrams. Incomplete telegram at
+// the end is returned as rest. At EOF, the last telegram needs no line end
+// after its CRC.
+func Split(data []byte, atEOF bool) (telegrams [][]byte, rest []byte) {
+	for {
+		begin := bytes.IndexByte(data, '/')
+		if begin == -1 {
+			return telegrams, nil
+		}
+		data = data[begin:]
+		end := bytes.IndexByte(data, '!')
+		if end == -1 {
+			return telegrams, data
+		}
+		// CRC follows the end mark on the same line.
+		lineEnd := bytes.IndexByte(data[end:], '\n')
+		if lineEnd == -1 {
+			if atEOF && isCRC(bytes.TrimSpace(data[end+1:])) {
+				return append(telegrams, data), nil
+			}
+			return telegrams, data
+		}
+		end += lineEnd + 1
+		telegrams = append(telegrams, data[:end])
+		data = data[end:]
+	}
+}
+
+func isCRC(b []byte) bool {
+	if len(b) != 4 {
+		return false
+	}
+	_, err := hex.DecodeString(string(b))
+	return err == nil
+}
+
+// Parse parses a single telegram and validates its CRC.
+func Parse(data []byte) (*Telegram, error) {
+	begin := bytes.IndexByte(data, '/')
+	if begin == -1 {
+		return nil, errors.New("no telegram start")
+	}
+	data = data[begin:]
+	end := bytes.IndexByte(data, '!')
+	if end == -1 {
+		return nil, errors.New("no telegram end")
+	}
+	crcHex := string(bytes.TrimSpace(data[end+1:]))
+	if crcHex == "" {
+		return nil, errors.New("no CRC, only DSMR 4 and newer is supported")
+	}
+	expectedCRC, err := strconv.ParseUint(crcHex, 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRC %q", crcHex)
+	}
+	if crc := crc16(data[:end+1]); crc != uint16(expectedCRC) {
+		return nil, fmt.Errorf("CRC %04X does not match %04X", crc, expectedCRC)
+	}
+
+	lines := strings.Split(string(data[1:end]), "\n")
+	t := &Telegram{
+		Header:  strings.TrimSpace(lines[0]),
+		Objects: make(map[string][]string),
+	}
+	for i, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		obis, values, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+		t.Objects[obis] = values
+	}
+	if err := t.readObjects(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseLine splits line like 1-0:1.8.1(001234.567*kWh) to OBIS code and
+// values in brackets.
+func parseLine(line string) (string, []string, error) {
+	obis, rest, ok := strings.Cut(line, "(")
+	if !ok || obis == "" {
+		return "", nil, fmt.Errorf("invalid object %q", line)
+	}
+	var values []string
+	rest = "(" + rest
+	for rest != "" {
+		if rest[0] != '(' {
+			return "", nil, fmt.Errorf("invalid object %q", line)
+		}
+		value, after, ok := strings.Cut(rest[1:], ")")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid object %q", line)
+		}
+		values = append(values, value)
+		rest = after
+	}
+	return obis, values, nil
+}
+
+func (t *Telegram) readObjects() error {
+	if values, ok := t.Objects[obisVersion]; ok {
+		t.Version = values[0]
+	} else if values, ok := t.Objects[obisVersionBelgium]; ok {
+		t.Version = values[0]
+	}
+	if values, ok := t.Objects[obisTime]; ok {
+		v, err := parseTime(values[0])
+		if err != nil {
+			return err
+		}
+		t.Time = v
+	}
+	if values, ok := t.Objects[obisEquipmentID]; ok {
+		t.EquipmentID = decodeEquipmentID(values[0])
+	}
+
+	registers := []struct {
+		obis  string
+		value *float64
+	}{
+		{obisImportTariff1, &t.ImportTariff1},
+		{obisImportTariff2, &t.ImportTariff2},
+		{obisExportTariff1, &t.ExportTariff1},
+		{obisExportTariff2, &t.ExportTariff2},
+	}
+	for _, register := range registers {
+		values, ok := t.Objects[register.obis]
+		if !ok {
+			continue
+		}
+		v, unit, err := parseValue(values[0])
+		if err != nil {
+			return fmt.Errorf("%s: %w", register.obis, err)
+		}
+		switch unit {
+		case "kWh":
+		case "Wh":
+			v /= 1000
+		default:
+			return fmt.Errorf("%s: unknown unit %q", register.obis, unit)
+		}
+		*register.value = v
+	}
+
+	// M-Bus devices use channels 1 to 4.
+	for channel := 1; channel <= 4; channel++ {
+		reading, ok, err := t.readMBus(channel)
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.MBus = append(t.MBus, reading)
+		}
+	}
+	return nil
+}
+
+func (t *Telegram) readMBus(channel int) (MBusReading, bool, error) {
+	reading := MBusReading{Channel: channel}
+	prefix := fmt.Sprintf("0-%d:", channel)
+	values, ok := t.Objects[prefix+"24.1.0"]
+	if !ok {
+		return reading, false, nil
+	}
+	deviceType, err := strconv.Atoi(values[0])
+	if err != nil {
+		return reading, false, fmt.Errorf("%s24.1.0: invalid device type", prefix)
+	}
+	reading.DeviceType = deviceType
+	if values, ok := t.Objects[prefix+"96.1.0"]; ok {
+		reading.EquipmentID = decodeEquipmentID(values[0])
+	}
+	// Belgian meters send temperature corrected gas value.
+	values, ok = t.Objects[prefix+"24.2.1"]
+	if !ok {
+		values, ok = t.Objects[prefix+"24.2.3"]
+	}
+	if !ok || len(values) < 2 {
+		return reading, false, nil
+	}
+	reading.Time, err = parseTime(values[0])
+	if err != nil {
+		return reading, false, err
+	}
+	reading.Value, reading.Unit, err = parseValue(values[1])
+	if err != nil {
+		return reading, false, fmt.Errorf("%s24.2.1: %w", prefix, err)
+	}
+	return reading, true, nil
+}
+
+// parseTime parses timestamp like 101209113020W, where the last letter is S
+// in summer and W in winter.
+func parseTime(s string) (time.Time, error) {
+	if len(s) != 13 {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	var location *time.Location
+	switch s[12] {
+	case 'S':
+		location = summerTime
+	case 'W':
+		location = winterTime
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	t, err := time.ParseInLocation("060102150405", s[:12], location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	return t, nil
+}
+
+func parseValue(s string) (float64, string, error) {
+	number, unit, _ := strings.Cut(s, "*")
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid value %q", s)
+	}
+	return v, unit, nil
+}
+
+// Equipment identifiers are sent hex encoded.
+func decodeEquipmentID(s string) string {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
+
+// crc16 computes CRC-16/ARC of telegram from the start to the end mark.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,194 @@
+package dsmr
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleTelegram is the DSMR 5 sample telegram of ISKRA AM550 used by the
+// dsmr_parser project.
+const sampleTelegram = "/ISk5\\2MT382-1000\r\n" +
+	"\r\n" +
+	"1-3:0.2.8(50)\r\n" +
+	"0-0:1.0.0(170102192002W)\r\n" +
+	"0-0:96.1.1(4B384547303034303436333935353037)\r\n" +
+	"1-0:1.8.1(000004.426*kWh)\r\n" +
+	"1-0:1.8.2(000002.399*kWh)\r\n" +
+	"1-0:2.8.1(000002.444*kWh)\r\n" +
+	"1-0:2.8.2(000000.000*kWh)\r\n" +
+	"0-0:96.14.0(0002)\r\n" +
+	"1-0:1.7.0(00.244*kW)\r\n" +
+	"1-0:2.7.0(00.000*kW)\r\n" +
+	"0-0:96.7.21(00013)\r\n" +
+	"0-0:96.7.9(00000)\r\n" +
+	"1-0:99.97.0(0)(0-0:96.7.19)\r\n" +
+	"1-0:32.32.0(00000)\r\n" +
+	"1-0:52.32.0(00000)\r\n" +
+	"1-0:72.32.0(00000)\r\n" +
+	"1-0:32.36.0(00000)\r\n" +
+	"1-0:52.36.0(00000)\r\n" +
+	"1-0:72.36.0(00000)\r\n" +
+	"0-0:96.13.0()\r\n" +
+	"1-0:32.7.0(0230.0*V)\r\n" +
+	"1-0:52.7.0(0230.0*V)\r\n" +
+	"1-0:72.7.0(0229.0*V)\r\n" +
+	"1-0:31.7.0(0.48*A)\r\n" +
+	"1-0:51.7.0(0.44*A)\r\n" +
+	"1-0:71.7.0(0.86*A)\r\n" +
+	"1-0:21.7.0(00.070*kW)\r\n" +
+	"1-0:41.7.0(00.032*kW)\r\n" +
+	"1-0:61.7.0(00.142*kW)\r\n" +
+	"1-0:22.7.0(00.000*kW)\r\n" +
+	"1-0:42.7.0(00.000*kW)\r\n" +
+	"1-0:62.7.0(00.000*kW)\r\n" +
+	"0-1:24.1.0(003)\r\n" +
+	"0-1:96.1.0(3232323241424344313233343536373839)\r\n" +
+	"0-1:24.2.1(170102161005W)(00000.107*m3)\r\n" +
+	"0-2:24.1.0(003)\r\n" +
+	"0-2:96.1.0()\r\n" +
+	"!6EEE\r\n"
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want uint16
+	}{
+		{"check value", "123456789", 0xBB3D},
+		{"sample telegram", sampleTelegram[:strings.IndexByte(sampleTelegram, '!')+1], 0x6EEE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := crc16([]byte(tt.data)); got != tt.want {
+				t.Errorf("crc16() = %04X, want %04X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	telegram, err := Parse([]byte(sampleTelegram))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if telegram.Header != `ISk5\2MT382-1000` {
+		t.Errorf("Header = %q", telegram.Header)
+	}
+	if telegram.Version != "50" {
+		t.Errorf("Version = %q", telegram.Version)
+	}
+	if want := time.Date(2017, 1, 2, 19, 20, 2, 0, winterTime); !telegram.Time.Equal(want) {
+		t.Errorf("Time = %v, want %v", telegram.Time, want)
+	}
+	if telegram.EquipmentID != "K8EG004046395507" {
+		t.Errorf("EquipmentID = %q", telegram.EquipmentID)
+	}
+	registers := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"ImportTariff1", telegram.ImportTariff1, 4.426},
+		{"ImportTariff2", telegram.ImportTariff2, 2.399},
+		{"ExportTariff1", telegram.ExportTariff1, 2.444},
+		{"ExportTariff2", telegram.ExportTariff2, 0},
+	}
+	for _, register := range registers {
+		if register.got != register.want {
+			t.Errorf("%s = %v, want %v", register.name, register.got, register.want)
+		}
+	}
+
+	// Channel 2 has no reading and is left out.
+	if len(telegram.MBus) != 1 {
+		t.Fatalf("len(MBus) = %d, want 1", len(telegram.MBus))
+	}
+	gas, ok := telegram.FindMBus(DeviceTypeGas)
+	if !ok {
+		t.Fatal("FindMBus() found no gas meter")
+	}
+	want := MBusReading{
+		Channel:     1,
+		DeviceType:  DeviceTypeGas,
+		EquipmentID: "2222ABCD123456789",
+		Time:        time.Date(2017, 1, 2, 16, 10, 5, 0, winterTime),
+		Value:       0.107,
+		Unit:        "m3",
+	}
+	if gas != want {
+		t.Errorf("FindMBus() = %+v, want %+v", gas, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name     string
+		telegram string
+		err      string
+	}{
+		{
+			"changed value",
+			strings.Replace(sampleTelegram, "000004.426", "000004.427", 1),
+			"CRC",
+		},
+		{
+			"changed CRC",
+			strings.Replace(sampleTelegram, "!6EEE", "!6EEF", 1),
+			"CRC",
+		},
+		{
+			"no CRC",
+			strings.Replace(sampleTelegram, "!6EEE", "!", 1),
+			"no CRC",
+		},
+		{
+			"no end",
+			sampleTelegram[:strings.IndexByte(sampleTelegram, '!')],
+			"no telegram end",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.telegram))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	withoutLineEnd := strings.TrimSuffix(sampleTelegram, "\r\n")
+	tests := []struct {
+		name      string
+		data      string
+		atEOF     bool
+		telegrams int
+		rest      string
+	}{
+		{"one", sampleTelegram, false, 1, ""},
+		{"two with garbage", "garbage" + sampleTelegram + sampleTelegram, false, 2, ""},
+		{"incomplete", sampleTelegram + sampleTelegram[:100], false, 1, sampleTelegram[:100]},
+		{"without line end", withoutLineEnd, false, 0, withoutLineEnd},
+		{"without line end at EOF", withoutLineEnd, true, 1, ""},
+		{"without CRC at EOF", sampleTelegram[:strings.IndexByte(sampleTelegram, '!')+1], true, 0,
+			sampleTelegram[:strings.IndexByte(sampleTelegram, '!')+1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telegrams, rest := Split([]byte(tt.data), tt.atEOF)
+			if len(telegrams) != tt.telegrams {
+				t.Fatalf("len(telegrams) = %d, want %d", len(telegrams), tt.telegrams)
+			}
+			if string(rest) != tt.rest {
+				t.Errorf("rest = %q, want %q", rest, tt.rest)
+			}
+			for _, telegram := range telegrams {
+				if _, err := Parse(telegram); err != nil {
+					t.Errorf("Parse() error = %v", err)
+				}
+			}
+		})
+	}
+}
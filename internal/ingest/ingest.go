@@ -0,0 +1,19 @@
+// Package ingest has what subsystems reading meters automatically share.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrRejected is returned by StoreFunc for readings that are not valid, they
+// are not retried until the next day.
+var ErrRejected = errors.New("reading rejected")
+
+// StoreFunc stores reading of sub meter of main meter with database ID
+// mainMeterID, identified by meter identification. It reports whether it was
+// stored or there already is a reading for the day.
+type StoreFunc func(
+	ctx context.Context, mainMeterID int32, meterID string, value float64, t time.Time,
+) (bool, error)
@@ -0,0 +1,152 @@
+// Package isdoc parses Czech electronic invoices in the ISDOC format.
+package isdoc
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxInvoiceSize = 10 << 20
+
+var ErrNotInvoice = errors.New("not an ISDOC invoice")
+
+type Invoice struct {
+	XMLName   xml.Name
+	ID        string   `xml:"ID"`
+	IssueDate string   `xml:"IssueDate"`
+	Notes     []string `xml:"Note"`
+	Currency  string   `xml:"LocalCurrencyCode"`
+	Lines     []Line   `xml:"InvoiceLines>InvoiceLine"`
+}
+
+type Quantity struct {
+	Value    float64 `xml:",chardata"`
+	UnitCode string  `xml:"unitCode,attr"`
+}
+
+type Line struct {
+	ID                 string    `xml:"ID"`
+	Quantity           *Quantity `xml:"InvoicedQuantity"`
+	Amount             float64   `xml:"LineExtensionAmount"`
+	AmountTaxInclusive *float64  `xml:"LineExtensionAmountTaxInclusive"`
+	Notes              []string  `xml:"Note"`
+	Description        string    `xml:"Item>Description"`
+}
+
+// Price includes tax if the invoice states it.
+func (l Line) Price() float64 {
+	if l.AmountTaxInclusive != nil {
+		return *l.AmountTaxInclusive
+	}
+	return l.Amount
+}
+
+func (l Line) Text() string {
+	return strings.Join(append([]string{l.Description}, l.Notes...), " ")
+}
+
+func (l Line) Period() (Period, bool) { return findPeriod(l.Text()) }
+
+// Period of the whole invoice is searched for in its notes.
+func (inv Invoice) Period() (Period, bool) {
+	return findPeriod(strings.Join(inv.Notes, " "))
+}
+
+type Period struct {
+	Begin time.Time
+	End   time.Time
+}
+
+// ISDOC has no element for billing periods, suppliers put them in texts
+// like "1. 1. 2024 - 31. 3. 2024" or "2024-01-01 - 2024-03-31".
+var dateRe = regexp.MustCompile(
+	`\b(?:(\d{1,2})\.\s?(\d{1,2})\.\s?(\d{4})|(\d{4})-(\d{2})-(\d{2}))\b`)
+
+func findPeriod(s string) (Period, bool) {
+	var dates []time.Time
+	for _, match := range dateRe.FindAllStringSubmatch(s, -1) {
+		day, month, year := match[1], match[2], match[3]
+		if match[4] != "" {
+			year, month, day = match[4], match[5], match[6]
+		}
+		d, err := time.Parse(
+			"2006-1-2", strings.Join([]string{year, month, day}, "-"))
+		if err != nil {
+			continue
+		}
+		dates = append(dates, d)
+		if len(dates) == 2 {
+			break
+		}
+	}
+	if len(dates) != 2 || dates[1].Before(dates[0]) {
+		return Period{}, false
+	}
+	return Period{Begin: dates[0], End: dates[1]}, true
+}
+
+// Parse reads invoice from ISDOC XML or from ISDOCX archive containing it.
+func Parse(r io.Reader) (*Invoice, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxInvoiceSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read invoice: %w", err)
+	}
+	if len(data) > maxInvoiceSize {
+		return nil, errors.New("invoice is too large")
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		if data, err = readArchive(data); err != nil {
+			return nil, err
+		}
+	}
+	var invoice Invoice
+	if err := xml.Unmarshal(data, &invoice); err != nil {
+		return nil, fmt.Errorf("could not parse invoice: %w", err)
+	}
+	if invoice.XMLName.Local != "Invoice" {
+		return nil, ErrNotInvoice
+	}
+	return &invoice, nil
+}
+
+func readArchive(data []byte) ([]byte, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("could not open invoice archive: %w", err)
+	}
+	for _, file := range zipReader.File {
+		if !strings.EqualFold(path.Ext(file.Name), ".isdoc") {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("could not open invoice: %w", err)
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxInvoiceSize))
+		if err != nil {
+			return nil, fmt.Errorf("could not read invoice: %w", err)
+		}
+		return data, nil
+	}
+	return nil, ErrNotInvoice
+}
+
+// Unit is the lower cased unit code with exponents written as digits, eg. "m3".
+func (q Quantity) Unit() string {
+	return strings.NewReplacer("³", "3", "^", "").Replace(
+		strings.ToLower(strings.TrimSpace(q.UnitCode)))
+}
+
+func (q Quantity) String() string {
+	return strconv.FormatFloat(q.Value, 'f', -1, 64) + " " + q.UnitCode
+}
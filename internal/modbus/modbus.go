@@ -0,0 +1,260 @@
+// Package modbus polls energy registers of meters over Modbus TCP and stores
+// them as daily readings.
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/goburrow/modbus"
+	"github.com/svoboond/spinus/internal/ingest"
+)
+
+const (
+	defaultInterval = 15 * time.Minute
+	defaultTimeout  = 5 * time.Second
+	storeTimeout    = 10 * time.Second
+)
+
+var metrics = expvar.NewMap("modbus")
+
+// Number of 16-bit registers of data types.
+var registerCounts = map[string]uint16{
+	"uint16":  1,
+	"int16":   1,
+	"uint32":  2,
+	"int32":   2,
+	"float32": 2,
+	"uint64":  4,
+	"int64":   4,
+	"float64": 4,
+}
+
+// Meter is energy register of sub meter of main meter with database ID
+// MainMeterID, with meter identification MeterID.
+type Meter struct {
+	MainMeterID int32
+	MeterID     string
+	// Address is host and port of the meter or Modbus gateway.
+	Address  string
+	UnitID   byte
+	Register uint16
+	// RegisterType is holding or input.
+	RegisterType string
+	DataType     string
+	// Registers of 32 and 64-bit values are in big endian order, unless
+	// WordOrder is little.
+	WordOrder string
+	// Scale converts register value to kWh, like 0.01 for register in 10 Wh.
+	Scale float64
+}
+
+type Options struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	Meters   []Meter
+}
+
+// Poller reads meters every interval until it stores their reading for the
+// day, failed reads are retried in the next interval.
+type Poller struct {
+	options  Options
+	store    ingest.StoreFunc
+	lastDays map[string]string
+	cancel   context.CancelFunc
+	done     sync.WaitGroup
+}
+
+func NewPoller(options Options, store ingest.StoreFunc) (*Poller, error) {
+	if len(options.Meters) == 0 {
+		return nil, errors.New("no meters")
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaultInterval
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultTimeout
+	}
+	for i := range options.Meters {
+		meter := &options.Meters[i]
+		if meter.MainMeterID <= 0 || meter.MeterID == "" || meter.Address == "" {
+			return nil, fmt.Errorf(
+				"meter %d without main meter ID, meter ID or address", i+1)
+		}
+		switch meter.RegisterType {
+		case "":
+			meter.RegisterType = "holding"
+		case "holding", "input":
+		default:
+			return nil, fmt.Errorf(
+				"meter %s: invalid register type %q", meter.MeterID, meter.RegisterType)
+		}
+		if _, ok := registerCounts[meter.DataType]; !ok {
+			return nil, fmt.Errorf(
+				"meter %s: invalid data type %q", meter.MeterID, meter.DataType)
+		}
+		switch meter.WordOrder {
+		case "":
+			meter.WordOrder = "big"
+		case "big", "little":
+		default:
+			return nil, fmt.Errorf(
+				"meter %s: invalid word order %q", meter.MeterID, meter.WordOrder)
+		}
+		if meter.Scale == 0 {
+			meter.Scale = 1
+		}
+	}
+	return &Poller{
+		options:  options,
+		store:    store,
+		lastDays: make(map[string]string),
+	}, nil
+}
+
+// Start polls meters in background until Stop is called.
+func (p *Poller) Start() {
+	slog.Info(
+		"modbus poller starting",
+		"meters", len(p.options.Meters), "interval", p.options.Interval)
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(p.options.Interval)
+		defer ticker.Stop()
+		for {
+			p.poll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *Poller) Stop() {
+	p.cancel()
+	p.done.Wait()
+	slog.Info("modbus poller stopped")
+}
+
+func (p *Poller) poll(ctx context.Context) {
+	metrics.Add("polls", 1)
+	for _, meter := range p.options.Meters {
+		if ctx.Err() != nil {
+			return
+		}
+		now := time.Now()
+		day := now.Format(time.DateOnly)
+		meterKey := fmt.Sprintf("%d/%s", meter.MainMeterID, meter.MeterID)
+		if p.lastDays[meterKey] == day {
+			continue
+		}
+		value, err := p.read(meter)
+		if err != nil {
+			metrics.Add("read_errors", 1)
+			slog.Warn(
+				"error reading modbus meter",
+				"meterID", meter.MeterID, "address", meter.Address, "err", err)
+			continue
+		}
+
+		storeCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+		stored, err := p.store(storeCtx, meter.MainMeterID, meter.MeterID, value, now)
+		cancel()
+		if errors.Is(err, ingest.ErrRejected) {
+			metrics.Add("rejected_readings", 1)
+			slog.Warn(
+				"modbus reading rejected",
+				"mainMeterID", meter.MainMeterID, "meterID", meter.MeterID, "err", err)
+		} else if err != nil {
+			metrics.Add("store_errors", 1)
+			slog.Error(
+				"error storing modbus reading",
+				"mainMeterID", meter.MainMeterID, "meterID", meter.MeterID, "err", err)
+			continue
+		}
+		p.lastDays[meterKey] = day
+		if stored {
+			metrics.Add("readings", 1)
+			slog.Info("modbus reading stored", "meterID", meter.MeterID, "value", value)
+		}
+	}
+}
+
+func (p *Poller) read(meter Meter) (float64, error) {
+	handler := modbus.NewTCPClientHandler(meter.Address)
+	handler.SlaveId = meter.UnitID
+	handler.Timeout = p.options.Timeout
+	if err := handler.Connect(); err != nil {
+		return 0, fmt.Errorf("could not connect: %w", err)
+	}
+	defer handler.Close()
+	client := modbus.NewClient(handler)
+
+	count := registerCounts[meter.DataType]
+	var data []byte
+	var err error
+	if meter.RegisterType == "input" {
+		data, err = client.ReadInputRegisters(meter.Register, count)
+	} else {
+		data, err = client.ReadHoldingRegisters(meter.Register, count)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not read registers: %w", err)
+	}
+	value, err := decodeRegisters(data, meter.DataType, meter.WordOrder)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("value is not a number")
+	}
+	return value * meter.Scale, nil
+}
+
+// decodeRegisters decodes big endian registers, words of 32 and 64-bit values
+// are reversed first for little word order.
+func decodeRegisters(data []byte, dataType, wordOrder string) (float64, error) {
+	count := int(registerCounts[dataType])
+	if len(data) != 2*count {
+		return 0, fmt.Errorf("got %d bytes, expected %d", len(data), 2*count)
+	}
+	if wordOrder == "little" {
+		reversed := make([]byte, len(data))
+		for i := 0; i < count; i++ {
+			copy(reversed[2*(count-1-i):], data[2*i:2*i+2])
+		}
+		data = reversed
+	}
+	switch dataType {
+	case "uint16":
+		return float64(binary.BigEndian.Uint16(data)), nil
+	case "int16":
+		return float64(int16(binary.BigEndian.Uint16(data))), nil
+	case "uint32":
+		return float64(binary.BigEndian.Uint32(data)), nil
+	case "int32":
+		return float64(int32(binary.BigEndian.Uint32(data))), nil
+	case "float32":
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case "uint64":
+		return float64(binary.BigEndian.Uint64(data)), nil
+	case "int64":
+		return float64(int64(binary.BigEndian.Uint64(data))), nil
+	case "float64":
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	default:
+		return 0, fmt.Errorf("invalid data type %q", dataType)
+	}
+}
@@ -0,0 +1,205 @@
+// Package mqtt subscribes to meter values published over MQTT and stores them
+// as daily readings.
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/svoboond/spinus/internal/ingest"
+)
+
+const (
+	connectTimeout = 30 * time.Second
+	storeTimeout   = 10 * time.Second
+)
+
+var metrics = expvar.NewMap("mqtt")
+
+// Mapping maps messages of topics matching Topic to sub meter of main meter
+// with database ID MainMeterID, with meter identification MeterID, or the
+// first "+" level of topic if MeterID is empty.
+type Mapping struct {
+	Topic       string
+	JSONPath    string
+	MainMeterID int32
+	MeterID     string
+}
+
+type Options struct {
+	Broker               string
+	ClientID             string
+	Username             string
+	Password             string
+	QoS                  byte
+	MaxReconnectInterval time.Duration
+	Mappings             []Mapping
+}
+
+// Subscriber stores the first value received each day for every meter.
+type Subscriber struct {
+	client   paho.Client
+	options  Options
+	store    ingest.StoreFunc
+	mu       sync.Mutex
+	lastDays map[string]string
+}
+
+func NewSubscriber(options Options, store ingest.StoreFunc) (*Subscriber, error) {
+	if options.Broker == "" {
+		return nil, errors.New("no broker")
+	}
+	if len(options.Mappings) == 0 {
+		return nil, errors.New("no mappings")
+	}
+	if options.QoS > 2 {
+		return nil, fmt.Errorf("invalid QoS %d", options.QoS)
+	}
+	for _, mapping := range options.Mappings {
+		if mapping.Topic == "" {
+			return nil, errors.New("mapping without topic")
+		}
+		if mapping.MainMeterID <= 0 {
+			return nil, fmt.Errorf("mapping %s without main meter ID", mapping.Topic)
+		}
+	}
+	s := &Subscriber{
+		options:  options,
+		store:    store,
+		lastDays: make(map[string]string),
+	}
+
+	clientOptions := paho.NewClientOptions().
+		AddBroker(options.Broker).
+		SetClientID(options.ClientID).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectTimeout(connectTimeout).
+		SetOnConnectHandler(s.onConnect).
+		SetConnectionLostHandler(s.onConnectionLost).
+		SetReconnectingHandler(s.onReconnecting)
+	// Reconnect interval doubles up to the maximum.
+	if options.MaxReconnectInterval > 0 {
+		clientOptions.SetMaxReconnectInterval(options.MaxReconnectInterval)
+	}
+	s.client = paho.NewClient(clientOptions)
+	return s, nil
+}
+
+// Start connects in background, connection is retried until Stop is called.
+func (s *Subscriber) Start() {
+	slog.Info("mqtt subscriber starting", "broker", s.options.Broker)
+	s.client.Connect()
+}
+
+func (s *Subscriber) Stop() {
+	s.client.Disconnect(250)
+	slog.Info("mqtt subscriber stopped")
+}
+
+// Subscriptions are renewed on every connect, broker may not keep them.
+func (s *Subscriber) onConnect(client paho.Client) {
+	metrics.Add("connects", 1)
+	slog.Info("mqtt connected", "broker", s.options.Broker)
+	filters := make(map[string]byte, len(s.options.Mappings))
+	for _, mapping := range s.options.Mappings {
+		filters[mapping.Topic] = s.options.QoS
+	}
+	token := client.SubscribeMultiple(filters, s.onMessage)
+	go func() {
+		if token.WaitTimeout(connectTimeout) && token.Error() == nil {
+			return
+		}
+		metrics.Add("subscribe_errors", 1)
+		slog.Error("error subscribing to mqtt topics", "err", token.Error())
+	}()
+}
+
+func (s *Subscriber) onConnectionLost(_ paho.Client, err error) {
+	metrics.Add("connection_losses", 1)
+	slog.Warn("mqtt connection lost", "err", err)
+}
+
+func (s *Subscriber) onReconnecting(_ paho.Client, _ *paho.ClientOptions) {
+	metrics.Add("reconnects", 1)
+	slog.Debug("mqtt reconnecting", "broker", s.options.Broker)
+}
+
+func (s *Subscriber) onMessage(_ paho.Client, message paho.Message) {
+	metrics.Add("messages", 1)
+	topic := message.Topic()
+	mapping, meterID, ok := s.findMapping(topic)
+	if !ok {
+		metrics.Add("unmapped_messages", 1)
+		slog.Debug("no mqtt mapping", "topic", topic)
+		return
+	}
+	value, err := parseValue(message.Payload(), mapping.JSONPath)
+	if err != nil {
+		metrics.Add("invalid_messages", 1)
+		slog.Warn("invalid mqtt message", "topic", topic, "err", err)
+		return
+	}
+
+	now := time.Now()
+	day := now.Format(time.DateOnly)
+	s.mu.Lock()
+	meterKey := fmt.Sprintf("%d/%s", mapping.MainMeterID, meterID)
+	lastDay := s.lastDays[meterKey]
+	s.mu.Unlock()
+	if lastDay == day {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	stored, err := s.store(ctx, mapping.MainMeterID, meterID, value, now)
+	if errors.Is(err, ingest.ErrRejected) {
+		metrics.Add("rejected_readings", 1)
+		slog.Warn(
+			"mqtt reading rejected",
+			"topic", topic, "mainMeterID", mapping.MainMeterID, "meterID", meterID,
+			"err", err)
+	} else if err != nil {
+		metrics.Add("store_errors", 1)
+		slog.Error(
+			"error storing mqtt reading",
+			"topic", topic, "mainMeterID", mapping.MainMeterID, "meterID", meterID,
+			"err", err)
+		return
+	}
+	s.mu.Lock()
+	s.lastDays[meterKey] = day
+	s.mu.Unlock()
+	if stored {
+		metrics.Add("readings", 1)
+		slog.Info(
+			"mqtt reading stored",
+			"mainMeterID", mapping.MainMeterID, "meterID", meterID, "value", value)
+	}
+}
+
+func (s *Subscriber) findMapping(topic string) (Mapping, string, bool) {
+	for _, mapping := range s.options.Mappings {
+		wildcards, ok := matchTopic(mapping.Topic, topic)
+		if !ok {
+			continue
+		}
+		meterID := mapping.MeterID
+		if meterID == "" {
+			if len(wildcards) == 0 {
+				continue
+			}
+			meterID = wildcards[0]
+		}
+		return mapping, meterID, true
+	}
+	return Mapping{}, "", false
+}
@@ -0,0 +1,84 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// matchTopic matches topic against filter with "+" and "#" wildcards and
+// returns topic levels matched by "+".
+func matchTopic(filter, topic string) ([]string, bool) {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	var wildcards []string
+	for i, filterLevel := range filterLevels {
+		if filterLevel == "#" {
+			return wildcards, true
+		}
+		if i >= len(topicLevels) {
+			return nil, false
+		}
+		switch filterLevel {
+		case "+":
+			wildcards = append(wildcards, topicLevels[i])
+		case topicLevels[i]:
+		default:
+			return nil, false
+		}
+	}
+	return wildcards, len(filterLevels) == len(topicLevels)
+}
+
+// parseValue reads number from the whole payload or from dot separated JSON
+// path, with array indexes as path elements. Numbers sent as JSON strings are
+// accepted.
+func parseValue(payload []byte, jsonPath string) (float64, error) {
+	if jsonPath == "" {
+		return parseNumber(string(bytes.TrimSpace(payload)))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return 0, fmt.Errorf("could not decode JSON: %w", err)
+	}
+	for _, key := range strings.Split(jsonPath, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			child, ok := node[key]
+			if !ok {
+				return 0, fmt.Errorf("no %q in JSON path %q", key, jsonPath)
+			}
+			v = child
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return 0, fmt.Errorf("no index %q in JSON path %q", key, jsonPath)
+			}
+			v = node[i]
+		default:
+			return 0, fmt.Errorf("no %q in JSON path %q", key, jsonPath)
+		}
+	}
+	switch value := v.(type) {
+	case json.Number:
+		return parseNumber(value.String())
+	case string:
+		return parseNumber(strings.TrimSpace(value))
+	default:
+		return 0, fmt.Errorf("value at JSON path %q is not a number", jsonPath)
+	}
+}
+
+func parseNumber(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.New("value is not a number")
+	}
+	return v, nil
+}
@@ -0,0 +1,323 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const (
+	fontFamily = "go"
+	fontSize   = 9.0
+	lineHeight = 5.0
+	pageMargin = 15.0
+	dateLayout = "2006-01-02"
+	qrCodeSize = 40.0
+)
+
+type Party struct {
+	Name        string
+	CompanyID   string
+	VATID       string
+	Email       string
+	Address     string
+	BankAccount string
+}
+
+func (p Party) lines() []string {
+	var lines []string
+	for _, line := range []struct {
+		label string
+		value string
+	}{
+		{"", p.Name},
+		{"Company ID: ", p.CompanyID},
+		{"VAT ID: ", p.VATID},
+		{"", p.Email},
+		{"", p.Address},
+		{"Account: ", p.BankAccount},
+	} {
+		if line.value != "" {
+			lines = append(lines, line.label+line.value)
+		}
+	}
+	return lines
+}
+
+type Period struct {
+	BeginDate           time.Time
+	EndDate             time.Time
+	EnergyConsumption   float64
+	ConsumedEnergyPrice float64
+	ServicePrice        float64
+	TotalPrice          float64
+}
+
+type Reading struct {
+	Date      time.Time
+	Value     float64
+	Estimated bool
+}
+
+type CostItem struct {
+	Name          string
+	AllocationKey string
+	Share         float64
+	Price         float64
+}
+
+// Payment of the statement balance, QR code is PNG image.
+type Payment struct {
+	IBAN           string
+	VariableSymbol string
+	Amount         float64
+	QRCode         []byte
+}
+
+type SubMeterStatement struct {
+	Title               string
+	Number              string
+	Landlord            Party
+	Tenant              Party
+	Energy              string
+	MainMeterID         string
+	SubMeterID          string
+	BeginDate           time.Time
+	EndDate             time.Time
+	Periods             []Period
+	Readings            []Reading
+	CostItems           []CostItem
+	EnergyConsumption   float64
+	ConsumedEnergyPrice float64
+	ServicePrice        float64
+	CostItemPrice       float64
+	AdvancePrice        float64
+	TotalPrice          float64
+	Payment             *Payment
+}
+
+func (s SubMeterStatement) Balance() float64 { return s.TotalPrice - s.AdvancePrice }
+
+func formatPrice(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+
+func formatValue(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+
+func formatPercent(v float64) string { return strconv.FormatFloat(v*100, 'f', 2, 64) + " %" }
+
+type document struct {
+	*gofpdf.Fpdf
+	contentWidth float64
+}
+
+func newDocument(title string) *document {
+	f := gofpdf.New("P", "mm", "A4", "")
+	f.AddUTF8FontFromBytes(fontFamily, "", goregular.TTF)
+	f.AddUTF8FontFromBytes(fontFamily, "B", gobold.TTF)
+	f.SetMargins(pageMargin, pageMargin, pageMargin)
+	f.SetAutoPageBreak(true, pageMargin)
+	f.SetTitle(title, true)
+	f.SetCreator("spinus", true)
+	f.AddPage()
+	pageWidth, _ := f.GetPageSize()
+	return &document{Fpdf: f, contentWidth: pageWidth - 2*pageMargin}
+}
+
+func (d *document) heading(txt string, size float64) {
+	d.SetFont(fontFamily, "B", size)
+	d.CellFormat(0, size*0.6, txt, "", 1, "L", false, 0, "")
+	d.Ln(2)
+}
+
+// Table columns are given as relative widths.
+func (d *document) table(widths []float64, header []string, rows [][]string, footer []string) {
+	var widthsSum float64
+	for _, w := range widths {
+		widthsSum += w
+	}
+	cellWidths := make([]float64, len(widths))
+	for i, w := range widths {
+		cellWidths[i] = d.contentWidth * w / widthsSum
+	}
+	row := func(cells []string, style string) {
+		d.SetFont(fontFamily, style, fontSize)
+		for i, cell := range cells {
+			align := "R"
+			if i == 0 {
+				align = "L"
+			}
+			d.CellFormat(cellWidths[i], lineHeight+1, cell, "1", 0, align, false, 0, "")
+		}
+		d.Ln(-1)
+	}
+	row(header, "B")
+	for _, cells := range rows {
+		row(cells, "")
+	}
+	if footer != nil {
+		row(footer, "B")
+	}
+	d.Ln(4)
+}
+
+func (d *document) parties(landlord, tenant Party) {
+	columnWidth := d.contentWidth / 2
+	y := d.GetY()
+	maxY := y
+	for i, p := range []struct {
+		label string
+		party Party
+	}{{"Landlord", landlord}, {"Tenant", tenant}} {
+		x := pageMargin + float64(i)*columnWidth
+		d.SetXY(x, y)
+		d.SetFont(fontFamily, "B", fontSize)
+		d.CellFormat(columnWidth, lineHeight, p.label, "", 2, "L", false, 0, "")
+		d.SetFont(fontFamily, "", fontSize)
+		for _, line := range p.party.lines() {
+			d.CellFormat(columnWidth, lineHeight, line, "", 2, "L", false, 0, "")
+		}
+		maxY = max(maxY, d.GetY())
+	}
+	d.SetXY(pageMargin, maxY)
+	d.Ln(4)
+}
+
+func (d *document) summary(rows [][2]string) {
+	labelWidth := d.contentWidth * 0.7
+	valueWidth := d.contentWidth - labelWidth
+	for i, r := range rows {
+		style := ""
+		if i == len(rows)-1 {
+			style = "B"
+		}
+		d.SetFont(fontFamily, style, fontSize)
+		d.CellFormat(labelWidth, lineHeight+1, r[0], "1", 0, "L", false, 0, "")
+		d.CellFormat(valueWidth, lineHeight+1, r[1], "1", 1, "R", false, 0, "")
+	}
+	d.Ln(4)
+}
+
+func (d *document) payment(p *Payment) {
+	d.summary([][2]string{
+		{"Account", p.IBAN},
+		{"Variable Symbol", p.VariableSymbol},
+		{"Amount", formatPrice(p.Amount)},
+	})
+	const imageName = "payment"
+	d.RegisterImageOptionsReader(
+		imageName, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(p.QRCode))
+	d.ImageOptions(
+		imageName, pageMargin, -1, qrCodeSize, qrCodeSize, true,
+		gofpdf.ImageOptions{ImageType: "PNG"}, 0, "",
+	)
+}
+
+func WriteSubMeterStatement(w io.Writer, s SubMeterStatement) error {
+	d := newDocument(s.Title)
+
+	d.heading(s.Title, 16)
+	if s.Number != "" {
+		d.SetFont(fontFamily, "", fontSize)
+		d.CellFormat(0, lineHeight, "Number: "+s.Number, "", 1, "L", false, 0, "")
+		d.Ln(2)
+	}
+	d.parties(s.Landlord, s.Tenant)
+
+	d.SetFont(fontFamily, "", fontSize)
+	d.CellFormat(
+		0, lineHeight,
+		fmt.Sprintf(
+			"Energy: %s   Main meter: %s   Sub meter: %s",
+			s.Energy, s.MainMeterID, s.SubMeterID,
+		),
+		"", 1, "L", false, 0, "",
+	)
+	d.CellFormat(
+		0, lineHeight,
+		fmt.Sprintf(
+			"Billing period: %s - %s",
+			s.BeginDate.Format(dateLayout), s.EndDate.Format(dateLayout),
+		),
+		"", 1, "L", false, 0, "",
+	)
+	d.Ln(4)
+
+	d.heading("Billing Periods", 12)
+	periodRows := make([][]string, 0, len(s.Periods))
+	for _, p := range s.Periods {
+		periodRows = append(periodRows, []string{
+			p.BeginDate.Format(dateLayout) + " - " + p.EndDate.Format(dateLayout),
+			formatValue(p.EnergyConsumption),
+			formatPrice(p.ConsumedEnergyPrice),
+			formatPrice(p.ServicePrice),
+			formatPrice(p.TotalPrice),
+		})
+	}
+	d.table(
+		[]float64{3, 2, 2, 2, 2},
+		[]string{"Period", "Consumption", "Energy Price", "Service Price", "Total Price"},
+		periodRows,
+		[]string{
+			"Total",
+			formatValue(s.EnergyConsumption),
+			formatPrice(s.ConsumedEnergyPrice),
+			formatPrice(s.ServicePrice),
+			"",
+		},
+	)
+
+	if len(s.Readings) > 0 {
+		d.heading("Readings", 12)
+		readingRows := make([][]string, 0, len(s.Readings))
+		for _, r := range s.Readings {
+			var estimated string
+			if r.Estimated {
+				estimated = "Yes"
+			}
+			readingRows = append(readingRows, []string{
+				r.Date.Format(dateLayout), formatValue(r.Value), estimated})
+		}
+		d.table(
+			[]float64{1, 1, 1}, []string{"Date", "Value", "Estimated"}, readingRows, nil)
+	}
+
+	if len(s.CostItems) > 0 {
+		d.heading("Cost Items", 12)
+		costItemRows := make([][]string, 0, len(s.CostItems))
+		for _, c := range s.CostItems {
+			costItemRows = append(costItemRows, []string{
+				c.Name, c.AllocationKey, formatPercent(c.Share), formatPrice(c.Price)})
+		}
+		d.table(
+			[]float64{3, 2, 1, 1},
+			[]string{"Name", "Allocation Key", "Share", "Price"},
+			costItemRows,
+			[]string{"Total", "", "", formatPrice(s.CostItemPrice)},
+		)
+	}
+
+	d.heading("Summary", 12)
+	d.summary([][2]string{
+		{"Consumed Energy Price", formatPrice(s.ConsumedEnergyPrice)},
+		{"Service Price", formatPrice(s.ServicePrice)},
+		{"Cost Item Price", formatPrice(s.CostItemPrice)},
+		{"Total Price", formatPrice(s.TotalPrice)},
+		{"Advance Price", formatPrice(s.AdvancePrice)},
+		{"Balance", formatPrice(s.Balance())},
+	})
+
+	if s.Payment != nil {
+		d.heading("Payment", 12)
+		d.payment(s.Payment)
+	}
+
+	if err := d.Output(w); err != nil {
+		return fmt.Errorf("could not write sub meter statement: %w", err)
+	}
+	return nil
+}
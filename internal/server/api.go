@@ -0,0 +1,34 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// APIErrorResponse is the body of failed responses of JSON endpoints.
+type APIErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		slog.Error("error encoding JSON", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("error writing response", "err", err)
+	}
+}
+
+// writeInternalError answers JSON endpoints after the error was logged.
+func writeInternalError(w http.ResponseWriter) {
+	writeJSON(
+		w, http.StatusInternalServerError,
+		APIErrorResponse{Error: "Internal server error."})
+}
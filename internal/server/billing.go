@@ -2,6 +2,8 @@ package server
 
 import (
 	"time"
+
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
 )
 
 type BreakPoints [][3]time.Time
@@ -10,8 +12,129 @@ func (bp BreakPoints) Less(i, j int) bool { return bp[i][1].Before(bp[j][1]) }
 func (bp BreakPoints) Swap(i, j int)      { bp[i], bp[j] = bp[j], bp[i] }
 func (bp BreakPoints) Len() int           { return len(bp) }
 
+// ActualRange returns the earliest and the latest actual time of break points.
+func (bp BreakPoints) ActualRange() (earliest, latest time.Time) {
+	for i, p := range bp {
+		if i == 0 || p[1].Before(earliest) {
+			earliest = p[1]
+		}
+		if i == 0 || p[1].After(latest) {
+			latest = p[1]
+		}
+	}
+	return earliest, latest
+}
+
 type Reading struct {
-	Value float64
-	Time  time.Time
-	Valid bool
+	Value     float64
+	Time      time.Time
+	Valid     bool
+	Estimated bool
+}
+
+// betterFor reports whether reading in break point range should replace the
+// other reading of the break point. Actual readings are preferred over
+// estimates, then readings with lower time difference.
+func (r *Reading) betterFor(bpActual time.Time, other *Reading) bool {
+	if other.Valid && other.Estimated != r.Estimated {
+		return other.Estimated
+	}
+	return bpActual.Sub(r.Time) <= bpActual.Sub(other.Time)
+}
+
+// AllocationWeights maps sub meter ID to its weight for given allocation key.
+// Sub meters without value for the allocation key have zero weight.
+type AllocationWeights map[int32]float64
+
+func newAllocationWeights(
+	allocationKey spinusdb.AllocationKey,
+	subMeters []spinusdb.ListSubMeterAllocationsRow,
+) AllocationWeights {
+	weights := make(AllocationWeights, len(subMeters))
+	for _, subMeter := range subMeters {
+		var weight float64
+		switch allocationKey {
+		case spinusdb.AllocationKeyEqual:
+			weight = 1
+		case spinusdb.AllocationKeyFloorArea:
+			weight = subMeter.FloorArea.Float64
+		case spinusdb.AllocationKeyOccupants:
+			weight = float64(subMeter.Occupants.Int32)
+		case spinusdb.AllocationKeyCustom:
+			weight = subMeter.CostShare.Float64
+		}
+		weights[subMeter.ID] = weight
+	}
+	return weights
+}
+
+func (aw AllocationWeights) Sum() float64 {
+	var sum float64
+	for _, weight := range aw {
+		sum += weight
+	}
+	return sum
+}
+
+var allocationKeyErrors = map[spinusdb.AllocationKey]string{
+	spinusdb.AllocationKeyEqual:     "There is no sub meter.",
+	spinusdb.AllocationKeyFloorArea: "No sub meter has floor area set.",
+	spinusdb.AllocationKeyOccupants: "No sub meter has number of occupants set.",
+	spinusdb.AllocationKeyCustom:    "No sub meter has cost share set.",
+}
+
+// billedAllocationKeyErrors are used when the sub meters with the allocation
+// key set are not billed, as they have no readings in the billing.
+var billedAllocationKeyErrors = map[spinusdb.AllocationKey]string{
+	spinusdb.AllocationKeyEqual:     "No sub meter is billed.",
+	spinusdb.AllocationKeyFloorArea: "No billed sub meter has floor area set.",
+	spinusdb.AllocationKeyOccupants: "No billed sub meter has number of occupants set.",
+	spinusdb.AllocationKeyCustom:    "No billed sub meter has cost share set.",
+}
+
+// newIntervalBreakPointReadings returns exact readings at break points of sub
+// meters with interval readings covering all days between the earliest and
+// the latest break point. Reading value is consumption since the earliest
+// break point, as only differences between break points matter.
+func newIntervalBreakPointReadings(
+	days []spinusdb.ListMainMeterIntervalDaysRow, breakPoints BreakPoints,
+	location *time.Location,
+) map[int32]map[time.Time]*Reading {
+	earliest, latest := breakPoints.ActualRange()
+
+	consumptions := make(map[int32]map[time.Time]float64)
+	for _, day := range days {
+		if day.CoveredSeconds < completeDaySeconds(day.Day.Time, location) {
+			continue
+		}
+		if _, ok := consumptions[day.FkSubMeter]; !ok {
+			consumptions[day.FkSubMeter] = make(map[time.Time]float64)
+		}
+		consumptions[day.FkSubMeter][day.Day.Time] = day.Consumption
+	}
+
+	readings := make(map[int32]map[time.Time]*Reading)
+	for subMeterID, dayConsumptions := range consumptions {
+		values := map[time.Time]float64{earliest: 0}
+		var value float64
+		complete := true
+		for t := earliest.AddDate(0, 0, 1); !t.After(latest); t = t.AddDate(0, 0, 1) {
+			consumption, ok := dayConsumptions[t]
+			if !ok {
+				complete = false
+				break
+			}
+			value += consumption
+			values[t] = value
+		}
+		if !complete {
+			continue
+		}
+		readings[subMeterID] = make(map[time.Time]*Reading, len(breakPoints))
+		for _, bp := range breakPoints {
+			readings[subMeterID][bp[1]] = &Reading{
+				Value: values[bp[1]], Time: bp[1], Valid: true}
+		}
+	}
+	return readings
 }
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"slices"
+
+	"github.com/svoboond/spinus/internal/chart"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+)
+
+// Older billing periods do not fit in the chart.
+const maxChartBillingPeriods = 8
+
+var energyUnits = map[spinusdb.Energy]string{
+	spinusdb.EnergyElectricity: "kWh",
+	spinusdb.EnergyGas:         "m³",
+	spinusdb.EnergyWater:       "m³",
+	spinusdb.EnergyHeat:        "kWh",
+}
+
+// newSubMeterReadingCharts returns chart of readings and chart of average daily
+// consumption between them. Readings are sorted by date descending.
+func newSubMeterReadingCharts(
+	readings []spinusdb.SubMeterReading, energy spinusdb.Energy,
+) (readingChart, consumptionChart template.HTML) {
+	unit := energyUnits[energy]
+	points := make([]chart.Point, 0, len(readings))
+	for i := len(readings) - 1; i >= 0; i-- {
+		points = append(points, chart.Point{
+			Time: readings[i].ReadingDate.Time, Value: readings[i].ReadingValue})
+	}
+	var spans []chart.Span
+	for i := 1; i < len(points); i++ {
+		previous, current := points[i-1], points[i]
+		days := daysBetween(previous.Time, current.Time)
+		if days <= 0 {
+			continue
+		}
+		spans = append(spans, chart.Span{
+			Begin: previous.Time,
+			End:   current.Time,
+			Value: (current.Value - previous.Value) / float64(days),
+		})
+	}
+	// Charts escape all texts.
+	readingChart = template.HTML(chart.Line("Readings", unit, points))
+	consumptionChart = template.HTML(
+		chart.Spans("Average Daily Consumption", unit+" / day", spans))
+	return readingChart, consumptionChart
+}
+
+// newConsumptionShareChart returns chart of sub meter consumptions stacked per
+// billing period. Rows are sorted by billing, period and sub meter.
+func newConsumptionShareChart(
+	rows []spinusdb.ListSubMeterBillingPeriodConsumptionsRow, energy spinusdb.Energy,
+) template.HTML {
+	var subMeterSubids []int32
+	for _, row := range rows {
+		if !slices.Contains(subMeterSubids, row.SubMeterSubid) {
+			subMeterSubids = append(subMeterSubids, row.SubMeterSubid)
+		}
+	}
+	slices.Sort(subMeterSubids)
+	seriesNames := make([]string, 0, len(subMeterSubids))
+	for _, subid := range subMeterSubids {
+		seriesNames = append(seriesNames, fmt.Sprintf("Sub Meter %d", subid))
+	}
+
+	var stacks []chart.Stack
+	for i, row := range rows {
+		if i == 0 || row.BillingSubid != rows[i-1].BillingSubid ||
+			row.BeginDate != rows[i-1].BeginDate {
+			stacks = append(stacks, chart.Stack{
+				Label:  formatDate(row.BeginDate),
+				Values: make([]float64, len(subMeterSubids)),
+			})
+		}
+		j := slices.Index(subMeterSubids, row.SubMeterSubid)
+		stacks[len(stacks)-1].Values[j] += row.EnergyConsumption
+	}
+	if len(stacks) > maxChartBillingPeriods {
+		stacks = stacks[len(stacks)-maxChartBillingPeriods:]
+	}
+	// Charts escape all texts.
+	return template.HTML(chart.StackedBars(
+		"Sub Meter Consumption per Billing Period",
+		energyUnits[energy],
+		seriesNames,
+		stacks,
+	))
+}
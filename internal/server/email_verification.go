@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/mail"
+)
+
+const (
+	emailVerificationTokenTTL       = 7 * 24 * time.Hour
+	emailVerificationResendInterval = 5 * time.Minute
+)
+
+// Email verification token is signed instead of stored. It consists of user
+// ID, expiry and signature of both with the email, so the token is not valid
+// for a changed email.
+type emailVerificationToken struct {
+	userID    int32
+	expiresAt time.Time
+	signature []byte
+}
+
+func (s *Server) newEmailVerificationToken(user spinusdb.SpinusUser, now time.Time) string {
+	token := emailVerificationToken{
+		userID: user.ID, expiresAt: now.Add(emailVerificationTokenTTL).Truncate(time.Second)}
+	signature := s.signEmailVerificationToken(token, user.Email)
+	return fmt.Sprintf(
+		"%d.%d.%s",
+		token.userID, token.expiresAt.Unix(), base64.RawURLEncoding.EncodeToString(signature))
+}
+
+func parseEmailVerificationToken(s string) (emailVerificationToken, error) {
+	var token emailVerificationToken
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return token, errors.New("token does not have three parts")
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return token, fmt.Errorf("could not parse user ID: %w", err)
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return token, fmt.Errorf("could not parse expiry: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return token, fmt.Errorf("could not decode signature: %w", err)
+	}
+	token.userID = int32(userID)
+	token.expiresAt = time.Unix(expiresAt, 0)
+	token.signature = signature
+	return token, nil
+}
+
+func (s *Server) signEmailVerificationToken(token emailVerificationToken, email string) []byte {
+	mac := hmac.New(sha256.New, s.secretKey)
+	fmt.Fprintf(
+		mac, "email-verification\x00%d\x00%d\x00%s",
+		token.userID, token.expiresAt.Unix(), email)
+	return mac.Sum(nil)
+}
+
+func (s *Server) validEmailVerificationToken(
+	token emailVerificationToken, email string, now time.Time,
+) bool {
+	return now.Before(token.expiresAt) &&
+		hmac.Equal(token.signature, s.signEmailVerificationToken(token, email))
+}
+
+// sendEmailVerification sends verification email unless one was sent to the
+// user recently. It reports whether the email was sent.
+func (s *Server) sendEmailVerification(ctx context.Context, user spinusdb.SpinusUser) (bool, error) {
+	key := fmt.Sprintf("email-verification:%d", user.ID)
+	ok, err := s.redisClient.SetNX(ctx, key, 1, emailVerificationResendInterval).Result()
+	if err != nil {
+		return false, fmt.Errorf("could not limit email verification: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+	token := s.newEmailVerificationToken(user, time.Now())
+	s.sendMail(s.newEmailVerificationMessage(user, token))
+	return true, nil
+}
+
+func (s *Server) newEmailVerificationMessage(user spinusdb.SpinusUser, token string) mail.Message {
+	link := s.baseURL + "/email/verify?" + url.Values{"token": {token}}.Encode()
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Spinus email verification",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\n"+
+				"open the link below to verify the email of your Spinus account, "+
+				"the link is valid for %d days.\n\n"+
+				"%s\n\n"+
+				"If you did not create the account, ignore this email.\n",
+			user.Username, int(emailVerificationTokenTTL.Hours()/24), link),
+	}
+}
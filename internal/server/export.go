@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/svoboond/spinus/internal/xlsx"
+)
+
+const (
+	exportFormatCSV  = "csv"
+	exportFormatXLSX = "xlsx"
+	// Exports are written while rows are read, which takes long for large
+	// buildings.
+	exportWriteTimeout = 5 * time.Minute
+)
+
+// Languages writing decimal comma. CSV files for them use semicolons as field
+// separators, the same as spreadsheet applications do.
+var decimalCommaLanguages = map[string]bool{
+	"bg": true, "cs": true, "da": true, "de": true, "el": true, "es": true,
+	"et": true, "fi": true, "fr": true, "hr": true, "hu": true, "id": true,
+	"it": true, "lt": true, "lv": true, "nb": true, "nl": true, "no": true,
+	"pl": true, "pt": true, "ro": true, "ru": true, "sk": true, "sl": true,
+	"sr": true, "sv": true, "tr": true, "uk": true,
+}
+
+// exportLanguage returns language of the export. It is taken from locale query
+// parameter and falls back to the preferred language of the browser.
+func exportLanguage(r *http.Request) string {
+	locale := r.URL.Query().Get("locale")
+	if locale == "" {
+		locale, _, _ = strings.Cut(r.Header.Get("Accept-Language"), ",")
+		locale, _, _ = strings.Cut(locale, ";")
+	}
+	language, _, _ := strings.Cut(strings.TrimSpace(locale), "-")
+	language, _, _ = strings.Cut(language, "_")
+	return strings.ToLower(language)
+}
+
+type exportCell struct {
+	xlsx.Cell
+	text string
+	// Numbers are written with decimal separator of the export locale.
+	number bool
+}
+
+func textCell(s string) exportCell { return exportCell{Cell: xlsx.String(s), text: s} }
+
+func boolCell(v bool) exportCell {
+	if v {
+		return textCell("Yes")
+	}
+	return textCell("No")
+}
+
+func intCell(v int32) exportCell {
+	return exportCell{
+		Cell: xlsx.Number(float64(v), 0), text: strconv.Itoa(int(v)), number: true}
+}
+
+func priceCell(v float64) exportCell {
+	return exportCell{Cell: xlsx.Number(v, 2), text: formatPrice(v), number: true}
+}
+
+func optionalPriceCell(v pgtype.Float8) exportCell {
+	if !v.Valid {
+		return exportCell{}
+	}
+	return priceCell(v.Float64)
+}
+
+func consumptionCell(v float64) exportCell {
+	return exportCell{Cell: xlsx.Number(v, 3), text: formatConsumption(v), number: true}
+}
+
+func dateCell(d pgtype.Date) exportCell {
+	if !d.Valid {
+		return exportCell{}
+	}
+	return exportCell{Cell: xlsx.Date(d.Time), text: formatDate(d)}
+}
+
+type exporter interface {
+	WriteRow(cells ...exportCell) error
+	Close() error
+}
+
+type csvExporter struct {
+	w            *csv.Writer
+	decimalComma bool
+}
+
+func (e *csvExporter) WriteRow(cells ...exportCell) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = cell.text
+		if e.decimalComma && cell.number {
+			record[i] = strings.Replace(cell.text, ".", ",", 1)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type xlsxExporter struct {
+	w *xlsx.Writer
+}
+
+func (e *xlsxExporter) WriteRow(cells ...exportCell) error {
+	row := make([]xlsx.Cell, len(cells))
+	for i, cell := range cells {
+		row[i] = cell.Cell
+	}
+	return e.w.WriteRow(row...)
+}
+
+func (e *xlsxExporter) Close() error { return e.w.Close() }
+
+// newExporter sets response headers for a file of given name without extension
+// and returns exporter writing rows directly to the response.
+func newExporter(
+	w http.ResponseWriter, r *http.Request, format string, name string,
+) (exporter, error) {
+	disposition := fmt.Sprintf("attachment; filename=\"%s.%s\"", name, format)
+	switch format {
+	case exportFormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", disposition)
+		csvWriter := csv.NewWriter(w)
+		decimalComma := decimalCommaLanguages[exportLanguage(r)]
+		if decimalComma {
+			csvWriter.Comma = ';'
+		}
+		return &csvExporter{w: csvWriter, decimalComma: decimalComma}, nil
+	case exportFormatXLSX:
+		w.Header().Set("Content-Type", xlsx.ContentType)
+		w.Header().Set("Content-Disposition", disposition)
+		xlsxWriter, err := xlsx.NewWriter(w, name)
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxExporter{w: xlsxWriter}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
@@ -19,6 +19,25 @@ type LogInFormData struct {
 	PasswordError string
 }
 
+type PasswordForgotFormData struct {
+	GeneralError string
+	Email        string
+	EmailError   string
+}
+
+type PasswordResetFormData struct {
+	GeneralError        string
+	Token               string
+	PasswordError       string
+	RepeatPasswordError string
+}
+
+type TwoFactorFormData struct {
+	GeneralError  string
+	CodeError     string
+	PasswordError string
+}
+
 type MainMeterFormData struct {
 	GeneralError string
 	MeterID      string
@@ -30,9 +49,15 @@ type MainMeterFormData struct {
 }
 
 type SubMeterFormData struct {
-	GeneralError string
-	MeterID      string
-	MeterIDError string
+	GeneralError   string
+	MeterID        string
+	MeterIDError   string
+	FloorArea      string
+	FloorAreaError string
+	Occupants      string
+	OccupantsError string
+	CostShare      string
+	CostShareError string
 }
 
 type SubMeterReadingFormData struct {
@@ -41,6 +66,9 @@ type SubMeterReadingFormData struct {
 	ReadingValueError string
 	ReadingDate       string
 	ReadingDateError  string
+	Estimated         bool
+	PhotosError       string
+	Confirmed         bool
 }
 
 type MainMeterBillingPeriodFormData struct {
@@ -58,6 +86,15 @@ type MainMeterBillingPeriodFormData struct {
 	ServicePriceError        string
 }
 
+type MainMeterBillingCostItemFormData struct {
+	Name               string
+	NameError          string
+	AllocationKey      string
+	AllocationKeyError string
+	Price              string
+	PriceError         string
+}
+
 func NewMainMeterBillingFormData() MainMeterBillingFormData {
 	return MainMeterBillingFormData{
 		MaxDayDiff:     "14",
@@ -70,4 +107,94 @@ type MainMeterBillingFormData struct {
 	MaxDayDiff      string
 	MaxDayDiffError string
 	BillingPeriods  []*MainMeterBillingPeriodFormData
+	CostItems       []*MainMeterBillingCostItemFormData
+}
+
+type StatementFormData struct {
+	GeneralError   string
+	Tenant         string
+	TenantError    string
+	BeginDate      string
+	BeginDateError string
+	EndDate        string
+	EndDateError   string
+}
+
+type BankAccountFormData struct {
+	GeneralError string
+	IBAN         string
+	IBANError    string
+	BIC          string
+	BICError     string
+}
+
+type LandlordProfileFormData struct {
+	GeneralError          string
+	Name                  string
+	NameError             string
+	CompanyID             string
+	CompanyIDError        string
+	VATID                 string
+	VATIDError            string
+	Address               string
+	AddressError          string
+	NumberingPattern      string
+	NumberingPatternError string
+}
+
+type ReadingRoundSubMeterFormData struct {
+	Subid             int32
+	MeterID           string
+	LastReadingDate   string
+	LastReadingValue  string
+	ReadingValue      string
+	ReadingValueError string
+	Warnings          []string
+}
+
+type ReadingRoundFormData struct {
+	GeneralError     string
+	ReadingDate      string
+	ReadingDateError string
+	SubMeters        []*ReadingRoundSubMeterFormData
+	Confirmed        bool
+}
+
+func (f ReadingRoundFormData) HasWarnings() bool {
+	for _, subMeter := range f.SubMeters {
+		if len(subMeter.Warnings) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type IntervalImportFormData struct {
+	GeneralError        string
+	TimeMark            string
+	TimeMarkError       string
+	ValueUnit           string
+	ValueUnitError      string
+	IntervalLength      string
+	IntervalLengthError string
+}
+
+func NewIntervalImportFormData() IntervalImportFormData {
+	return IntervalImportFormData{TimeMark: "end", ValueUnit: "energy", IntervalLength: "15"}
+}
+
+type DeviceFormData struct {
+	GeneralError string
+	Name         string
+	NameError    string
+}
+
+type WmbusKeyFormData struct {
+	SubMeterID int32
+	Subid      int32
+	MeterID    string
+	KeySet     bool
+	Key        string
+	KeyError   string
+	Remove     bool
 }
@@ -1,24 +1,38 @@
 package server
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"slices"
 	"sort"
+	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/svoboond/spinus/internal/blob"
 	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/dsmr"
+	"github.com/svoboond/spinus/internal/isdoc"
+	"github.com/svoboond/spinus/internal/pdf"
+	"github.com/svoboond/spinus/internal/totp"
 )
 
 const errorTmplName = "error"
 
+const maxUploadSize = 10 << 20
+
 type Upper struct {
 	UserLoggedIn bool
 }
@@ -105,20 +119,23 @@ func (s *Server) HandlePostSignUp(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	ipKey := signUpIPKey(clientIP(r))
+	lockout, err := s.getLockout(ctx, ipKey)
+	if err != nil {
+		slog.Error("error getting lockout", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if lockout > 0 {
+		formData.GeneralError = lockoutError(lockout)
+		s.renderTemplate(w, r, tmplName, formData)
+		return
+	}
 
 	iUsername := r.PostFormValue("username")
 	formData.Username = iUsername
 	username, err := parseUsername(iUsername)
-	if err == nil {
-		_, err := s.queries.GetUserByUsername(ctx, string(username))
-		if err == nil {
-			formData.UsernameError = "Username is already taken."
-			formError = true
-		} else if err != pgx.ErrNoRows {
-			s.HandleInternalServerError(w, r, err)
-			return
-		}
-	} else {
+	if err != nil {
 		formData.UsernameError = err.Error()
 		formError = true
 	}
@@ -126,16 +143,7 @@ func (s *Server) HandlePostSignUp(w http.ResponseWriter, r *http.Request) {
 	iEmail := r.PostFormValue("email")
 	formData.Email = iEmail
 	email, err := parseEmail(iEmail)
-	if err == nil {
-		_, err := s.queries.GetUserByEmail(ctx, string(email))
-		if err == nil {
-			formData.EmailError = "Email is already assigned to another account."
-			formError = true
-		} else if err != pgx.ErrNoRows {
-			s.HandleInternalServerError(w, r, err)
-			return
-		}
-	} else {
+	if err != nil {
 		formData.EmailError = err.Error()
 		formError = true
 	}
@@ -163,15 +171,101 @@ func (s *Server) HandlePostSignUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.recordFailure(ctx, ipKey); err != nil {
+		slog.Error("error recording failure", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	_, err = s.queries.GetUserByUsername(ctx, string(username))
+	if err == nil {
+		formData.UsernameError = "Username is taken."
+		s.renderTemplate(w, r, tmplName, formData)
+		return
+	}
+	if err != pgx.ErrNoRows {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	owner, err := s.queries.GetUserByEmail(ctx, string(email))
+	emailTaken := err == nil
+	if err != nil && err != pgx.ErrNoRows {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	ok, err := s.reserveUsername(ctx, username)
+	if err != nil {
+		slog.Error("error reserving username", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if !ok {
+		formData.UsernameError = "Username is taken."
+		s.renderTemplate(w, r, tmplName, formData)
+		return
+	}
+
+	// Taken email gets the same response as a new one, also in time, and its
+	// owner is told by email. Nobody is logged in until the link in the email
+	// is opened.
+	passwordHash, err := s.queries.HashPassword(ctx, string(password))
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if emailTaken {
+		if err := s.sendSignUpNotice(ctx, owner); err != nil {
+			slog.Error("error sending sign up notice", "err", err)
+		}
+	} else {
+		err := s.sendSignUp(
+			ctx,
+			pendingSignUp{
+				Username:     string(username),
+				Email:        string(email),
+				PasswordHash: passwordHash,
+			},
+		)
+		if err != nil {
+			slog.Error("error sending sign up", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+	}
+	s.renderTemplate(w, r, "signUpSent", SignUpSentTmplData{Email: string(email)})
+}
+
+func (s *Server) HandleGetSignUpVerify(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "signUpVerify"
+
+	ctx := r.Context()
+	signUp, ok, err := s.popPendingSignUp(ctx, r.URL.Query().Get("token"))
+	if err != nil {
+		slog.Error("error getting sign up", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if !ok {
+		s.renderTemplate(w, r, tmplName, nil)
+		return
+	}
 	user, err := s.queries.CreateUser(
 		ctx,
 		spinusdb.CreateUserParams{
-			Username: string(username),
-			Email:    string(email),
-			Crypt:    string(password),
+			Username: signUp.Username,
+			Email:    signUp.Email,
+			Password: signUp.PasswordHash,
 		},
 	)
 	if err != nil {
+		// Username or email was registered since the sign up.
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			s.renderTemplate(w, r, tmplName, nil)
+			return
+		}
 		slog.Error("error executing query", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
@@ -181,16 +275,8 @@ func (s *Server) HandlePostSignUp(w http.ResponseWriter, r *http.Request) {
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
+	s.removeTwoFactorLogIn(ctx)
 	s.sessionManager.Put(ctx, "userID", user.ID)
-
-	query := r.URL.Query()
-	next := query.Get("next")
-	if next != "" {
-		query.Del("next")
-		redirectUrl := url.URL{Path: next, RawQuery: query.Encode()}
-		http.Redirect(w, r, redirectUrl.String(), http.StatusSeeOther)
-		return
-	}
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -245,10 +331,27 @@ func (s *Server) HandlePostLogIn(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	ipKey, usernameKey := logInIPKey(clientIP(r)), logInUsernameKey(username)
+	lockout, err := s.getLockout(ctx, ipKey, usernameKey)
+	if err != nil {
+		slog.Error("error getting lockout", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if lockout > 0 {
+		formData.GeneralError = lockoutError(lockout)
+		s.renderTemplate(w, r, tmplName, formData)
+		return
+	}
 	user, err := s.queries.GetUser(
 		ctx, spinusdb.GetUserParams{Username: string(username), Crypt: string(password)})
 	if err != nil {
 		if err == pgx.ErrNoRows {
+			if err := s.recordFailure(ctx, ipKey, usernameKey); err != nil {
+				slog.Error("error recording failure", "err", err)
+				s.HandleInternalServerError(w, r, err)
+				return
+			}
 			formData.GeneralError = "Wrong username or password."
 			s.renderTemplate(w, r, tmplName, formData)
 		} else {
@@ -257,11 +360,38 @@ func (s *Server) HandlePostLogIn(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	var twoFactor bool
+	userTotp, err := s.queries.GetUserTotp(ctx, user.ID)
+	if err == nil {
+		twoFactor = userTotp.EnabledAt.Valid
+	} else if err != pgx.ErrNoRows {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
 	if err := s.sessionManager.RenewToken(ctx); err != nil {
 		slog.Error("error renewing token", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
+	if twoFactor {
+		s.sessionManager.Remove(ctx, "userID")
+		s.sessionManager.Put(ctx, twoFactorUserIDKey, user.ID)
+		s.sessionManager.Put(ctx, twoFactorUsernameKey, string(username))
+		s.sessionManager.Put(
+			ctx, twoFactorExpiresAtKey, time.Now().Add(twoFactorLoginTTL).Unix())
+		s.sessionManager.Put(ctx, twoFactorAttemptsKey, 0)
+		redirectUrl := url.URL{Path: "/login/two-factor", RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, redirectUrl.String(), http.StatusSeeOther)
+		return
+	}
+	// Failures are reset only after the full login, so failed second factors
+	// of the account add up across logins with the right password.
+	if err := s.resetFailures(ctx, usernameKey); err != nil {
+		slog.Error("error resetting failures", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
 	s.sessionManager.Put(ctx, "userID", user.ID)
 
 	query := r.URL.Query()
@@ -275,143 +405,138 @@ func (s *Server) HandlePostLogIn(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func (s *Server) HandleGetMainMeterList(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "mainMeterList"
+func (s *Server) HandleGetLogInTwoFactor(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "logInTwoFactor"
 
-	ctx := r.Context()
-	userID, ok := UserID(ctx)
-	if !ok {
-		slog.Error("error getting user ID", "userID", userID)
-		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
-		return
-	}
-	mainMeters, err := s.queries.ListMainMeters(r.Context(), userID)
-	if err != nil {
-		slog.Error("error executing query", "err", err)
-		s.HandleInternalServerError(w, r, err)
+	if s.sessionManager.GetInt32(r.Context(), twoFactorUserIDKey) == emptyUserIDValue {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
-
-	s.renderTemplate(w, r, tmplName, mainMeters)
-}
-
-func (s *Server) HandleGetMainMeterCreate(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "mainMeterCreate"
 	s.renderTemplate(w, r, tmplName, nil)
 }
 
-func (s *Server) HandlePostMainMeterCreate(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "mainMeterCreate"
-	formData := MainMeterFormData{}
-	var formError bool
+func (s *Server) HandlePostLogInTwoFactor(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "logInTwoFactor"
+
+	ctx := r.Context()
+	userID := s.sessionManager.GetInt32(ctx, twoFactorUserIDKey)
+	expiresAt := time.Unix(s.sessionManager.GetInt64(ctx, twoFactorExpiresAtKey), 0)
+	if userID == emptyUserIDValue || time.Now().After(expiresAt) {
+		s.removeTwoFactorLogIn(ctx)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	formData := TwoFactorFormData{}
 	if err := r.ParseForm(); err != nil {
 		slog.Error("error parsing form", "err", err)
 		formData.GeneralError = "Bad request"
-		if err := s.templates.Render(w, tmplName, formData); err != nil {
-			slog.Error("error rendering template", "template", tmplName, "err", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		s.renderTemplate(w, r, tmplName, formData)
 		return
 	}
-
-	iMeterID := r.PostFormValue("meter-identification")
-	formData.MeterID = iMeterID
-	meterID, err := parseMainMeterID(iMeterID)
+	code, err := parseTwoFactorCode(r.PostFormValue("code"))
 	if err != nil {
-		formData.MeterIDError = err.Error()
-		formError = true
+		formData.CodeError = err.Error()
+		s.renderTemplate(w, r, tmplName, formData)
+		return
 	}
 
-	iEnergy := r.PostFormValue("energy")
-	formData.Energy = iEnergy
-	energy, err := parseEnergy(iEnergy)
+	ipKey := logInIPKey(clientIP(r))
+	usernameKey := logInUsernameKey(
+		Username(s.sessionManager.GetString(ctx, twoFactorUsernameKey)))
+	lockout, err := s.getLockout(ctx, ipKey, usernameKey)
 	if err != nil {
-		formData.EnergyError = err.Error()
-		formError = true
+		slog.Error("error getting lockout", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if lockout > 0 {
+		formData.GeneralError = lockoutError(lockout)
+		s.renderTemplate(w, r, tmplName, formData)
+		return
 	}
 
-	iAddress := r.PostFormValue("address")
-	formData.Address = iAddress
-	address, err := parseAddress(iAddress)
+	userTotp, err := s.queries.GetUserTotp(ctx, userID)
 	if err != nil {
-		formData.AddressError = err.Error()
-		formError = true
+		if err == pgx.ErrNoRows {
+			s.removeTwoFactorLogIn(ctx)
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		} else {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+		}
+		return
 	}
-
-	if formError {
+	ok, err := s.checkTwoFactorCode(ctx, userTotp, code)
+	if err != nil {
+		slog.Error("error checking two-factor code", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if !ok {
+		if err := s.recordFailure(ctx, ipKey, usernameKey); err != nil {
+			slog.Error("error recording failure", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		attempts := s.sessionManager.GetInt(ctx, twoFactorAttemptsKey) + 1
+		if attempts >= maxTwoFactorAttempts {
+			s.removeTwoFactorLogIn(ctx)
+			formData := LogInFormData{GeneralError: "Too many wrong codes, log in again."}
+			s.renderTemplate(w, r, "logIn", formData)
+			return
+		}
+		s.sessionManager.Put(ctx, twoFactorAttemptsKey, attempts)
+		formData.CodeError = "Wrong code."
 		s.renderTemplate(w, r, tmplName, formData)
 		return
 	}
 
-	ctx := r.Context()
-	userID, ok := UserID(ctx)
-	if !ok {
-		slog.Error("error getting user ID", "userID", userID)
-		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+	if err := s.resetFailures(ctx, usernameKey); err != nil {
+		slog.Error("error resetting failures", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
 	}
-	mainMeter, err := s.queries.CreateMainMeter(
-		ctx,
-		spinusdb.CreateMainMeterParams{
-			MeterID: string(meterID),
-			Energy:  energy,
-			Address: string(address),
-			FkUser:  userID,
-		},
-	)
-	if err != nil {
-		slog.Error("error executing query", "err", err)
+	if err := s.sessionManager.RenewToken(ctx); err != nil {
+		slog.Error("error renewing token", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
+	s.removeTwoFactorLogIn(ctx)
+	s.sessionManager.Put(ctx, "userID", userID)
 
-	http.Redirect(
-		w, r, fmt.Sprintf("/main-meter/%d/overview", mainMeter.ID), http.StatusSeeOther)
-}
-
-func (s *Server) HandleGetMainMeterOverview(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "mainMeterOverview"
-
-	ctx := r.Context()
-	mainMeter, ok := GetMainMeter(ctx)
-	if !ok {
-		slog.Error("error getting main meter", "mainMeter", mainMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+	query := r.URL.Query()
+	next := query.Get("next")
+	if next != "" {
+		query.Del("next")
+		redirectUrl := url.URL{Path: next, RawQuery: query.Encode()}
+		http.Redirect(w, r, redirectUrl.String(), http.StatusSeeOther)
 		return
 	}
-	s.renderTemplate(
-		w, r,
-		tmplName,
-		MainMeterOverviewTmplData{
-			GetMainMeterRow: mainMeter,
-			Upper:           MainMeterTmplData{ID: mainMeter.ID},
-		},
-	)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func (s *Server) HandleGetSubMeterCreate(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "subMeterCreate"
+func (s *Server) HandleGetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "twoFactor"
 
 	ctx := r.Context()
-	mainMeter, ok := GetMainMeter(ctx)
+	userID, ok := UserID(ctx)
 	if !ok {
-		slog.Error("error getting main meter", "mainMeter", mainMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
 		return
 	}
-	s.renderTemplate(
-		w, r,
-		tmplName,
-		SubMeterCreateTmplData{
-			SubMeterFormData: SubMeterFormData{},
-			Upper:            MainMeterTmplData{ID: mainMeter.ID},
-		},
-	)
+	tmplData, err := s.newTwoFactorTmplData(ctx, userID)
+	if err != nil {
+		slog.Error("error getting two-factor data", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.renderTemplate(w, r, tmplName, tmplData)
 }
 
-func (s *Server) HandlePostSubMeterCreate(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "subMeterCreate"
+func (s *Server) HandlePostTwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "twoFactorSetup"
 
 	ctx := r.Context()
 	userID, ok := UserID(ctx)
@@ -420,1075 +545,4500 @@ func (s *Server) HandlePostSubMeterCreate(w http.ResponseWriter, r *http.Request
 		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
 		return
 	}
-	mainMeter, ok := GetMainMeter(ctx)
-	if !ok {
-		slog.Error("error getting main meter", "mainMeter", mainMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
-		return
-	}
-
-	tmplData := SubMeterCreateTmplData{
-		SubMeterFormData: SubMeterFormData{},
-		Upper:            MainMeterTmplData{ID: mainMeter.ID},
-	}
-	var formError bool
-	if err := r.ParseForm(); err != nil {
-		slog.Error("error parsing form", "err", err)
-		tmplData.GeneralError = "Bad request"
-		if err := s.templates.Render(w, tmplName, tmplData); err != nil {
-			slog.Error("error rendering template", "template", tmplName, "err", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	user, err := s.queries.GetUserByID(ctx, userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
 	}
-
-	meterID := r.PostFormValue("meter-identification")
-	tmplData.MeterID = meterID
-	subMeterID, err := parseSubMeterID(meterID)
+	secret, err := totp.GenerateSecret()
 	if err != nil {
-		tmplData.MeterIDError = err.Error()
-		formError = true
+		slog.Error("error generating secret", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
 	}
-
-	if formError {
-		s.renderTemplate(w, r, tmplName, tmplData)
+	// Enabled two-factor authentication is not replaced.
+	err = s.queries.UpsertUserTotp(
+		ctx, spinusdb.UpsertUserTotpParams{FkUser: userID, Secret: secret})
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
 	}
-
-	_, err = s.queries.CreateSubMeter(
-		ctx,
-		spinusdb.CreateSubMeterParams{
-			FkMainMeter: mainMeter.ID,
-			MeterID:     pgtype.Text{String: string(subMeterID), Valid: true},
-			FkUser:      userID,
-		},
-	)
+	userTotp, err := s.queries.GetUserTotp(ctx, userID)
 	if err != nil {
 		slog.Error("error executing query", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
-
-	http.Redirect(
-		w, r,
-		fmt.Sprintf("/main-meter/%d/sub-meter/list", mainMeter.ID), http.StatusSeeOther,
-	)
+	if userTotp.EnabledAt.Valid {
+		http.Redirect(w, r, "/two-factor", http.StatusSeeOther)
+		return
+	}
+	tmplData, err := s.newTwoFactorSetupTmplData(user, userTotp.Secret)
+	if err != nil {
+		slog.Error("error creating QR code", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.renderTemplate(w, r, tmplName, tmplData)
 }
 
-func (s *Server) HandleGetSubMeterList(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "subMeterList"
+func (s *Server) HandlePostTwoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	const (
+		tmplName              = "twoFactorSetup"
+		recoveryCodesTmplName = "twoFactorRecoveryCodes"
+	)
 
 	ctx := r.Context()
-	mainMeter, ok := GetMainMeter(ctx)
+	userID, ok := UserID(ctx)
 	if !ok {
-		slog.Error("error getting main meter", "mainMeter", mainMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
 		return
 	}
-
-	mainMeterID := mainMeter.ID
-	subMeters, err := s.queries.ListSubMeters(r.Context(), mainMeterID)
+	user, err := s.queries.GetUserByID(ctx, userID)
 	if err != nil {
 		slog.Error("error executing query", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
-
-	s.renderTemplate(
-		w, r,
-		tmplName,
-		SubMeterListTmplData{
-			SubMeters: subMeters,
-			Upper:     MainMeterTmplData{ID: mainMeterID},
-		},
-	)
-}
-
-func (s *Server) HandleGetSubMeterOverview(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "subMeterOverview"
-
-	ctx := r.Context()
-	subMeter, ok := GetSubMeter(ctx)
-	if !ok {
-		slog.Error("error getting sub meter", "subMeter", subMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+	userTotp, err := s.queries.GetUserTotp(ctx, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			http.Redirect(w, r, "/two-factor", http.StatusSeeOther)
+		} else {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+		}
+		return
+	}
+	if userTotp.EnabledAt.Valid {
+		http.Redirect(w, r, "/two-factor", http.StatusSeeOther)
+		return
+	}
+	tmplData, err := s.newTwoFactorSetupTmplData(user, userTotp.Secret)
+	if err != nil {
+		slog.Error("error creating QR code", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
 	}
 
-	s.renderTemplate(
-		w, r,
-		tmplName,
-		SubMeterOverviewTmplData{
-			GetSubMeterRow: subMeter,
-			Upper: SubMeterTmplData{
-				MainMeterID: subMeter.MainMeterID, Subid: subMeter.Subid},
-		},
-	)
-}
-
-func (s *Server) HandleGetSubMeterReadingList(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "subMeterReadingList"
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	code, err := parseTwoFactorCode(r.PostFormValue("code"))
+	if err != nil {
+		tmplData.CodeError = err.Error()
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	counter, valid := totp.Validate(userTotp.Secret, string(code), time.Now())
+	if !valid {
+		tmplData.CodeError = "Wrong code, check time of the device with authenticator app."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
 
-	ctx := r.Context()
-	subMeter, ok := GetSubMeter(ctx)
-	if !ok {
-		slog.Error("error getting sub meter", "subMeter", subMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
 	}
-	subMeterSubid := subMeter.Subid
-	subMeterReadings, err := s.queries.ListSubMeterReadings(r.Context(), subMeterSubid)
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	n, err := qtx.EnableUserTotp(
+		ctx, spinusdb.EnableUserTotpParams{FkUser: userID, LastCounter: counter})
 	if err != nil {
 		slog.Error("error executing query", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
-	s.renderTemplate(
-		w, r,
-		tmplName,
-		SubMeterReadingListTmplData{
-			SubMeterReadings: subMeterReadings,
-			Upper: SubMeterTmplData{
-				MainMeterID: subMeter.MainMeterID, Subid: subMeterSubid},
-		},
-	)
-}
-
-func (s *Server) HandleGetSubMeterReadingCreate(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "subMeterReadingCreate"
-
-	ctx := r.Context()
-	subMeter, ok := GetSubMeter(ctx)
-	if !ok {
-		slog.Error("error getting sub meter", "subMeter", subMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+	if n == 0 {
+		http.Redirect(w, r, "/two-factor", http.StatusSeeOther)
+		return
+	}
+	recoveryCodes, err := replaceRecoveryCodes(ctx, qtx, userID)
+	if err != nil {
+		slog.Error("error creating recovery codes", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
 	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
 	s.renderTemplate(
-		w, r,
-		tmplName,
-		SubMeterReadingCreateTmplData{
-			SubMeterReadingFormData: SubMeterReadingFormData{},
-			Upper: SubMeterTmplData{
-				MainMeterID: subMeter.MainMeterID, Subid: subMeter.Subid},
-		},
-	)
+		w, r, recoveryCodesTmplName,
+		TwoFactorRecoveryCodesTmplData{RecoveryCodes: recoveryCodes})
 }
 
-func (s *Server) HandlePostSubMeterReadingCreate(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "subMeterReadingCreate"
+func (s *Server) HandlePostTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "twoFactor"
 
 	ctx := r.Context()
-	subMeter, ok := GetSubMeter(ctx)
+	userID, ok := UserID(ctx)
 	if !ok {
-		slog.Error("error getting sub meter", "subMeter", subMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
 		return
 	}
-
-	mainMeterID := subMeter.MainMeterID
-	subMeterSubid := subMeter.Subid
-	tmplData := SubMeterReadingCreateTmplData{
-		SubMeterReadingFormData: SubMeterReadingFormData{},
-		Upper: SubMeterTmplData{
-			MainMeterID: mainMeterID, Subid: subMeterSubid},
+	tmplData, err := s.newTwoFactorTmplData(ctx, userID)
+	if err != nil {
+		slog.Error("error getting two-factor data", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if !tmplData.Enabled {
+		http.Redirect(w, r, "/two-factor", http.StatusSeeOther)
+		return
 	}
 	var formError bool
 	if err := r.ParseForm(); err != nil {
 		slog.Error("error parsing form", "err", err)
 		tmplData.GeneralError = "Bad request"
-		if err := s.templates.Render(w, tmplName, tmplData); err != nil {
-			slog.Error("error rendering template", "template", tmplName, "err", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		s.renderTemplate(w, r, tmplName, tmplData)
 		return
 	}
-
-	iReadingVal := r.PostFormValue("reading-value")
-	tmplData.ReadingValue = iReadingVal
-	readingVal, err := parseReadingValue(iReadingVal)
+	password, err := parsePassword(r.PostFormValue("password"))
 	if err != nil {
-		tmplData.ReadingValueError = err.Error()
+		tmplData.PasswordError = err.Error()
 		formError = true
 	}
-
-	subMeterID := subMeter.ID
-	iReadingDate := r.PostFormValue("reading-date")
-	tmplData.ReadingDate = iReadingDate
-	readingTime, err := parseDate(iReadingDate)
-	readingDate := pgtype.Date{Time: readingTime.Time, Valid: true}
-	if err == nil {
-		_, err = s.queries.GetSubMeterReadingForDate(
-			ctx,
-			spinusdb.GetSubMeterReadingForDateParams{
-				FkSubMeter:  subMeterID,
-				ReadingDate: readingDate,
-			},
-		)
-		if err == nil {
-			tmplData.ReadingDateError = "Reading for the given date already exists."
-			formError = true
-		} else if err != pgx.ErrNoRows {
-			s.HandleInternalServerError(w, r, err)
-			return
-		}
-	} else {
-		tmplData.ReadingDateError = err.Error()
+	code, err := parseTwoFactorCode(r.PostFormValue("code"))
+	if err != nil {
+		tmplData.CodeError = err.Error()
 		formError = true
 	}
-
 	if formError {
 		s.renderTemplate(w, r, tmplName, tmplData)
 		return
 	}
 
-	_, err = s.queries.CreateSubMeterReading(
-		ctx,
-		spinusdb.CreateSubMeterReadingParams{
-			FkSubMeter:   subMeter.ID,
-			ReadingValue: float64(readingVal),
-			ReadingDate:  readingDate,
-		},
-	)
+	// Disabling requires both factors again, so an unattended session is not
+	// enough.
+	user, err := s.queries.GetUserByID(ctx, userID)
 	if err != nil {
 		slog.Error("error executing query", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
-
-	http.Redirect(
-		w, r,
-		fmt.Sprintf(
-			"/main-meter/%d/sub-meter/%d/reading/list", mainMeterID, subMeterSubid),
-		http.StatusSeeOther,
-	)
-}
-
-func (s *Server) HandleGetMainMeterBillingList(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "mainMeterBillingList"
-
-	ctx := r.Context()
-	mainMeter, ok := GetMainMeter(ctx)
-	if !ok {
-		slog.Error("error getting main meter", "mainMeter", mainMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+	_, err = s.queries.GetUser(
+		ctx, spinusdb.GetUserParams{Username: user.Username, Crypt: string(password)})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			tmplData.PasswordError = "Wrong password."
+			s.renderTemplate(w, r, tmplName, tmplData)
+		} else {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+		}
 		return
 	}
-	mainMeterID := mainMeter.ID
-	s.renderTemplate(
-		w, r, tmplName,
-		MainMeterBillingListTmplData{Upper: MainMeterTmplData{ID: mainMeterID}},
-	)
+	userTotp, err := s.queries.GetUserTotp(ctx, userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	ok, err = s.checkTwoFactorCode(ctx, userTotp, code)
+	if err != nil {
+		slog.Error("error checking two-factor code", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if !ok {
+		tmplData.CodeError = "Wrong code."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	if err := qtx.DeleteUserRecoveryCodes(ctx, userID); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if err := qtx.DeleteUserTotp(ctx, userID); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/two-factor", http.StatusSeeOther)
 }
 
-func (s *Server) HandleGetMainMeterBillingCreate(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "mainMeterBillingCreate"
+func (s *Server) HandlePostLogInPasskeyBegin(w http.ResponseWriter, r *http.Request) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		slog.Error("error beginning passkey login", "err", err)
+		writeInternalError(w)
+		return
+	}
+	if err := s.putPasskeySession(r.Context(), passkeyLogInKey, session); err != nil {
+		slog.Error("error putting passkey session", "err", err)
+		writeInternalError(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, assertion)
+}
 
+func (s *Server) HandlePostLogInPasskeyFinish(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	mainMeter, ok := GetMainMeter(ctx)
+	session, ok := s.popPasskeySession(ctx, passkeyLogInKey)
 	if !ok {
-		slog.Error("error getting main meter", "mainMeter", mainMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		writeJSON(
+			w, http.StatusBadRequest, APIErrorResponse{Error: "Start logging in again."})
 		return
 	}
-	s.renderTemplate(
-		w, r,
-		tmplName,
-		MainMeterBillingCreateTmplData{
-			MainMeterBillingFormData: NewMainMeterBillingFormData(),
-			Upper:                    MainMeterTmplData{ID: mainMeter.ID},
+	var user *passkeyUser
+	credential, err := s.webAuthn.FinishDiscoverableLogin(
+		func(_, userHandle []byte) (webauthn.User, error) {
+			var err error
+			user, err = s.getDiscoverablePasskeyUser(ctx, userHandle)
+			return user, err
+		},
+		session, r,
+	)
+	if err != nil {
+		slog.Info("passkey not verified", "err", err)
+		writeJSON(
+			w, http.StatusUnauthorized,
+			APIErrorResponse{Error: "Passkey could not be verified."})
+		return
+	}
+	// Counter going back means the passkey may have been copied.
+	if credential.Authenticator.CloneWarning {
+		slog.Warn("passkey counter did not increase", "credentialID", credential.ID)
+		writeJSON(
+			w, http.StatusUnauthorized,
+			APIErrorResponse{Error: "Passkey could not be verified."})
+		return
+	}
+	err = s.queries.UpdateWebauthnCredentialUse(
+		ctx,
+		spinusdb.UpdateWebauthnCredentialUseParams{
+			CredentialID: credential.ID,
+			SignCount:    int64(credential.Authenticator.SignCount),
+			BackupState:  credential.Flags.BackupState,
 		},
 	)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		writeInternalError(w)
+		return
+	}
+
+	if err := s.sessionManager.RenewToken(ctx); err != nil {
+		slog.Error("error renewing token", "err", err)
+		writeInternalError(w)
+		return
+	}
+	s.removeTwoFactorLogIn(ctx)
+	s.sessionManager.Put(ctx, "userID", user.user.ID)
+
+	query := r.URL.Query()
+	redirectUrl := url.URL{Path: "/"}
+	if next := query.Get("next"); next != "" {
+		query.Del("next")
+		redirectUrl = url.URL{Path: next, RawQuery: query.Encode()}
+	}
+	writeJSON(w, http.StatusOK, PasskeyLogInResponse{Redirect: redirectUrl.String()})
 }
 
-func (s *Server) HandlePostMainMeterBillingCreate(w http.ResponseWriter, r *http.Request) {
-	const tmplName = "mainMeterBillingCreate"
+func (s *Server) HandleGetPasskeyList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "passkeyList"
 
 	ctx := r.Context()
-	mainMeter, ok := GetMainMeter(ctx)
+	userID, ok := UserID(ctx)
 	if !ok {
-		slog.Error("error getting main meter", "mainMeter", mainMeter)
-		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	passkeys, err := s.queries.ListUserWebauthnCredentials(ctx, userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
 	}
+	s.renderTemplate(w, r, tmplName, PasskeyListTmplData{Passkeys: passkeys})
+}
 
-	mainMeterID := mainMeter.ID
+func (s *Server) HandlePostPasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		writeInternalError(w)
+		return
+	}
+	user, err := s.getPasskeyUser(ctx, userID)
+	if err != nil {
+		slog.Error("error getting passkey user", "err", err)
+		writeInternalError(w)
+		return
+	}
+	// Authenticator with a passkey of the user does not create another one.
+	var exclusions []protocol.CredentialDescriptor
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	creation, session, err := s.webAuthn.BeginRegistration(
+		user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		slog.Error("error beginning passkey registration", "err", err)
+		writeInternalError(w)
+		return
+	}
+	if err := s.putPasskeySession(ctx, passkeyRegistrationKey, session); err != nil {
+		slog.Error("error putting passkey session", "err", err)
+		writeInternalError(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, creation)
+}
 
-	var mainMeterbillingPeriodForms []*MainMeterBillingPeriodFormData
-	tmplData := MainMeterBillingCreateTmplData{
-		MainMeterBillingFormData: MainMeterBillingFormData{
-			BillingPeriods: mainMeterbillingPeriodForms},
-		Upper: MainMeterTmplData{ID: mainMeterID},
+func (s *Server) HandlePostPasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		writeInternalError(w)
+		return
 	}
-	var formError bool
+	name, err := parsePasskeyName(r.URL.Query().Get("name"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIErrorResponse{Error: err.Error()})
+		return
+	}
+	session, ok := s.popPasskeySession(ctx, passkeyRegistrationKey)
+	if !ok {
+		writeJSON(
+			w, http.StatusBadRequest,
+			APIErrorResponse{Error: "Start adding the passkey again."})
+		return
+	}
+	user, err := s.getPasskeyUser(ctx, userID)
+	if err != nil {
+		slog.Error("error getting passkey user", "err", err)
+		writeInternalError(w)
+		return
+	}
+	credential, err := s.webAuthn.FinishRegistration(user, session, r)
+	if err != nil {
+		slog.Info("passkey not verified", "err", err)
+		writeJSON(
+			w, http.StatusBadRequest,
+			APIErrorResponse{Error: "Passkey could not be verified."})
+		return
+	}
+	err = s.queries.CreateWebauthnCredential(
+		ctx, newCreateWebauthnCredentialParams(userID, credential, name))
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		writeInternalError(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) HandlePostPasskeyDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "passkeyID"), 10, 32)
+	if err != nil {
+		s.HandleNotFound(w, r)
+		return
+	}
+	passkeyID := int32(id)
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+
+	err = s.queries.DeleteWebauthnCredential(
+		ctx, spinusdb.DeleteWebauthnCredentialParams{ID: passkeyID, FkUser: userID})
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/passkey/list", http.StatusSeeOther)
+}
+
+func (s *Server) HandleGetPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "passwordForgot"
+	s.renderTemplate(w, r, tmplName, nil)
+}
+
+func (s *Server) HandlePostPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "passwordForgot"
+
+	tmplData := PasswordForgotTmplData{}
 	if err := r.ParseForm(); err != nil {
 		slog.Error("error parsing form", "err", err)
 		tmplData.GeneralError = "Bad request"
-		if err := s.templates.Render(w, tmplName, tmplData); err != nil {
-			slog.Error("error rendering template", "template", tmplName, "err", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		s.renderTemplate(w, r, tmplName, tmplData)
 		return
 	}
 
-	var addBillingPeriod bool
-	if r.PostFormValue("add-billing-period") != "" {
-		addBillingPeriod = true
+	iEmail := r.PostFormValue("email")
+	tmplData.Email = iEmail
+	email, err := parseEmail(iEmail)
+	if err != nil {
+		tmplData.EmailError = err.Error()
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
 	}
-	var removeBillingPeriod bool
-	if r.PostFormValue("remove-billing-period") != "" {
-		removeBillingPeriod = true
+
+	ctx := r.Context()
+	tmplData.Sent = true
+	user, err := s.queries.GetUserByEmail(ctx, string(email))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			s.renderTemplate(w, r, tmplName, tmplData)
+		} else {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+		}
+		return
 	}
-	var parse bool
-	if !addBillingPeriod && !removeBillingPeriod {
-		parse = true
+	token, tokenHash, err := newToken(passwordResetTokenPrefix)
+	if err != nil {
+		slog.Error("error creating token", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	err = s.queries.CreatePasswordResetToken(
+		ctx,
+		spinusdb.CreatePasswordResetTokenParams{
+			FkUser:    user.ID,
+			TokenHash: tokenHash,
+			ExpiresAt: pgtype.Timestamptz{
+				Time: time.Now().Add(passwordResetTokenTTL), Valid: true},
+		},
+	)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
 	}
+	s.sendMail(s.newPasswordResetMessage(user, token))
+	s.renderTemplate(w, r, tmplName, tmplData)
+}
 
-	mainMeterBilling := spinusdb.CreateMainMeterBillingParams{FkMainMeter: mainMeterID}
+func (s *Server) HandleGetPasswordReset(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "passwordReset"
 
-	iMaxDayDiff := r.PostFormValue("max-day-diff")
-	tmplData.MaxDayDiff = iMaxDayDiff
-	maxDayDiff, err := parseMaxDayDiff(iMaxDayDiff)
-	dayDiff := int(maxDayDiff)
+	// Token must not leak to other sites.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	tmplData := PasswordResetTmplData{}
+	token := r.URL.Query().Get("token")
+	_, err := s.queries.GetPasswordResetToken(r.Context(), hashToken(token))
 	if err != nil {
-		tmplData.MaxDayDiffError = err.Error()
-		formError = true
+		if err == pgx.ErrNoRows {
+			tmplData.InvalidToken = true
+			s.renderTemplate(w, r, tmplName, tmplData)
+		} else {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+		}
+		return
 	}
-	mainMeterBilling.MaxDayDiff = int32(maxDayDiff)
+	tmplData.Token = token
+	s.renderTemplate(w, r, tmplName, tmplData)
+}
 
-	// From latest to earliest.
-	var mainMeterBillingPeriods []*spinusdb.CreateMainMeterBillingPeriodParams
-	var calcBreakPoints BreakPoints // From latest to earliest.
+func (s *Server) HandlePostPasswordReset(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "passwordReset"
 
-	iBeginDates := r.PostForm["begin-date"]
-	iEndDates := r.PostForm["end-date"]
-	iBeginReadingVals := r.PostForm["begin-reading-value"]
-	iEndReadingVals := r.PostForm["end-reading-value"]
-	iConsumedEnergyPrices := r.PostForm["consumed-energy-price"]
-	iServicePrices := r.PostForm["service-price"]
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	tmplData := PasswordResetTmplData{}
+	var formError bool
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
 
-	billingPeriodsLen := len(iBeginDates)
-	if billingPeriodsLen == 0 {
-		tmplData.GeneralError = "No billing period provided."
+	tmplData.Token = r.PostFormValue("token")
+	password, passwordErr := parsePassword(r.PostFormValue("password"))
+	if passwordErr != nil {
+		tmplData.PasswordError = passwordErr.Error()
+		formError = true
+	}
+	repeatPassword, repeatPasswordErr := parsePassword(r.PostFormValue("repeat-password"))
+	if repeatPasswordErr != nil {
+		tmplData.RepeatPasswordError = repeatPasswordErr.Error()
+		formError = true
+	}
+	if passwordErr == nil &&
+		repeatPasswordErr == nil &&
+		password != repeatPassword {
+
+		tmplData.PasswordError = "Passwords do not match."
+		formError = true
+	}
+
+	if formError {
 		s.renderTemplate(w, r, tmplName, tmplData)
 		return
 	}
-	billingPeriodsLastIndex := billingPeriodsLen - 1
-	var mainMeterBillingMinTime time.Time // Shifted one day back.
-	var mainMeterBillingMaxTime time.Time
 
-	mmBillingPeriodIndex := 0
-	for i := billingPeriodsLastIndex; i >= 0; i-- {
-		mainMeterBillingPeriodForm := &MainMeterBillingPeriodFormData{}
-		tmplData.BillingPeriods = append(
-			tmplData.BillingPeriods, mainMeterBillingPeriodForm)
-		iBeginDate := iBeginDates[i]
-		mainMeterBillingPeriodForm.BeginDate = iBeginDate
-		iEndDate := iEndDates[i]
-		mainMeterBillingPeriodForm.EndDate = iEndDate
-		iBeginReadingVal := iBeginReadingVals[i]
-		mainMeterBillingPeriodForm.BeginReadingValue = iBeginReadingVal
-		iEndReadingVal := iEndReadingVals[i]
-		mainMeterBillingPeriodForm.EndReadingValue = iEndReadingVal
-		iConsumedEnergyPrice := iConsumedEnergyPrices[i]
-		mainMeterBillingPeriodForm.ConsumedEnergyPrice = iConsumedEnergyPrice
-		iServicePrice := iServicePrices[i]
-		mainMeterBillingPeriodForm.ServicePrice = iServicePrice
-		if parse {
-			mainMeterBillingPeriod := &spinusdb.CreateMainMeterBillingPeriodParams{}
-			mainMeterBillingPeriods = append(
-				mainMeterBillingPeriods, mainMeterBillingPeriod)
-			beginTime, err := parseDate(iBeginDate)
-			if err != nil {
-				mainMeterBillingPeriodForm.BeginDateError = err.Error()
-				formError = true
-			}
-			endTime, err := parseDate(iEndDate)
-			if err != nil {
-				mainMeterBillingPeriodForm.EndDateError = err.Error()
-				formError = true
-			}
-			beginReadingVal, err := parseReadingValue(iBeginReadingVal)
-			if err != nil {
-				mainMeterBillingPeriodForm.BeginReadingValueError = err.Error()
-				formError = true
-			}
-			endReadingVal, err := parseReadingValue(iEndReadingVal)
-			if err != nil {
-				mainMeterBillingPeriodForm.EndReadingValueError = err.Error()
-				formError = true
-			}
-			consumedEnergyPrice, err := parseConsumedEnergyPrice(iConsumedEnergyPrice)
-			if err != nil {
-				mainMeterBillingPeriodForm.ConsumedEnergyPriceError = err.Error()
-				formError = true
-			}
-			servicePrice, err := parseServicePrice(iServicePrice)
-			if err != nil {
-				mainMeterBillingPeriodForm.ServicePriceError = err.Error()
-				formError = true
-			}
-			if !formError {
-				if i != billingPeriodsLastIndex {
-					laterIndex := mmBillingPeriodIndex - 1
-					previousBeginDate :=
-						mainMeterBillingPeriods[laterIndex].BeginDate
-					if endTime.AddDate(0, 0, 1) !=
-						previousBeginDate.Time {
-
-						laterBillingPeriod :=
-							tmplData.BillingPeriods[laterIndex]
-						laterBillingPeriod.BeginDateError =
-							"Begin date must follow previous " +
-								"billing period's end date."
-						formError = true
-						mmBillingPeriodIndex++
-						continue
-					}
-				}
-				mainMeterBillingPeriod.BeginDate = pgtype.Date{
-					Time: beginTime.Time, Valid: true}
-				if endTime.Time.Before(beginTime.Time) {
-					mainMeterBillingPeriodForm.EndDateError =
-						"End date must be greater or equal to begin date."
-					formError = true
-					mmBillingPeriodIndex++
-					continue
-				}
-				calcBreakPoints = append(
-					calcBreakPoints,
-					[3]time.Time{
-						endTime.AddDate(0, 0, -dayDiff),
-						endTime.Time,
-						endTime.AddDate(0, 0, dayDiff),
-					},
-				)
-				// Shift begin time one day back,
-				// so that eg. January end date minus begin date is 31 days.
-				shiftedBeginTime := beginTime.AddDate(0, 0, -1)
-				minTime := shiftedBeginTime.AddDate(0, 0, -dayDiff)
-				calcBreakPoints = append(
-					calcBreakPoints,
-					[3]time.Time{
-						minTime,
-						shiftedBeginTime,
-						beginTime.AddDate(0, 0, dayDiff),
-					},
-				)
-				mainMeterBillingPeriod.EndDate = pgtype.Date{
-					Time: endTime.Time, Valid: true}
-				mainMeterBillingPeriod.BeginReadingValue =
-					float64(beginReadingVal)
-				mainMeterBillingPeriod.EndReadingValue = float64(endReadingVal)
-				energyConsumption :=
-					float64(endReadingVal) - float64(beginReadingVal)
-				mainMeterBillingPeriod.EnergyConsumption = energyConsumption
-				mainMeterBilling.EnergyConsumption += energyConsumption
-				mainMeterBillingPeriod.ConsumedEnergyPrice =
-					float64(consumedEnergyPrice)
-				mainMeterBillingPeriod.ServicePrice = pgtype.Float8{
-					Float64: servicePrice.Float64, Valid: servicePrice.Valid}
-				totalPrice := float64(consumedEnergyPrice)
-				if servicePrice.Valid {
-					totalPrice += servicePrice.Float64
-					mainMeterBilling.ServicePrice.Float64 +=
-						servicePrice.Float64
-					mainMeterBilling.ServicePrice.Valid = true
-				}
-				mainMeterBillingPeriod.TotalPrice = totalPrice
-				if i == 0 {
-					mainMeterBilling.BeginDate = pgtype.Date{
-						Time: beginTime.Time, Valid: true}
-					mainMeterBillingMinTime = minTime
-				}
-				if i == billingPeriodsLastIndex {
-					mainMeterBilling.EndDate = pgtype.Date{
-						Time: endTime.Time, Valid: true}
-					mainMeterBillingMaxTime = endTime.Time
-				}
-				mainMeterBilling.ConsumedEnergyPrice += float64(
-					consumedEnergyPrice)
-				mainMeterBilling.TotalPrice += totalPrice
-			}
+	ctx := r.Context()
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	userID, err := qtx.UsePasswordResetToken(ctx, hashToken(tmplData.Token))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			tmplData.InvalidToken = true
+			s.renderTemplate(w, r, tmplName, tmplData)
+		} else {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
 		}
-		mmBillingPeriodIndex++
+		return
 	}
-	slices.Reverse(tmplData.BillingPeriods)
-	if addBillingPeriod {
-		tmplData.BillingPeriods = append(
-			tmplData.BillingPeriods, &MainMeterBillingPeriodFormData{})
-		s.renderTemplate(w, r, tmplName, tmplData)
+	err = qtx.UpdateUserPassword(
+		ctx, spinusdb.UpdateUserPasswordParams{ID: userID, Crypt: string(password)})
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
-	} else if removeBillingPeriod {
-		if billingPeriodsLen > 1 {
-			tmplData.BillingPeriods = tmplData.BillingPeriods[:billingPeriodsLastIndex]
-		}
-		s.renderTemplate(w, r, tmplName, tmplData)
+	}
+	// Other links sent before are not valid after the password is changed.
+	if err := qtx.UseUserPasswordResetTokens(ctx, userID); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if err := s.destroyUserSessions(ctx, userID); err != nil {
+		slog.Error("error destroying sessions", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
 	}
 
-	if formError {
-		s.renderTemplate(w, r, tmplName, tmplData)
+	s.renderTemplate(w, r, tmplName, PasswordResetTmplData{Done: true})
+}
+
+func (s *Server) HandleGetEmailVerification(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "emailVerification"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	user, err := s.queries.GetUserByID(ctx, userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
 		return
 	}
+	s.renderTemplate(
+		w, r, tmplName,
+		EmailVerificationTmplData{Email: user.Email, Verified: user.EmailVerified})
+}
 
-	subMeterReadings, err := s.queries.GetSubMeterReadings(
-		ctx, spinusdb.GetSubMeterReadingsParams{
-			FkMainMeter: mainMeterID,
-			DateMin:     pgtype.Date{Time: mainMeterBillingMinTime, Valid: true},
-			DateMax:     pgtype.Date{Time: mainMeterBillingMaxTime, Valid: true},
-		})
+func (s *Server) HandlePostEmailVerification(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "emailVerification"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	user, err := s.queries.GetUserByID(ctx, userID)
 	if err != nil {
 		slog.Error("error executing query", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
-	readingsLen := len(subMeterReadings)
-	if readingsLen == 0 {
-		tmplData.GeneralError = "There is no sub meter."
+	tmplData := EmailVerificationTmplData{Email: user.Email, Verified: user.EmailVerified}
+	if user.EmailVerified {
 		s.renderTemplate(w, r, tmplName, tmplData)
 		return
 	}
+	sent, err := s.sendEmailVerification(ctx, user)
+	if err != nil {
+		slog.Error("error sending email verification", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if sent {
+		tmplData.Sent = true
+	} else {
+		tmplData.GeneralError = fmt.Sprintf(
+			"Email was sent recently, wait %d minutes before sending another one.",
+			int(emailVerificationResendInterval.Minutes()))
+	}
+	s.renderTemplate(w, r, tmplName, tmplData)
+}
 
-	slog.Debug("billing", "calcBreakPoints", calcBreakPoints)
-	calcBreakPointsLen := len(calcBreakPoints)
-	breakPointReadings := make(map[time.Time]map[int32]*Reading)
-	laterReadings := make(map[int32]*Reading)
-	breakPointLastIndexes := make(map[int32]int)
-	var additionalBreakPoints BreakPoints              // From latest to earliest.
-	for _, subMeterReading := range subMeterReadings { // From latest to earliest.
-		readingDate := subMeterReading.ReadingDate
-		subMeterID := subMeterReading.SubMeterID
-		readingVal := subMeterReading.ReadingValue.Float64
-		readingTime := readingDate.Time
-		readingValid := readingDate.Valid
-		reading := &Reading{
-			Value: readingVal,
-			Time:  readingTime,
-			Valid: readingValid,
-		}
-		lastIndex := breakPointLastIndexes[subMeterID]
-		if lastIndex > calcBreakPointsLen {
-			continue
+func (s *Server) HandleGetEmailVerify(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "emailVerify"
+
+	tmplData := EmailVerifyTmplData{}
+	token, err := parseEmailVerificationToken(r.URL.Query().Get("token"))
+	if err != nil {
+		tmplData.InvalidToken = true
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	ctx := r.Context()
+	user, err := s.queries.GetUserByID(ctx, token.userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			tmplData.InvalidToken = true
+			s.renderTemplate(w, r, tmplName, tmplData)
+		} else {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
 		}
-		if !readingValid { // Earliest reading.
-			laterReading, ok := laterReadings[subMeterID]
-			if ok {
-				// Calculate valid readings.
-				// Set invalid reading for break points without reading.
-				lrTime := laterReading.Time
-				var lrInBp bool // Later reading in break point range.
-				var hasValidReading bool
-				for _, bp := range calcBreakPoints {
-					bpMin := bp[0]
-					bpActual := bp[1]
-					bpMax := bp[2]
-					_, ok := breakPointReadings[bpActual]
+		return
+	}
+	if !s.validEmailVerificationToken(token, user.Email, time.Now()) {
+		tmplData.InvalidToken = true
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	err = s.queries.VerifyUserEmail(
+		ctx, spinusdb.VerifyUserEmailParams{ID: user.ID, Email: user.Email})
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.renderTemplate(w, r, tmplName, tmplData)
+}
+
+func (s *Server) HandleGetMainMeterList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "mainMeterList"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	mainMeters, err := s.queries.ListMainMeters(r.Context(), userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	s.renderTemplate(w, r, tmplName, mainMeters)
+}
+
+func (s *Server) HandleGetMainMeterListExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	exp, err := newExporter(w, r, chi.URLParam(r, "format"), "main-meters")
+	if err != nil {
+		slog.Error("error creating exporter", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if err := exp.WriteRow(
+		textCell("ID"),
+		textCell("Meter Identification"),
+		textCell("Energy"),
+		textCell("Address"),
+	); err != nil {
+		slog.Error("error writing export", "err", err)
+		return
+	}
+	if err := s.streamMainMeters(ctx, userID, func(mainMeter spinusdb.MainMeter) error {
+		return exp.WriteRow(
+			intCell(mainMeter.ID),
+			textCell(mainMeter.MeterID),
+			textCell(string(mainMeter.Energy)),
+			textCell(mainMeter.Address),
+		)
+	}); err != nil {
+		slog.Error("error writing export", "err", err)
+		return
+	}
+	if err := exp.Close(); err != nil {
+		slog.Error("error writing export", "err", err)
+	}
+}
+
+func (s *Server) HandleGetMainMeterCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "mainMeterCreate"
+	s.renderTemplate(w, r, tmplName, nil)
+}
+
+func (s *Server) HandlePostMainMeterCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "mainMeterCreate"
+	formData := MainMeterFormData{}
+	var formError bool
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		formData.GeneralError = "Bad request"
+		if err := s.templates.Render(w, tmplName, formData); err != nil {
+			slog.Error("error rendering template", "template", tmplName, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	iMeterID := r.PostFormValue("meter-identification")
+	formData.MeterID = iMeterID
+	meterID, err := parseMainMeterID(iMeterID)
+	if err != nil {
+		formData.MeterIDError = err.Error()
+		formError = true
+	}
+
+	iEnergy := r.PostFormValue("energy")
+	formData.Energy = iEnergy
+	energy, err := parseEnergy(iEnergy)
+	if err != nil {
+		formData.EnergyError = err.Error()
+		formError = true
+	}
+
+	iAddress := r.PostFormValue("address")
+	formData.Address = iAddress
+	address, err := parseAddress(iAddress)
+	if err != nil {
+		formData.AddressError = err.Error()
+		formError = true
+	}
+
+	if formError {
+		s.renderTemplate(w, r, tmplName, formData)
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	mainMeter, err := s.queries.CreateMainMeter(
+		ctx,
+		spinusdb.CreateMainMeterParams{
+			MeterID: string(meterID),
+			Energy:  energy,
+			Address: string(address),
+			FkUser:  userID,
+		},
+	)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r, fmt.Sprintf("/main-meter/%d/overview", mainMeter.ID), http.StatusSeeOther)
+}
+
+func (s *Server) HandleGetMainMeterOverview(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "mainMeterOverview"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	consumptions, err := s.queries.ListSubMeterBillingPeriodConsumptions(ctx, mainMeter.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		MainMeterOverviewTmplData{
+			GetMainMeterRow:       mainMeter,
+			ConsumptionShareChart: newConsumptionShareChart(consumptions, mainMeter.Energy),
+			Upper:                 MainMeterTmplData{ID: mainMeter.ID},
+		},
+	)
+}
+
+func (s *Server) HandleGetSubMeterCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterCreate"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		SubMeterCreateTmplData{
+			SubMeterFormData: SubMeterFormData{},
+			Upper:            MainMeterTmplData{ID: mainMeter.ID},
+		},
+	)
+}
+
+func (s *Server) HandlePostSubMeterCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterCreate"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+
+	tmplData := SubMeterCreateTmplData{
+		SubMeterFormData: SubMeterFormData{},
+		Upper:            MainMeterTmplData{ID: mainMeter.ID},
+	}
+	var formError bool
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		if err := s.templates.Render(w, tmplName, tmplData); err != nil {
+			slog.Error("error rendering template", "template", tmplName, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	meterID := r.PostFormValue("meter-identification")
+	tmplData.MeterID = meterID
+	subMeterID, err := parseSubMeterID(meterID)
+	if err != nil {
+		tmplData.MeterIDError = err.Error()
+		formError = true
+	}
+
+	iFloorArea := r.PostFormValue("floor-area")
+	tmplData.FloorArea = iFloorArea
+	floorArea, err := parseFloorArea(iFloorArea)
+	if err != nil {
+		tmplData.FloorAreaError = err.Error()
+		formError = true
+	}
+
+	iOccupants := r.PostFormValue("occupants")
+	tmplData.Occupants = iOccupants
+	occupants, err := parseOccupants(iOccupants)
+	if err != nil {
+		tmplData.OccupantsError = err.Error()
+		formError = true
+	}
+
+	iCostShare := r.PostFormValue("cost-share")
+	tmplData.CostShare = iCostShare
+	costShare, err := parseCostShare(iCostShare)
+	if err != nil {
+		tmplData.CostShareError = err.Error()
+		formError = true
+	}
+
+	if formError {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	_, err = s.queries.CreateSubMeter(
+		ctx,
+		spinusdb.CreateSubMeterParams{
+			FkMainMeter: mainMeter.ID,
+			MeterID:     pgtype.Text{String: string(subMeterID), Valid: true},
+			FkUser:      userID,
+			FloorArea: pgtype.Float8{
+				Float64: floorArea.Float64, Valid: floorArea.Valid},
+			Occupants: pgtype.Int4{Int32: occupants.Int32, Valid: occupants.Valid},
+			CostShare: pgtype.Float8{
+				Float64: costShare.Float64, Valid: costShare.Valid},
+		},
+	)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r,
+		fmt.Sprintf("/main-meter/%d/sub-meter/list", mainMeter.ID), http.StatusSeeOther,
+	)
+}
+
+func (s *Server) HandleGetSubMeterList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterList"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+
+	mainMeterID := mainMeter.ID
+	subMeters, err := s.queries.ListSubMeters(r.Context(), mainMeterID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		SubMeterListTmplData{
+			SubMeters: subMeters,
+			Upper:     MainMeterTmplData{ID: mainMeterID},
+		},
+	)
+}
+
+func (s *Server) HandleGetSubMeterOverview(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterOverview"
+
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	subMeterReadings, err := s.queries.ListSubMeterReadings(ctx, subMeter.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	readingChart, consumptionChart := newSubMeterReadingCharts(
+		subMeterReadings, subMeter.Energy)
+
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		SubMeterOverviewTmplData{
+			GetSubMeterRow:   subMeter,
+			ReadingChart:     readingChart,
+			ConsumptionChart: consumptionChart,
+			Upper: SubMeterTmplData{
+				MainMeterID: subMeter.MainMeterID, Subid: subMeter.Subid},
+		},
+	)
+}
+
+func (s *Server) newSubMeterReadingListTmplData(
+	ctx context.Context, subMeter spinusdb.GetSubMeterRow,
+) (SubMeterReadingListTmplData, error) {
+	tmplData := SubMeterReadingListTmplData{
+		Upper: SubMeterTmplData{MainMeterID: subMeter.MainMeterID, Subid: subMeter.Subid},
+	}
+	subMeterReadings, err := s.queries.ListSubMeterReadingsWithUser(ctx, subMeter.ID)
+	if err != nil {
+		return tmplData, err
+	}
+	photos, err := s.queries.ListSubMeterReadingPhotos(ctx, subMeter.ID)
+	if err != nil {
+		return tmplData, err
+	}
+	photoIDs := make(map[int32][]int32)
+	for _, photo := range photos {
+		photoIDs[photo.FkReading] = append(photoIDs[photo.FkReading], photo.ID)
+	}
+	tmplData.SubMeterReadings = make([]SubMeterReadingTmplData, 0, len(subMeterReadings))
+	for _, subMeterReading := range subMeterReadings {
+		tmplData.SubMeterReadings = append(tmplData.SubMeterReadings, SubMeterReadingTmplData{
+			ListSubMeterReadingsWithUserRow: subMeterReading,
+			PhotoIDs:                        photoIDs[subMeterReading.ID],
+		})
+	}
+	return tmplData, nil
+}
+
+func (s *Server) HandleGetSubMeterReadingList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterReadingList"
+
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	tmplData, err := s.newSubMeterReadingListTmplData(ctx, subMeter)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.renderTemplate(w, r, tmplName, tmplData)
+}
+
+func (s *Server) HandleGetSubMeterReadingExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	exp, err := newExporter(
+		w, r,
+		chi.URLParam(r, "format"),
+		fmt.Sprintf("sub-meter-%d-readings", subMeter.Subid),
+	)
+	if err != nil {
+		slog.Error("error creating exporter", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if err := exp.WriteRow(
+		textCell("Reading"),
+		textCell("Date"),
+		textCell("Value"),
+		textCell("Source"),
+		textCell("Estimated"),
+	); err != nil {
+		slog.Error("error writing export", "err", err)
+		return
+	}
+	if err := s.streamSubMeterReadings(
+		ctx, subMeter.ID, func(reading spinusdb.SubMeterReading) error {
+			return exp.WriteRow(
+				intCell(reading.Subid),
+				dateCell(reading.ReadingDate),
+				consumptionCell(reading.ReadingValue),
+				textCell(string(reading.Source)),
+				boolCell(reading.Estimated),
+			)
+		},
+	); err != nil {
+		slog.Error("error writing export", "err", err)
+		return
+	}
+	if err := exp.Close(); err != nil {
+		slog.Error("error writing export", "err", err)
+	}
+}
+
+func (s *Server) HandleGetSubMeterReadingCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterReadingCreate"
+
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		SubMeterReadingCreateTmplData{
+			SubMeterReadingFormData: SubMeterReadingFormData{},
+			Upper: SubMeterTmplData{
+				MainMeterID: subMeter.MainMeterID, Subid: subMeter.Subid},
+		},
+	)
+}
+
+func (s *Server) HandlePostSubMeterReadingCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterReadingCreate"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+
+	mainMeterID := subMeter.MainMeterID
+	subMeterSubid := subMeter.Subid
+	tmplData := SubMeterReadingCreateTmplData{
+		SubMeterReadingFormData: SubMeterReadingFormData{},
+		Upper: SubMeterTmplData{
+			MainMeterID: mainMeterID, Subid: subMeterSubid},
+	}
+	var formError bool
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotoUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		if err := s.templates.Render(w, tmplName, tmplData); err != nil {
+			slog.Error("error rendering template", "template", tmplName, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	photos, err := parseReadingPhotos(r.MultipartForm.File["photos"])
+	if err != nil {
+		tmplData.PhotosError = err.Error()
+		formError = true
+	}
+
+	iReadingVal := r.PostFormValue("reading-value")
+	tmplData.ReadingValue = iReadingVal
+	readingVal, err := parseReadingValue(iReadingVal)
+	if err != nil {
+		tmplData.ReadingValueError = err.Error()
+		formError = true
+	}
+
+	subMeterID := subMeter.ID
+	tmplData.Estimated = r.PostFormValue("estimated") != ""
+
+	iReadingDate := r.PostFormValue("reading-date")
+	tmplData.ReadingDate = iReadingDate
+	readingTime, err := parseDate(iReadingDate)
+	readingDate := pgtype.Date{Time: readingTime.Time, Valid: true}
+	if err == nil {
+		_, err = s.queries.GetSubMeterReadingForDate(
+			ctx,
+			spinusdb.GetSubMeterReadingForDateParams{
+				FkSubMeter:  subMeterID,
+				ReadingDate: readingDate,
+			},
+		)
+		if err == nil {
+			tmplData.ReadingDateError = "Reading for the given date already exists."
+			formError = true
+		} else if err != pgx.ErrNoRows {
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+	} else {
+		tmplData.ReadingDateError = err.Error()
+		formError = true
+	}
+
+	if formError {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	subMeterReadings, err := s.queries.ListSubMeterReadings(ctx, subMeterID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	check := checkNewReading(subMeterReadings, float64(readingVal), readingTime.Time)
+	if check.Error != "" {
+		tmplData.ReadingValueError = check.Error
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	tmplData.Confirmed = r.PostFormValue("confirm") != ""
+	if len(check.Warnings) > 0 && !tmplData.Confirmed {
+		tmplData.Warnings = check.Warnings
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	subMeterReading, err := qtx.CreateSubMeterReading(
+		ctx,
+		spinusdb.CreateSubMeterReadingParams{
+			FkSubMeter:   subMeter.ID,
+			ReadingValue: float64(readingVal),
+			ReadingDate:  readingDate,
+			Source:       spinusdb.ReadingSourceManual,
+			FkUser:       pgtype.Int4{Int32: userID, Valid: true},
+			Estimated:    tmplData.Estimated,
+		},
+	)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	photoIDs, err := s.createReadingPhotos(ctx, qtx, subMeterReading.ID, photos)
+	if err != nil {
+		s.deleteReadingPhotoBlobs(ctx, photoIDs)
+		slog.Error("error creating photos", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		s.deleteReadingPhotoBlobs(ctx, photoIDs)
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r,
+		fmt.Sprintf(
+			"/main-meter/%d/sub-meter/%d/reading/list", mainMeterID, subMeterSubid),
+		http.StatusSeeOther,
+	)
+}
+
+func (s *Server) HandlePostSubMeterReadingDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	subMeterReading, ok := GetSubMeterReading(ctx)
+	if !ok {
+		slog.Error("error getting sub meter reading", "subMeterReading", subMeterReading)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter reading"))
+		return
+	}
+
+	photoIDs, err := s.queries.ListSubMeterReadingPhotoIDs(ctx, subMeterReading.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	// Photos are deleted along with the reading.
+	if err := s.queries.DeleteSubMeterReading(ctx, subMeterReading.ID); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.deleteReadingPhotoBlobs(ctx, photoIDs)
+
+	http.Redirect(
+		w, r,
+		fmt.Sprintf(
+			"/main-meter/%d/sub-meter/%d/reading/list", subMeter.MainMeterID, subMeter.Subid),
+		http.StatusSeeOther,
+	)
+}
+
+func (s *Server) HandlePostSubMeterReadingPhotoCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterReadingList"
+
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	subMeterReading, ok := GetSubMeterReading(ctx)
+	if !ok {
+		slog.Error("error getting sub meter reading", "subMeterReading", subMeterReading)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter reading"))
+		return
+	}
+
+	var generalError string
+	var photos []readingPhoto
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotoUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		slog.Error("error parsing form", "err", err)
+		generalError = "Bad request"
+	} else if photos, err = parseReadingPhotos(r.MultipartForm.File["photos"]); err != nil {
+		generalError = err.Error()
+	} else if len(photos) == 0 {
+		generalError = "Upload photo."
+	}
+	if generalError != "" {
+		tmplData, err := s.newSubMeterReadingListTmplData(ctx, subMeter)
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		tmplData.GeneralError = generalError
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	photoIDs, err := s.createReadingPhotos(ctx, qtx, subMeterReading.ID, photos)
+	if err != nil {
+		s.deleteReadingPhotoBlobs(ctx, photoIDs)
+		slog.Error("error creating photos", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		s.deleteReadingPhotoBlobs(ctx, photoIDs)
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r,
+		fmt.Sprintf(
+			"/main-meter/%d/sub-meter/%d/reading/list", subMeter.MainMeterID, subMeter.Subid),
+		http.StatusSeeOther,
+	)
+}
+
+func (s *Server) HandleGetSubMeterReadingPhoto(w http.ResponseWriter, r *http.Request) {
+	s.serveSubMeterReadingPhoto(w, r, false)
+}
+
+func (s *Server) HandleGetSubMeterReadingPhotoThumbnail(w http.ResponseWriter, r *http.Request) {
+	s.serveSubMeterReadingPhoto(w, r, true)
+}
+
+func (s *Server) serveSubMeterReadingPhoto(
+	w http.ResponseWriter, r *http.Request, thumbnail bool,
+) {
+	ctx := r.Context()
+	subMeterReading, ok := GetSubMeterReading(ctx)
+	if !ok {
+		slog.Error("error getting sub meter reading", "subMeterReading", subMeterReading)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter reading"))
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "photoID"), 10, 32)
+	if err != nil {
+		s.HandleNotFound(w, r)
+		return
+	}
+	photo, err := s.queries.GetSubMeterReadingPhoto(
+		ctx,
+		spinusdb.GetSubMeterReadingPhotoParams{
+			FkReading: subMeterReading.ID, ID: int32(id)},
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			s.HandleNotFound(w, r)
+			return
+		}
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	key, contentType := readingPhotoKey(photo.ID), photo.ContentType
+	if thumbnail {
+		key, contentType = readingPhotoThumbnailKey(photo.ID), "image/jpeg"
+	}
+	blobReader, err := s.blobStorage.Get(ctx, key)
+	if err != nil {
+		if err == blob.ErrNotFound {
+			s.HandleNotFound(w, r)
+			return
+		}
+		slog.Error("error getting blob", "key", key, "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer blobReader.Close()
+	w.Header().Set("Content-Type", contentType)
+	// Photos never change and URL has the photo ID, which is never reused
+	// unlike subids of readings.
+	w.Header().Set("Cache-Control", "private, max-age=31536000")
+	if _, err := io.Copy(w, blobReader); err != nil {
+		slog.Error("error writing photo", "err", err)
+	}
+}
+
+func (s *Server) HandleGetSubMeterReadingImport(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterReadingImport"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		SubMeterReadingImportTmplData{Upper: MainMeterTmplData{ID: mainMeter.ID}},
+	)
+}
+
+func (s *Server) HandlePostSubMeterReadingImport(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterReadingImport"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	mainMeterID := mainMeter.ID
+
+	tmplData := SubMeterReadingImportTmplData{Upper: MainMeterTmplData{ID: mainMeterID}}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	// Preview uploads the file, import sends back the previewed content.
+	var importReadings bool
+	if r.PostFormValue("import") != "" {
+		importReadings = true
+		tmplData.CSV = r.PostFormValue("csv")
+	} else {
+		file, _, err := r.FormFile("readings")
+		if err != nil {
+			tmplData.GeneralError = "Upload CSV file."
+			s.renderTemplate(w, r, tmplName, tmplData)
+			return
+		}
+		defer file.Close()
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(file); err != nil {
+			slog.Error("error reading file", "err", err)
+			tmplData.GeneralError = "Upload valid CSV file."
+			s.renderTemplate(w, r, tmplName, tmplData)
+			return
+		}
+		tmplData.CSV = buf.String()
+	}
+
+	rows, err := parseReadingImportCSV(tmplData.CSV)
+	if err != nil {
+		tmplData.GeneralError = "Upload valid CSV file."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	if err := s.validateReadingImport(ctx, mainMeterID, rows); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	tmplData.Rows = rows
+	for _, row := range rows {
+		if row.Valid() {
+			tmplData.ValidRowsCount++
+		}
+	}
+	if !importReadings || tmplData.ValidRowsCount == 0 {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	if err := s.createImportedReadings(ctx, userID, rows); err != nil {
+		slog.Error("error importing readings", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r, fmt.Sprintf("/main-meter/%d/sub-meter/list", mainMeterID), http.StatusSeeOther)
+}
+
+func (s *Server) HandleGetWmbusKeyList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "wmbusKeyList"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	keys, err := s.listWmbusKeyFormData(ctx, mainMeter.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		WmbusKeyListTmplData{Keys: keys, Upper: MainMeterTmplData{ID: mainMeter.ID}},
+	)
+}
+
+func (s *Server) HandlePostWmbusKeyList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "wmbusKeyList"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	mainMeterID := mainMeter.ID
+
+	keys, err := s.listWmbusKeyFormData(ctx, mainMeterID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	tmplData := WmbusKeyListTmplData{Keys: keys, Upper: MainMeterTmplData{ID: mainMeterID}}
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	// Empty key keeps the key set before, keys are never shown back.
+	parsedKeys := make(map[int32]WmbusKey)
+	var hasErrors bool
+	for _, key := range keys {
+		key.Key = r.PostFormValue(fmt.Sprintf("key-%d", key.Subid))
+		key.Remove = r.PostFormValue(fmt.Sprintf("remove-%d", key.Subid)) != ""
+		if key.Key == "" || key.Remove {
+			continue
+		}
+		parsedKey, err := parseWmbusKey(key.Key)
+		if err != nil {
+			key.KeyError = err.Error()
+			hasErrors = true
+			continue
+		}
+		parsedKeys[key.SubMeterID] = parsedKey
+	}
+	if hasErrors {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	for _, key := range keys {
+		if key.Remove {
+			err = qtx.DeleteSubMeterWmbusKey(ctx, key.SubMeterID)
+		} else if parsedKey, ok := parsedKeys[key.SubMeterID]; ok {
+			err = qtx.UpsertSubMeterWmbusKey(
+				ctx,
+				spinusdb.UpsertSubMeterWmbusKeyParams{
+					FkSubMeter: key.SubMeterID, AesKey: parsedKey},
+			)
+		}
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r,
+		fmt.Sprintf("/main-meter/%d/sub-meter/wmbus-key/list", mainMeterID),
+		http.StatusSeeOther,
+	)
+}
+
+func (s *Server) HandleGetWmbusImport(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "wmbusImport"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		WmbusImportTmplData{Upper: MainMeterTmplData{ID: mainMeter.ID}},
+	)
+}
+
+func (s *Server) HandlePostWmbusImport(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "wmbusImport"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	mainMeterID := mainMeter.ID
+
+	tmplData := WmbusImportTmplData{Upper: MainMeterTmplData{ID: mainMeterID}}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	// Preview uploads the file, import sends back the previewed content.
+	var importReadings bool
+	if r.PostFormValue("import") != "" {
+		importReadings = true
+		tmplData.Log = r.PostFormValue("log")
+	} else {
+		file, _, err := r.FormFile("telegrams")
+		if err != nil {
+			tmplData.GeneralError = "Upload log file."
+			s.renderTemplate(w, r, tmplName, tmplData)
+			return
+		}
+		defer file.Close()
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(file); err != nil {
+			slog.Error("error reading file", "err", err)
+			tmplData.GeneralError = "Upload valid log file."
+			s.renderTemplate(w, r, tmplName, tmplData)
+			return
+		}
+		tmplData.Log = buf.String()
+	}
+
+	subMeters, err := s.queries.ListSubMeters(ctx, mainMeterID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	wmbusKeys, err := s.queries.ListSubMeterWmbusKeys(ctx, mainMeterID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	keys := make(map[int32][]byte, len(wmbusKeys))
+	for _, key := range wmbusKeys {
+		keys[key.FkSubMeter] = key.AesKey
+	}
+	rows, stats := parseWmbusLog(tmplData.Log, subMeters, keys, mainMeter.Energy)
+	tmplData.Stats = stats
+	if stats.Telegrams == 0 {
+		tmplData.GeneralError = "Upload log file with telegrams."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	if err := s.validateReadingImport(ctx, mainMeterID, rows); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	tmplData.Rows = rows
+	for _, row := range rows {
+		if row.Valid() {
+			tmplData.ValidRowsCount++
+		}
+	}
+	if !importReadings || tmplData.ValidRowsCount == 0 {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	if err := s.createImportedReadings(ctx, userID, rows); err != nil {
+		slog.Error("error importing readings", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r, fmt.Sprintf("/main-meter/%d/sub-meter/list", mainMeterID), http.StatusSeeOther)
+}
+
+func (s *Server) HandleGetSubMeterIntervalList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterIntervalList"
+
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	days, err := s.queries.ListSubMeterIntervalDays(ctx, subMeter.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	tmplData := SubMeterIntervalListTmplData{
+		Days: make([]IntervalDayTmplData, 0, len(days)),
+		Upper: SubMeterTmplData{
+			MainMeterID: subMeter.MainMeterID, Subid: subMeter.Subid},
+	}
+	for _, day := range days {
+		tmplData.Days = append(tmplData.Days, IntervalDayTmplData{
+			ListSubMeterIntervalDaysRow: day,
+			Complete: day.CoveredSeconds >=
+				completeDaySeconds(day.Day.Time, s.location),
+		})
+	}
+	s.renderTemplate(w, r, tmplName, tmplData)
+}
+
+func (s *Server) HandleGetSubMeterIntervalImport(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterIntervalImport"
+
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		SubMeterIntervalImportTmplData{
+			IntervalImportFormData: NewIntervalImportFormData(),
+			Upper: SubMeterTmplData{
+				MainMeterID: subMeter.MainMeterID, Subid: subMeter.Subid},
+		},
+	)
+}
+
+func (s *Server) HandlePostSubMeterIntervalImport(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "subMeterIntervalImport"
+
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	mainMeterID := subMeter.MainMeterID
+	subMeterSubid := subMeter.Subid
+
+	tmplData := SubMeterIntervalImportTmplData{
+		Upper: SubMeterTmplData{MainMeterID: mainMeterID, Subid: subMeterSubid},
+	}
+	var formError bool
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	var options IntervalImportOptions
+	var err error
+	iTimeMark := r.PostFormValue("time-mark")
+	tmplData.TimeMark = iTimeMark
+	options.TimeMarksEnd, err = parseTimeMark(iTimeMark)
+	if err != nil {
+		tmplData.TimeMarkError = err.Error()
+		formError = true
+	}
+	iValueUnit := r.PostFormValue("value-unit")
+	tmplData.ValueUnit = iValueUnit
+	options.AveragePower, err = parseValueUnit(iValueUnit)
+	if err != nil {
+		tmplData.ValueUnitError = err.Error()
+		formError = true
+	}
+	iIntervalLength := r.PostFormValue("interval-length")
+	tmplData.IntervalLength = iIntervalLength
+	options.Length, err = parseIntervalLength(iIntervalLength)
+	if err != nil {
+		tmplData.IntervalLengthError = err.Error()
+		formError = true
+	}
+
+	// Preview uploads the file, import sends back the previewed content.
+	var importIntervals bool
+	if r.PostFormValue("import") != "" {
+		importIntervals = true
+		tmplData.CSV = r.PostFormValue("csv")
+	} else {
+		file, _, err := r.FormFile("intervals")
+		if err != nil {
+			tmplData.GeneralError = "Upload CSV file."
+			s.renderTemplate(w, r, tmplName, tmplData)
+			return
+		}
+		defer file.Close()
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(file); err != nil {
+			slog.Error("error reading file", "err", err)
+			tmplData.GeneralError = "Upload valid CSV file."
+			s.renderTemplate(w, r, tmplName, tmplData)
+			return
+		}
+		tmplData.CSV = buf.String()
+	}
+	if formError {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	imp, err := parseIntervalImportCSV(tmplData.CSV, options)
+	if err != nil {
+		tmplData.GeneralError = "Upload valid CSV file."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	tmplData.Import = imp
+	if !importIntervals || imp.ErrorCount > 0 || imp.IntervalCount() == 0 {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	intervals := make(
+		[]spinusdb.CreateSubMeterIntervalReadingsParams, 0, imp.IntervalCount())
+	for _, interval := range imp.intervals {
+		intervals = append(intervals, spinusdb.CreateSubMeterIntervalReadingsParams{
+			FkSubMeter:  subMeter.ID,
+			BeginTime:   pgtype.Timestamptz{Time: interval.begin, Valid: true},
+			EndTime:     pgtype.Timestamptz{Time: interval.end, Valid: true},
+			Consumption: interval.consumption,
+		})
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	// Imported intervals replace existing ones in the same time range.
+	err = qtx.DeleteSubMeterIntervalReadings(
+		ctx,
+		spinusdb.DeleteSubMeterIntervalReadingsParams{
+			FkSubMeter: subMeter.ID,
+			BeginTime:  pgtype.Timestamptz{Time: imp.BeginTime, Valid: true},
+			EndTime:    pgtype.Timestamptz{Time: imp.EndTime, Valid: true},
+		},
+	)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if _, err := qtx.CreateSubMeterIntervalReadings(ctx, intervals); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r,
+		fmt.Sprintf(
+			"/main-meter/%d/sub-meter/%d/interval/list", mainMeterID, subMeterSubid),
+		http.StatusSeeOther,
+	)
+}
+
+func (s *Server) HandleGetDeviceList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "deviceList"
+
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+	devices, err := s.queries.ListDevices(ctx, subMeter.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		DeviceListTmplData{
+			Devices: devices,
+			Upper: SubMeterTmplData{
+				MainMeterID: subMeter.MainMeterID, Subid: subMeter.Subid},
+		},
+	)
+}
+
+func (s *Server) HandlePostDeviceCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "deviceList"
+
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+
+	tmplData := DeviceListTmplData{
+		Upper: SubMeterTmplData{
+			MainMeterID: subMeter.MainMeterID, Subid: subMeter.Subid},
+	}
+	iName := r.PostFormValue("name")
+	tmplData.Name = iName
+	name, err := parseDeviceName(iName)
+	if err != nil {
+		tmplData.NameError = err.Error()
+	} else {
+		token, tokenHash, err := newToken(deviceTokenPrefix)
+		if err != nil {
+			slog.Error("error creating device token", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		_, err = s.queries.CreateDevice(
+			ctx,
+			spinusdb.CreateDeviceParams{
+				FkSubMeter: subMeter.ID,
+				Name:       string(name),
+				TokenHash:  tokenHash,
+			},
+		)
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		// Token is rendered instead of redirecting, it cannot be shown again.
+		tmplData.Name = ""
+		tmplData.Token = token
+	}
+
+	tmplData.Devices, err = s.queries.ListDevices(ctx, subMeter.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.renderTemplate(w, r, tmplName, tmplData)
+}
+
+func (s *Server) HandlePostDeviceDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "deviceID"), 10, 32)
+	if err != nil {
+		s.HandleNotFound(w, r)
+		return
+	}
+	deviceID := int32(id)
+	ctx := r.Context()
+	subMeter, ok := GetSubMeter(ctx)
+	if !ok {
+		slog.Error("error getting sub meter", "subMeter", subMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+		return
+	}
+
+	// Readings of the device are kept.
+	err = s.queries.DeleteDevice(
+		ctx, spinusdb.DeleteDeviceParams{FkSubMeter: subMeter.ID, Subid: deviceID})
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r,
+		fmt.Sprintf(
+			"/main-meter/%d/sub-meter/%d/device/list", subMeter.MainMeterID, subMeter.Subid),
+		http.StatusSeeOther,
+	)
+}
+
+func (s *Server) HandlePostIngestReadings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	device, ok := GetDevice(ctx)
+	if !ok {
+		slog.Error("error getting device", "device", device)
+		writeInternalError(w)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxIngestBodySize)
+	readings, err := parseIngestRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		writeInternalError(w)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	results, err := s.ingestReadings(ctx, qtx, device, readings)
+	if err != nil {
+		slog.Error("error ingesting readings", "err", err)
+		writeInternalError(w)
+		return
+	}
+	if err := qtx.UpdateDeviceLastUsedAt(ctx, device.ID); err != nil {
+		slog.Error("error executing query", "err", err)
+		writeInternalError(w)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		writeInternalError(w)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, IngestResponse{Results: results})
+}
+
+func (s *Server) HandlePostSyncReadings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		writeInternalError(w)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxIngestBodySize)
+	readings, err := parseSyncRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		writeInternalError(w)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	results, err := s.syncReadings(ctx, qtx, userID, readings)
+	if err != nil {
+		slog.Error("error syncing readings", "err", err)
+		writeInternalError(w)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		writeInternalError(w)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SyncResponse{Results: results})
+}
+
+func (s *Server) HandlePostIngestDSMR(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	device, ok := GetDevice(ctx)
+	if !ok {
+		slog.Error("error getting device", "device", device)
+		writeInternalError(w)
+		return
+	}
+	energy, err := s.queries.GetSubMeterEnergy(ctx, device.FkSubMeter)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		writeInternalError(w)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxIngestBodySize)
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(
+			w, http.StatusBadRequest,
+			APIErrorResponse{Error: "Send DSMR telegrams no larger than 1 MB."})
+		return
+	}
+	telegrams, _ := dsmr.Split(data, true)
+	switch {
+	case len(telegrams) == 0:
+		writeJSON(
+			w, http.StatusBadRequest, APIErrorResponse{Error: "Send DSMR telegram."})
+		return
+	case len(telegrams) > maxIngestBatchSize:
+		writeJSON(
+			w, http.StatusBadRequest,
+			APIErrorResponse{Error: fmt.Sprintf(
+				"Send no more than %d telegrams at once.", maxIngestBatchSize)})
+		return
+	}
+	results := make([]IngestResult, len(telegrams))
+	var readings []IngestReading
+	var readingIndexes []int
+	for i, telegram := range telegrams {
+		reading, err := newDSMRIngestReading(telegram, energy)
+		if err != nil {
+			results[i] = IngestResult{Status: IngestStatusRejected, Error: err.Error()}
+			continue
+		}
+		readings = append(readings, reading)
+		readingIndexes = append(readingIndexes, i)
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		writeInternalError(w)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	readingResults, err := s.ingestReadings(ctx, qtx, device, readings)
+	if err != nil {
+		slog.Error("error ingesting readings", "err", err)
+		writeInternalError(w)
+		return
+	}
+	for i, result := range readingResults {
+		results[readingIndexes[i]] = result
+	}
+	if err := qtx.UpdateDeviceLastUsedAt(ctx, device.ID); err != nil {
+		slog.Error("error executing query", "err", err)
+		writeInternalError(w)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		writeInternalError(w)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, IngestResponse{Results: results})
+}
+
+func (s *Server) HandleGetReadingAnomalyList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "readingAnomalyList"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	readings, err := s.queries.ListMainMeterReadings(ctx, mainMeter.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		ReadingAnomalyListTmplData{
+			Anomalies: findReadingAnomalies(readings),
+			Upper:     MainMeterTmplData{ID: mainMeter.ID},
+		},
+	)
+}
+
+func (s *Server) HandleGetMainMeterReadingExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	exp, err := newExporter(
+		w, r,
+		chi.URLParam(r, "format"),
+		fmt.Sprintf("main-meter-%d-readings", mainMeter.ID),
+	)
+	if err != nil {
+		slog.Error("error creating exporter", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if err := exp.WriteRow(
+		textCell("Sub Meter"),
+		textCell("Sub Meter Identification"),
+		textCell("Reading"),
+		textCell("Date"),
+		textCell("Value"),
+		textCell("Source"),
+		textCell("Estimated"),
+	); err != nil {
+		slog.Error("error writing export", "err", err)
+		return
+	}
+	if err := s.streamMainMeterReadings(
+		ctx, mainMeter.ID, func(reading spinusdb.ListMainMeterReadingsRow) error {
+			return exp.WriteRow(
+				intCell(reading.SubMeterSubid),
+				textCell(reading.MeterID.String),
+				intCell(reading.Subid),
+				dateCell(reading.ReadingDate),
+				consumptionCell(reading.ReadingValue),
+				textCell(string(reading.Source)),
+				boolCell(reading.Estimated),
+			)
+		},
+	); err != nil {
+		slog.Error("error writing export", "err", err)
+		return
+	}
+	if err := exp.Close(); err != nil {
+		slog.Error("error writing export", "err", err)
+	}
+}
+
+func newReadingRoundSubMeterForms(
+	lastReadings []spinusdb.ListSubMeterLastReadingsRow,
+) []*ReadingRoundSubMeterFormData {
+	subMeterForms := make([]*ReadingRoundSubMeterFormData, 0, len(lastReadings))
+	for _, lastReading := range lastReadings {
+		subMeterForm := &ReadingRoundSubMeterFormData{
+			Subid:   lastReading.Subid,
+			MeterID: lastReading.MeterID.String,
+		}
+		if lastReading.ReadingValue.Valid {
+			subMeterForm.LastReadingDate = formatDate(lastReading.ReadingDate)
+			subMeterForm.LastReadingValue = formatConsumption(lastReading.ReadingValue.Float64)
+		}
+		subMeterForms = append(subMeterForms, subMeterForm)
+	}
+	return subMeterForms
+}
+
+func (s *Server) HandleGetReadingRoundCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "readingRoundCreate"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	today := time.Now().Truncate(24 * time.Hour)
+	lastReadings, err := s.queries.ListSubMeterLastReadings(
+		ctx,
+		spinusdb.ListSubMeterLastReadingsParams{
+			BeforeDate:  pgtype.Date{Time: today.AddDate(0, 0, 1), Valid: true},
+			FkMainMeter: mainMeter.ID,
+		},
+	)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		ReadingRoundCreateTmplData{
+			ReadingRoundFormData: ReadingRoundFormData{
+				ReadingDate: today.Format("2006-01-02"),
+				SubMeters:   newReadingRoundSubMeterForms(lastReadings),
+			},
+			Upper: MainMeterTmplData{ID: mainMeter.ID},
+		},
+	)
+}
+
+func (s *Server) HandlePostReadingRoundCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "readingRoundCreate"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	mainMeterID := mainMeter.ID
+
+	tmplData := ReadingRoundCreateTmplData{Upper: MainMeterTmplData{ID: mainMeterID}}
+	var formError bool
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	iReadingDate := r.PostFormValue("reading-date")
+	tmplData.ReadingDate = iReadingDate
+	readingTime, err := parseDate(iReadingDate)
+	if err != nil {
+		tmplData.ReadingDateError = err.Error()
+		formError = true
+		readingTime = Time{time.Now().Truncate(24 * time.Hour)}
+	}
+	readingDate := pgtype.Date{Time: readingTime.Time, Valid: true}
+
+	// Readings are compared with the last ones before the date.
+	lastReadings, err := s.queries.ListSubMeterLastReadings(
+		ctx,
+		spinusdb.ListSubMeterLastReadingsParams{
+			BeforeDate: readingDate, FkMainMeter: mainMeterID},
+	)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	tmplData.SubMeters = newReadingRoundSubMeterForms(lastReadings)
+
+	var subMeterReadings []spinusdb.CreateSubMeterReadingParams
+	for i, lastReading := range lastReadings {
+		subMeterForm := tmplData.SubMeters[i]
+		iReadingVal := r.PostFormValue(fmt.Sprintf("reading-value-%d", lastReading.Subid))
+		subMeterForm.ReadingValue = iReadingVal
+		if iReadingVal == "" {
+			continue
+		}
+		readingVal, err := parseReadingValue(iReadingVal)
+		if err != nil {
+			subMeterForm.ReadingValueError = err.Error()
+			formError = true
+			continue
+		}
+		if tmplData.ReadingDateError == "" {
+			_, err = s.queries.GetSubMeterReadingForDate(
+				ctx,
+				spinusdb.GetSubMeterReadingForDateParams{
+					FkSubMeter:  lastReading.ID,
+					ReadingDate: readingDate,
+				},
+			)
+			if err == nil {
+				subMeterForm.ReadingValueError =
+					"Reading for the given date already exists."
+				formError = true
+				continue
+			} else if err != pgx.ErrNoRows {
+				slog.Error("error executing query", "err", err)
+				s.HandleInternalServerError(w, r, err)
+				return
+			}
+			readings, err := s.queries.ListSubMeterReadings(ctx, lastReading.ID)
+			if err != nil {
+				slog.Error("error executing query", "err", err)
+				s.HandleInternalServerError(w, r, err)
+				return
+			}
+			// Lower value can be a replaced meter, so it is only highlighted
+			// with the warnings, which are confirmed for the whole round.
+			check := checkNewReading(readings, float64(readingVal), readingTime.Time)
+			if check.Error != "" {
+				subMeterForm.Warnings = append(subMeterForm.Warnings, check.Error)
+			}
+			subMeterForm.Warnings = append(subMeterForm.Warnings, check.Warnings...)
+		}
+		subMeterReadings = append(
+			subMeterReadings,
+			spinusdb.CreateSubMeterReadingParams{
+				FkSubMeter:   lastReading.ID,
+				ReadingValue: float64(readingVal),
+				ReadingDate:  readingDate,
+				Source:       spinusdb.ReadingSourceManual,
+				FkUser:       pgtype.Int4{Int32: userID, Valid: true},
+			},
+		)
+	}
+	if !formError && len(subMeterReadings) == 0 {
+		tmplData.GeneralError = "Enter at least one reading value."
+		formError = true
+	}
+
+	if formError {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	tmplData.Confirmed = r.PostFormValue("confirm") != ""
+	if tmplData.HasWarnings() && !tmplData.Confirmed {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	for _, subMeterReading := range subMeterReadings {
+		if _, err := qtx.CreateSubMeterReading(ctx, subMeterReading); err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r, fmt.Sprintf("/main-meter/%d/sub-meter/list", mainMeterID), http.StatusSeeOther)
+}
+
+func (s *Server) HandleGetMainMeterBillingList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "mainMeterBillingList"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	mainMeterID := mainMeter.ID
+	mainMeterBillings, err := s.queries.ListMainMeterBillings(ctx, mainMeterID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	s.renderTemplate(
+		w, r, tmplName,
+		MainMeterBillingListTmplData{
+			MainMeterBillings: mainMeterBillings,
+			Upper:             MainMeterTmplData{ID: mainMeterID},
+		},
+	)
+}
+
+func (s *Server) HandleGetMainMeterBillingOverview(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "mainMeterBillingOverview"
+
+	ctx := r.Context()
+	mainMeterBilling, ok := GetMainMeterBilling(ctx)
+	if !ok {
+		slog.Error("error getting main meter billing", "mainMeterBilling", mainMeterBilling)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter billing"))
+		return
+	}
+	mainMeterBillingID := mainMeterBilling.ID
+	billingPeriods, err := s.queries.ListMainMeterBillingPeriods(ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	costItems, err := s.queries.ListMainMeterBillingCostItems(ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	subMeterBillings, err := s.queries.ListSubMeterBillings(ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	subMeterBillingCostItems, err := s.queries.ListSubMeterBillingCostItems(
+		ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	bankAccount, err := s.getBankAccount(ctx, mainMeterBilling.MainUserID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	smCostItems := make(map[int32][]spinusdb.ListSubMeterBillingCostItemsRow)
+	for _, smCostItem := range subMeterBillingCostItems {
+		smBillingID := smCostItem.FkSubBilling
+		smCostItems[smBillingID] = append(smCostItems[smBillingID], smCostItem)
+	}
+	smBillings := make([]SubMeterBillingTmplData, 0, len(subMeterBillings))
+	for _, subMeterBilling := range subMeterBillings {
+		smBilling := SubMeterBillingTmplData{
+			ListSubMeterBillingsRow: subMeterBilling,
+			CostItems:               smCostItems[subMeterBilling.ID],
+		}
+		if payment, ok := newSubMeterBillingPayment(
+			bankAccount, mainMeterBilling, subMeterBilling); ok {
+			smBilling.Payment, err = newPaymentTmplData(payment)
+			if err != nil {
+				slog.Error("error creating payment", "err", err)
+				s.HandleInternalServerError(w, r, err)
+				return
+			}
+		}
+		smBillings = append(smBillings, smBilling)
+	}
+
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		MainMeterBillingOverviewTmplData{
+			GetMainMeterBillingRow: mainMeterBilling,
+			BillingPeriods:         billingPeriods,
+			CostItems:              costItems,
+			SubMeterBillings:       smBillings,
+			Upper: MainMeterBillingTmplData{
+				MainMeterID: mainMeterBilling.FkMainMeter,
+				Subid:       mainMeterBilling.Subid,
+			},
+		},
+	)
+}
+
+func (s *Server) HandleGetMainMeterBillingExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	mainMeterBilling, ok := GetMainMeterBilling(ctx)
+	if !ok {
+		slog.Error("error getting main meter billing", "mainMeterBilling", mainMeterBilling)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter billing"))
+		return
+	}
+	mainMeterBillingID := mainMeterBilling.ID
+	billingPeriods, err := s.queries.ListMainMeterBillingPeriods(ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	subMeterBillings, err := s.queries.ListSubMeterBillings(ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	subMeterBillingPeriods := make(
+		[][]spinusdb.ListSubMeterBillingPeriodsRow, len(subMeterBillings))
+	for i, subMeterBilling := range subMeterBillings {
+		subMeterBillingPeriods[i], err = s.queries.ListSubMeterBillingPeriods(
+			ctx, subMeterBilling.ID)
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+	}
+
+	exp, err := newExporter(
+		w, r,
+		chi.URLParam(r, "format"),
+		fmt.Sprintf("billing-%d", mainMeterBilling.Subid),
+	)
+	if err != nil {
+		slog.Error("error creating exporter", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	rows := [][]exportCell{
+		{textCell("Billing"), intCell(mainMeterBilling.Subid)},
+		{textCell("Begin Date"), dateCell(mainMeterBilling.BeginDate)},
+		{textCell("End Date"), dateCell(mainMeterBilling.EndDate)},
+		{textCell("Energy Consumption"), consumptionCell(mainMeterBilling.EnergyConsumption)},
+		{textCell("Consumed Energy Price"), priceCell(mainMeterBilling.ConsumedEnergyPrice)},
+		{textCell("Service Price"), optionalPriceCell(mainMeterBilling.ServicePrice)},
+		{textCell("Cost Item Price"), priceCell(mainMeterBilling.CostItemPrice)},
+		{textCell("Advance Price"), priceCell(mainMeterBilling.AdvancePrice)},
+		{textCell("Total Price"), priceCell(mainMeterBilling.TotalPrice)},
+		{},
+		{
+			textCell("Period"),
+			textCell("Begin Date"),
+			textCell("End Date"),
+			textCell("Begin Reading Value"),
+			textCell("End Reading Value"),
+			textCell("Energy Consumption"),
+			textCell("Consumed Energy Price"),
+			textCell("Service Price"),
+			textCell("Advance Price"),
+			textCell("Total Price"),
+		},
+	}
+	for _, period := range billingPeriods {
+		rows = append(rows, []exportCell{
+			intCell(period.Subid),
+			dateCell(period.BeginDate),
+			dateCell(period.EndDate),
+			consumptionCell(period.BeginReadingValue),
+			consumptionCell(period.EndReadingValue),
+			consumptionCell(period.EnergyConsumption),
+			priceCell(period.ConsumedEnergyPrice),
+			optionalPriceCell(period.ServicePrice),
+			priceCell(period.AdvancePrice),
+			priceCell(period.TotalPrice),
+		})
+	}
+	rows = append(
+		rows,
+		[]exportCell{},
+		[]exportCell{
+			textCell("Sub Meter"),
+			textCell("Sub Meter Identification"),
+			textCell("Number"),
+			textCell("Tenant"),
+			textCell("Begin Date"),
+			textCell("End Date"),
+			textCell("Energy Consumption"),
+			textCell("Consumed Energy Price"),
+			textCell("Service Price"),
+			textCell("Cost Item Price"),
+			textCell("Advance Price"),
+			textCell("Total Price"),
+		},
+	)
+	for _, row := range rows {
+		if err := exp.WriteRow(row...); err != nil {
+			slog.Error("error writing export", "err", err)
+			return
+		}
+	}
+	for i, subMeterBilling := range subMeterBillings {
+		smCells := []exportCell{
+			intCell(subMeterBilling.SubMeterSubid),
+			textCell(subMeterBilling.MeterID.String),
+			textCell(subMeterBilling.Number.String),
+			textCell(subMeterBilling.Email),
+		}
+		if err := exp.WriteRow(append(
+			smCells,
+			dateCell(mainMeterBilling.BeginDate),
+			dateCell(mainMeterBilling.EndDate),
+			consumptionCell(subMeterBilling.EnergyConsumption),
+			priceCell(subMeterBilling.ConsumedEnergyPrice),
+			optionalPriceCell(subMeterBilling.ServicePrice),
+			priceCell(subMeterBilling.CostItemPrice),
+			priceCell(subMeterBilling.AdvancePrice),
+			priceCell(subMeterBilling.TotalPrice),
+		)...); err != nil {
+			slog.Error("error writing export", "err", err)
+			return
+		}
+		// Period rows repeat the sub meter, so they can be filtered in
+		// spreadsheets. Cost items are not split into periods.
+		for _, period := range subMeterBillingPeriods[i] {
+			if err := exp.WriteRow(append(
+				smCells,
+				dateCell(period.BeginDate),
+				dateCell(period.EndDate),
+				consumptionCell(period.EnergyConsumption),
+				priceCell(period.ConsumedEnergyPrice),
+				optionalPriceCell(period.ServicePrice),
+				exportCell{},
+				priceCell(period.AdvancePrice),
+				priceCell(period.TotalPrice),
+			)...); err != nil {
+				slog.Error("error writing export", "err", err)
+				return
+			}
+		}
+	}
+	if err := exp.Close(); err != nil {
+		slog.Error("error writing export", "err", err)
+	}
+}
+
+func (s *Server) HandleGetMainMeterBillingCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "mainMeterBillingCreate"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		MainMeterBillingCreateTmplData{
+			MainMeterBillingFormData: NewMainMeterBillingFormData(),
+			Upper:                    MainMeterTmplData{ID: mainMeter.ID},
+		},
+	)
+}
+
+func (s *Server) HandlePostMainMeterBillingCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "mainMeterBillingCreate"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+
+	mainMeterID := mainMeter.ID
+
+	var mainMeterbillingPeriodForms []*MainMeterBillingPeriodFormData
+	tmplData := MainMeterBillingCreateTmplData{
+		MainMeterBillingFormData: MainMeterBillingFormData{
+			BillingPeriods: mainMeterbillingPeriodForms},
+		Upper: MainMeterTmplData{ID: mainMeterID},
+	}
+	var formError bool
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		if err := s.templates.Render(w, tmplName, tmplData); err != nil {
+			slog.Error("error rendering template", "template", tmplName, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	var addBillingPeriod bool
+	if r.PostFormValue("add-billing-period") != "" {
+		addBillingPeriod = true
+	}
+	var removeBillingPeriod bool
+	if r.PostFormValue("remove-billing-period") != "" {
+		removeBillingPeriod = true
+	}
+	var addCostItem bool
+	if r.PostFormValue("add-cost-item") != "" {
+		addCostItem = true
+	}
+	var removeCostItem bool
+	if r.PostFormValue("remove-cost-item") != "" {
+		removeCostItem = true
+	}
+	var parse bool
+	if !addBillingPeriod && !removeBillingPeriod && !addCostItem && !removeCostItem {
+		parse = true
+	}
+
+	mainMeterBilling := spinusdb.CreateMainMeterBillingParams{FkMainMeter: mainMeterID}
+
+	iMaxDayDiff := r.PostFormValue("max-day-diff")
+	tmplData.MaxDayDiff = iMaxDayDiff
+	maxDayDiff, err := parseMaxDayDiff(iMaxDayDiff)
+	dayDiff := int(maxDayDiff)
+	if err != nil {
+		tmplData.MaxDayDiffError = err.Error()
+		formError = true
+	}
+	mainMeterBilling.MaxDayDiff = int32(maxDayDiff)
+
+	// From latest to earliest.
+	var mainMeterBillingPeriods []*spinusdb.CreateMainMeterBillingPeriodParams
+	var calcBreakPoints BreakPoints // From latest to earliest.
+
+	iBeginDates := r.PostForm["begin-date"]
+	iEndDates := r.PostForm["end-date"]
+	iBeginReadingVals := r.PostForm["begin-reading-value"]
+	iEndReadingVals := r.PostForm["end-reading-value"]
+	iConsumedEnergyPrices := r.PostForm["consumed-energy-price"]
+	iServicePrices := r.PostForm["service-price"]
+
+	billingPeriodsLen := len(iBeginDates)
+	if billingPeriodsLen == 0 {
+		tmplData.GeneralError = "No billing period provided."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	billingPeriodsLastIndex := billingPeriodsLen - 1
+	var mainMeterBillingMinTime time.Time // Shifted one day back.
+	var mainMeterBillingMaxTime time.Time
+
+	mmBillingPeriodIndex := 0
+	for i := billingPeriodsLastIndex; i >= 0; i-- {
+		mainMeterBillingPeriodForm := &MainMeterBillingPeriodFormData{}
+		tmplData.BillingPeriods = append(
+			tmplData.BillingPeriods, mainMeterBillingPeriodForm)
+		iBeginDate := iBeginDates[i]
+		mainMeterBillingPeriodForm.BeginDate = iBeginDate
+		iEndDate := iEndDates[i]
+		mainMeterBillingPeriodForm.EndDate = iEndDate
+		iBeginReadingVal := iBeginReadingVals[i]
+		mainMeterBillingPeriodForm.BeginReadingValue = iBeginReadingVal
+		iEndReadingVal := iEndReadingVals[i]
+		mainMeterBillingPeriodForm.EndReadingValue = iEndReadingVal
+		iConsumedEnergyPrice := iConsumedEnergyPrices[i]
+		mainMeterBillingPeriodForm.ConsumedEnergyPrice = iConsumedEnergyPrice
+		iServicePrice := iServicePrices[i]
+		mainMeterBillingPeriodForm.ServicePrice = iServicePrice
+		if parse {
+			mainMeterBillingPeriod := &spinusdb.CreateMainMeterBillingPeriodParams{}
+			mainMeterBillingPeriods = append(
+				mainMeterBillingPeriods, mainMeterBillingPeriod)
+			beginTime, err := parseDate(iBeginDate)
+			if err != nil {
+				mainMeterBillingPeriodForm.BeginDateError = err.Error()
+				formError = true
+			}
+			endTime, err := parseDate(iEndDate)
+			if err != nil {
+				mainMeterBillingPeriodForm.EndDateError = err.Error()
+				formError = true
+			}
+			beginReadingVal, err := parseReadingValue(iBeginReadingVal)
+			if err != nil {
+				mainMeterBillingPeriodForm.BeginReadingValueError = err.Error()
+				formError = true
+			}
+			endReadingVal, err := parseReadingValue(iEndReadingVal)
+			if err != nil {
+				mainMeterBillingPeriodForm.EndReadingValueError = err.Error()
+				formError = true
+			}
+			consumedEnergyPrice, err := parseConsumedEnergyPrice(iConsumedEnergyPrice)
+			if err != nil {
+				mainMeterBillingPeriodForm.ConsumedEnergyPriceError = err.Error()
+				formError = true
+			}
+			servicePrice, err := parseServicePrice(iServicePrice)
+			if err != nil {
+				mainMeterBillingPeriodForm.ServicePriceError = err.Error()
+				formError = true
+			}
+			if !formError {
+				if i != billingPeriodsLastIndex {
+					laterIndex := mmBillingPeriodIndex - 1
+					previousBeginDate :=
+						mainMeterBillingPeriods[laterIndex].BeginDate
+					if endTime.AddDate(0, 0, 1) !=
+						previousBeginDate.Time {
+
+						laterBillingPeriod :=
+							tmplData.BillingPeriods[laterIndex]
+						laterBillingPeriod.BeginDateError =
+							"Begin date must follow previous " +
+								"billing period's end date."
+						formError = true
+						mmBillingPeriodIndex++
+						continue
+					}
+				}
+				mainMeterBillingPeriod.BeginDate = pgtype.Date{
+					Time: beginTime.Time, Valid: true}
+				if endTime.Time.Before(beginTime.Time) {
+					mainMeterBillingPeriodForm.EndDateError =
+						"End date must be greater or equal to begin date."
+					formError = true
+					mmBillingPeriodIndex++
+					continue
+				}
+				calcBreakPoints = append(
+					calcBreakPoints,
+					[3]time.Time{
+						endTime.AddDate(0, 0, -dayDiff),
+						endTime.Time,
+						endTime.AddDate(0, 0, dayDiff),
+					},
+				)
+				// Shift begin time one day back,
+				// so that eg. January end date minus begin date is 31 days.
+				shiftedBeginTime := beginTime.AddDate(0, 0, -1)
+				minTime := shiftedBeginTime.AddDate(0, 0, -dayDiff)
+				calcBreakPoints = append(
+					calcBreakPoints,
+					[3]time.Time{
+						minTime,
+						shiftedBeginTime,
+						beginTime.AddDate(0, 0, dayDiff),
+					},
+				)
+				mainMeterBillingPeriod.EndDate = pgtype.Date{
+					Time: endTime.Time, Valid: true}
+				mainMeterBillingPeriod.BeginReadingValue =
+					float64(beginReadingVal)
+				mainMeterBillingPeriod.EndReadingValue = float64(endReadingVal)
+				energyConsumption :=
+					float64(endReadingVal) - float64(beginReadingVal)
+				mainMeterBillingPeriod.EnergyConsumption = energyConsumption
+				mainMeterBilling.EnergyConsumption += energyConsumption
+				mainMeterBillingPeriod.ConsumedEnergyPrice =
+					float64(consumedEnergyPrice)
+				mainMeterBillingPeriod.ServicePrice = pgtype.Float8{
+					Float64: servicePrice.Float64, Valid: servicePrice.Valid}
+				totalPrice := float64(consumedEnergyPrice)
+				if servicePrice.Valid {
+					totalPrice += servicePrice.Float64
+					mainMeterBilling.ServicePrice.Float64 +=
+						servicePrice.Float64
+					mainMeterBilling.ServicePrice.Valid = true
+				}
+				mainMeterBillingPeriod.TotalPrice = totalPrice
+				if i == 0 {
+					mainMeterBilling.BeginDate = pgtype.Date{
+						Time: beginTime.Time, Valid: true}
+					mainMeterBillingMinTime = minTime
+				}
+				if i == billingPeriodsLastIndex {
+					mainMeterBilling.EndDate = pgtype.Date{
+						Time: endTime.Time, Valid: true}
+					mainMeterBillingMaxTime = endTime.Time
+				}
+				mainMeterBilling.ConsumedEnergyPrice += float64(
+					consumedEnergyPrice)
+				mainMeterBilling.TotalPrice += totalPrice
+			}
+		}
+		mmBillingPeriodIndex++
+	}
+	slices.Reverse(tmplData.BillingPeriods)
+
+	var mainMeterBillingCostItems []*spinusdb.CreateMainMeterBillingCostItemParams
+
+	iCostItemNames := r.PostForm["cost-item-name"]
+	iCostItemAllocationKeys := r.PostForm["cost-item-allocation-key"]
+	iCostItemPrices := r.PostForm["cost-item-price"]
+
+	costItemsLen := len(iCostItemNames)
+	if len(iCostItemAllocationKeys) != costItemsLen || len(iCostItemPrices) != costItemsLen {
+		tmplData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	for i := 0; i < costItemsLen; i++ {
+		costItemForm := &MainMeterBillingCostItemFormData{}
+		tmplData.CostItems = append(tmplData.CostItems, costItemForm)
+		iCostItemName := iCostItemNames[i]
+		costItemForm.Name = iCostItemName
+		iCostItemAllocationKey := iCostItemAllocationKeys[i]
+		costItemForm.AllocationKey = iCostItemAllocationKey
+		iCostItemPrice := iCostItemPrices[i]
+		costItemForm.Price = iCostItemPrice
+		if parse {
+			costItemName, err := parseCostItemName(iCostItemName)
+			if err != nil {
+				costItemForm.NameError = err.Error()
+				formError = true
+			}
+			allocationKey, err := parseAllocationKey(iCostItemAllocationKey)
+			if err != nil {
+				costItemForm.AllocationKeyError = err.Error()
+				formError = true
+			}
+			costItemPrice, err := parseCostItemPrice(iCostItemPrice)
+			if err != nil {
+				costItemForm.PriceError = err.Error()
+				formError = true
+			}
+			mainMeterBillingCostItems = append(
+				mainMeterBillingCostItems,
+				&spinusdb.CreateMainMeterBillingCostItemParams{
+					Name:          string(costItemName),
+					AllocationKey: allocationKey,
+					Price:         float64(costItemPrice),
+				},
+			)
+		}
+	}
+
+	if addBillingPeriod {
+		tmplData.BillingPeriods = append(
+			tmplData.BillingPeriods, &MainMeterBillingPeriodFormData{})
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	} else if removeBillingPeriod {
+		if billingPeriodsLen > 1 {
+			tmplData.BillingPeriods = tmplData.BillingPeriods[:billingPeriodsLastIndex]
+		}
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	} else if addCostItem {
+		tmplData.CostItems = append(
+			tmplData.CostItems, &MainMeterBillingCostItemFormData{})
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	} else if removeCostItem {
+		if costItemsLen > 0 {
+			tmplData.CostItems = tmplData.CostItems[:costItemsLen-1]
+		}
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	if formError {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	subMeterReadings, err := s.queries.GetSubMeterReadings(
+		ctx, spinusdb.GetSubMeterReadingsParams{
+			FkMainMeter: mainMeterID,
+			DateMin:     pgtype.Date{Time: mainMeterBillingMinTime, Valid: true},
+			DateMax:     pgtype.Date{Time: mainMeterBillingMaxTime, Valid: true},
+		})
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	readingsLen := len(subMeterReadings)
+	if readingsLen == 0 {
+		tmplData.GeneralError = "There is no sub meter."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	var costItemWeights []AllocationWeights
+	if costItemsLen > 0 {
+		subMeterAllocations, err := s.queries.ListSubMeterAllocations(ctx, mainMeterID)
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		for i, costItem := range mainMeterBillingCostItems {
+			allocationKey := costItem.AllocationKey
+			weights := newAllocationWeights(allocationKey, subMeterAllocations)
+			if weights.Sum() == 0 {
+				tmplData.CostItems[i].AllocationKeyError =
+					allocationKeyErrors[allocationKey]
+				formError = true
+			}
+			costItemWeights = append(costItemWeights, weights)
+		}
+		if formError {
+			s.renderTemplate(w, r, tmplName, tmplData)
+			return
+		}
+	}
+
+	slog.Debug("billing", "calcBreakPoints", calcBreakPoints)
+	calcBreakPointsLen := len(calcBreakPoints)
+	breakPointReadings := make(map[time.Time]map[int32]*Reading)
+	laterReadings := make(map[int32]*Reading)
+	breakPointLastIndexes := make(map[int32]int)
+	var additionalBreakPoints BreakPoints              // From latest to earliest.
+	for _, subMeterReading := range subMeterReadings { // From latest to earliest.
+		readingDate := subMeterReading.ReadingDate
+		subMeterID := subMeterReading.SubMeterID
+		readingVal := subMeterReading.ReadingValue.Float64
+		readingTime := readingDate.Time
+		readingValid := readingDate.Valid
+		reading := &Reading{
+			Value:     readingVal,
+			Time:      readingTime,
+			Valid:     readingValid,
+			Estimated: subMeterReading.Estimated.Bool,
+		}
+		lastIndex := breakPointLastIndexes[subMeterID]
+		if lastIndex > calcBreakPointsLen {
+			continue
+		}
+		if !readingValid { // Earliest reading.
+			laterReading, ok := laterReadings[subMeterID]
+			if ok {
+				// Calculate valid readings.
+				// Set invalid reading for break points without reading.
+				lrTime := laterReading.Time
+				var lrInBp bool // Later reading in break point range.
+				var hasValidReading bool
+				for _, bp := range calcBreakPoints {
+					bpMin := bp[0]
+					bpActual := bp[1]
+					bpMax := bp[2]
+					_, ok := breakPointReadings[bpActual]
+					if !ok {
+						breakPointReadings[bpActual] =
+							make(map[int32]*Reading)
+					}
+					r, ok := breakPointReadings[bpActual][subMeterID]
+					if ok {
+						if r.Valid {
+							hasValidReading = true
+						}
+					} else {
+						breakPointReadings[bpActual][subMeterID] =
+							&Reading{}
+					}
+					if !lrInBp && lrTime.Compare(bpMax) <= 0 &&
+						lrTime.Compare(bpMin) >= 0 {
+						// Later reading is in break point range.
+						lrInBp = true
+					}
+				}
+				// Sub meter has at least one valid break point reading.
+				if hasValidReading {
+					bp := [3]time.Time{
+						lrTime.AddDate(0, 0, -dayDiff),
+						lrTime,
+						lrTime.AddDate(0, 0, dayDiff),
+					}
+					if !lrInBp &&
+						!slices.Contains(additionalBreakPoints, bp) &&
+						lrTime.Compare(mainMeterBillingMaxTime) <= 0 &&
+						lrTime.Compare(mainMeterBillingMinTime) >= 0 {
+						// Add later reading to additional break points.
+						additionalBreakPoints = append(
+							additionalBreakPoints, bp)
+					}
+				}
+			} else {
+				// No later reading, set invalid reading for all break points.
+				for _, bp := range calcBreakPoints {
+					bpActual := bp[1]
+					_, ok := breakPointReadings[bpActual]
+					if !ok {
+						breakPointReadings[bpActual] =
+							make(map[int32]*Reading)
+					}
+					breakPointReadings[bpActual][subMeterID] = &Reading{}
+				}
+			}
+			continue // There is no other reading for this sub meter.
+		}
+		var additionalBp [3]time.Time
+		var lrValGE bool // Later value greater or equal to current reading value.
+		var lrVal float64
+		lr, ok := laterReadings[subMeterID]
+		if ok {
+			lrVal = lr.Value
+			if lrVal >= readingVal {
+				// Later reading value is greater or equal to current reading
+				// value.
+				lrValGE = true
+			} else {
+				// Later reading value is lower than current reading value.
+				lrTime := lr.Time
+				bp := [3]time.Time{
+					lrTime.AddDate(0, 0, -dayDiff),
+					lrTime,
+					lrTime.AddDate(0, 0, dayDiff),
+				}
+				if !slices.Contains(calcBreakPoints, bp) &&
+					!slices.Contains(additionalBreakPoints, bp) &&
+					lrTime.Compare(mainMeterBillingMaxTime) <= 0 &&
+					lrTime.Compare(mainMeterBillingMinTime) >= 0 {
+					// Add later reading to additional break points.
+					additionalBreakPoints = append(additionalBreakPoints, bp)
+				}
+				bp = [3]time.Time{
+					readingTime.AddDate(0, 0, -dayDiff),
+					readingTime,
+					readingTime.AddDate(0, 0, dayDiff),
+				}
+				if !slices.Contains(calcBreakPoints, bp) &&
+					!slices.Contains(additionalBreakPoints, bp) &&
+					readingTime.Compare(mainMeterBillingMaxTime) <= 0 &&
+					readingTime.Compare(mainMeterBillingMinTime) >= 0 {
+					// Add reading to additional break points.
+					additionalBreakPoints = append(additionalBreakPoints, bp)
+				}
+			}
+		} else {
+			// No later reading.
+			bp := [3]time.Time{
+				readingTime.AddDate(0, 0, -dayDiff),
+				readingTime,
+				readingTime.AddDate(0, 0, dayDiff),
+			}
+			if !slices.Contains(calcBreakPoints, bp) &&
+				!slices.Contains(additionalBreakPoints, bp) &&
+				readingTime.Compare(mainMeterBillingMaxTime) <= 0 &&
+				readingTime.Compare(mainMeterBillingMinTime) >= 0 {
+				// Prepare current reading as possible additional break point.
+				additionalBp = bp
+			}
+		}
+		var readingInBp bool // Reading in break point range.
+		for i := lastIndex; i < calcBreakPointsLen; i++ {
+			bp := calcBreakPoints[i]
+			bpMin := bp[0]
+			bpActual := bp[1]
+			bpMax := bp[2]
+			_, ok := breakPointReadings[bpActual]
+			if !ok {
+				breakPointReadings[bpActual] = make(map[int32]*Reading)
+			}
+			prevBpReading, prevBpReadingOk := breakPointReadings[bpActual][subMeterID]
+			if readingTime.After(bpMax) {
+				// After break point max.
+				if !readingInBp && !additionalBp[1].IsZero() {
+					// Add additional break point when set.
+					additionalBreakPoints = append(
+						additionalBreakPoints, additionalBp)
+				}
+				break
+			} else if readingTime.Compare(bpMin) >= 0 {
+				// Between break point min and max.
+				if !prevBpReadingOk || reading.betterFor(bpActual, prevBpReading) {
+					// Actual reading over estimate, lower time difference
+					// or no previous reading.
+					breakPointReadings[bpActual][subMeterID] = reading
+				}
+				if bpActual.Compare(readingTime) >= 0 && !reading.Estimated {
+					// No better reading possible.
+					breakPointLastIndexes[subMeterID] += 1
+				}
+				readingInBp = true
+			} else {
+				// Before break point min.
+				if prevBpReadingOk && prevBpReading.Valid && prevBpReading.Estimated {
+					// Keep estimate in break point range, there is no
+					// earlier actual reading in the range.
+					breakPointLastIndexes[subMeterID] += 1
+				} else if lrValGE {
+					readingDayDiff := lr.Time.Sub(readingTime).Hours() / 24
+					newValPerDay := (lrVal - readingVal) / readingDayDiff
+					newVal := readingVal +
+						(newValPerDay * bpActual.Sub(readingTime).Hours() /
+							24)
+					newReading := &Reading{
+						Value: newVal,
+						Time:  bpActual,
+						Valid: true,
+					}
+					breakPointReadings[bpActual][subMeterID] = newReading
+					breakPointLastIndexes[subMeterID] += 1
+				} else {
+					// Set invalid reading for break point.
+					breakPointReadings[bpActual][subMeterID] = &Reading{}
+					breakPointLastIndexes[subMeterID] += 1
+					// Possible additional break point is set already.
+				}
+			}
+		}
+		laterReadings[subMeterID] = reading
+	}
+
+	slog.Debug("billing", "additionalBreakPoints", additionalBreakPoints)
+	additionalBreakPointsLen := len(additionalBreakPoints)
+	if additionalBreakPointsLen > 0 {
+		sort.Sort(sort.Reverse(additionalBreakPoints))
+		laterReadings = make(map[int32]*Reading)
+		breakPointLastIndexes = make(map[int32]int)        // From latest to earliest.
+		for _, subMeterReading := range subMeterReadings { // From latest to earliest.
+			readingDate := subMeterReading.ReadingDate
+			subMeterID := subMeterReading.SubMeterID
+			readingVal := subMeterReading.ReadingValue.Float64
+			readingTime := readingDate.Time
+			readingValid := readingDate.Valid
+			reading := &Reading{
+				Value:     readingVal,
+				Time:      readingTime,
+				Valid:     readingValid,
+				Estimated: subMeterReading.Estimated.Bool,
+			}
+			lastIndex := breakPointLastIndexes[subMeterID]
+			if lastIndex > additionalBreakPointsLen {
+				continue
+			}
+			if !readingValid { // Earliest reading.
+				// Set invalid reading for break points without reading.
+				for i := lastIndex; i < additionalBreakPointsLen; i++ {
+					bp := additionalBreakPoints[i]
+					bpActual := bp[1]
+					_, ok := breakPointReadings[bpActual]
 					if !ok {
 						breakPointReadings[bpActual] =
 							make(map[int32]*Reading)
 					}
-					r, ok := breakPointReadings[bpActual][subMeterID]
-					if ok {
-						if r.Valid {
-							hasValidReading = true
-						}
-					} else {
+					_, ok = breakPointReadings[bpActual][subMeterID]
+					if !ok {
 						breakPointReadings[bpActual][subMeterID] =
 							&Reading{}
 					}
-					if !lrInBp && lrTime.Compare(bpMax) <= 0 &&
-						lrTime.Compare(bpMin) >= 0 {
-						// Later reading is in break point range.
-						lrInBp = true
-					}
 				}
-				// Sub meter has at least one valid break point reading.
-				if hasValidReading {
-					bp := [3]time.Time{
-						lrTime.AddDate(0, 0, -dayDiff),
-						lrTime,
-						lrTime.AddDate(0, 0, dayDiff),
+				continue
+			}
+			var lrValGE bool
+			var lrVal float64
+			lr, ok := laterReadings[subMeterID]
+			if ok {
+				lrVal = lr.Value
+				if lrVal >= readingVal {
+					// Later reading value is greater or equal to current
+					// reading value.
+					lrValGE = true
+				}
+			}
+			for i := lastIndex; i < additionalBreakPointsLen; i++ {
+				bp := additionalBreakPoints[i]
+				bpMin := bp[0]
+				bpActual := bp[1]
+				bpMax := bp[2]
+				_, ok := breakPointReadings[bpActual]
+				if !ok {
+					breakPointReadings[bpActual] = make(map[int32]*Reading)
+				}
+				prevBpReading, prevBpReadingOk :=
+					breakPointReadings[bpActual][subMeterID]
+				if readingTime.After(bpMax) {
+					// After break point max.
+					break
+				} else if readingTime.Compare(bpMin) >= 0 {
+					// Between break point min and max.
+					if !prevBpReadingOk || reading.betterFor(bpActual, prevBpReading) {
+						// Actual reading over estimate, lower time difference
+						// or no previous reading.
+						breakPointReadings[bpActual][subMeterID] = reading
 					}
-					if !lrInBp &&
-						!slices.Contains(additionalBreakPoints, bp) &&
-						lrTime.Compare(mainMeterBillingMaxTime) <= 0 &&
-						lrTime.Compare(mainMeterBillingMinTime) >= 0 {
-						// Add later reading to additional break points.
-						additionalBreakPoints = append(
-							additionalBreakPoints, bp)
+					if bpActual.Compare(readingTime) >= 0 && !reading.Estimated {
+						// No better reading possible.
+						breakPointLastIndexes[subMeterID] += 1
+					}
+				} else {
+					// Before break point min.
+					if prevBpReadingOk && prevBpReading.Valid && prevBpReading.Estimated {
+						// Keep estimate in break point range, there is no
+						// earlier actual reading in the range.
+						breakPointLastIndexes[subMeterID] += 1
+					} else if lrValGE {
+						readingDayDiff :=
+							lr.Time.Sub(readingTime).Hours() / 24
+						newValPerDay :=
+							(lrVal - readingVal) / readingDayDiff
+						newVal := readingVal + (newValPerDay *
+							bpActual.Sub(readingTime).Hours() / 24)
+						newReading := &Reading{
+							Value: newVal,
+							Time:  bpActual,
+							Valid: true,
+						}
+						breakPointReadings[bpActual][subMeterID] =
+							newReading
+						breakPointLastIndexes[subMeterID] += 1
+					} else {
+						// Set invalid reading for break point.
+						breakPointReadings[bpActual][subMeterID] =
+							&Reading{}
+						breakPointLastIndexes[subMeterID] += 1
 					}
 				}
+			}
+			laterReadings[subMeterID] = reading
+		}
+		// Merge all break points.
+		calcBreakPoints = append(calcBreakPoints, additionalBreakPoints...)
+		sort.Sort(sort.Reverse(calcBreakPoints))
+	}
+
+	// Interval readings give exact readings at break points, so they replace
+	// the readings found or interpolated above.
+	bpEarliestTime, bpLatestTime := calcBreakPoints.ActualRange()
+	intervalDays, err := s.queries.ListMainMeterIntervalDays(
+		ctx, spinusdb.ListMainMeterIntervalDaysParams{
+			FkMainMeter: mainMeterID,
+			BeginTime: pgtype.Timestamptz{
+				Time: bpEarliestTime.AddDate(0, 0, 1), Valid: true},
+			EndTime: pgtype.Timestamptz{
+				Time: bpLatestTime.AddDate(0, 0, 1), Valid: true},
+		})
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	intervalReadings := newIntervalBreakPointReadings(
+		intervalDays, calcBreakPoints, s.location)
+	for subMeterID, readings := range intervalReadings {
+		for bpActual, reading := range readings {
+			if _, ok := breakPointReadings[bpActual][subMeterID]; ok {
+				breakPointReadings[bpActual][subMeterID] = reading
+			}
+		}
+	}
+
+	subMeterBillings := make(map[int32]*spinusdb.CreateSubMeterBillingParams)
+	subMeterBillingPeriods := make(
+		map[int]map[int32]*spinusdb.CreateSubMeterBillingPeriodParams)
+
+	calcBreakPointsLen = len(calcBreakPoints)
+
+	calcBpIndex := 0
+	bp := calcBreakPoints[calcBpIndex]
+	laterBreakPointReadings := breakPointReadings[bp[1]]
+	calcBpIndex++
+
+	subMeterLen := len(breakPointReadings[calcBreakPoints[calcBpIndex][1]])
+
+	// Prepare main meter billing period.
+	var mmServicePriceValid bool
+	var mmServicePricePerSm float64
+	mmBillingPeriodIndex = 0
+	subMeterBillingPeriods[mmBillingPeriodIndex] = make(
+		map[int32]*spinusdb.CreateSubMeterBillingPeriodParams)
+	mmBillingPeriod := mainMeterBillingPeriods[mmBillingPeriodIndex]
+	mmBeginTime := mmBillingPeriod.BeginDate.Time
+	mmMinTime := mmBeginTime.AddDate(0, 0, -1) // Shifted one day back.
+	mmEndTime := mmBillingPeriod.EndDate.Time
+	mmDays := mmEndTime.Sub(mmMinTime).Hours() / 24
+	mmBeginVal := mmBillingPeriod.BeginReadingValue
+	mmEndVal := mmBillingPeriod.EndReadingValue
+	mmConsumption := mmEndVal - mmBeginVal
+	mmValPerDay := (mmEndVal - mmBeginVal) / mmDays
+	mmConsumedEnergyPricePerUnit := mmBillingPeriod.ConsumedEnergyPrice / mmConsumption
+	if mmBillingPeriod.ServicePrice.Valid {
+		mmServicePriceValid = true
+		mmServicePricePerSm = mmBillingPeriod.ServicePrice.Float64 / float64(subMeterLen)
+	}
+	mmLaterBpVal := mmEndVal // Main meter later break point value.
+
+	for ; calcBpIndex < calcBreakPointsLen; calcBpIndex++ { // From latest to earliest.
+		bp := calcBreakPoints[calcBpIndex]
+		bpActual := bp[1]
+		var mmBpVal float64
+		var mmBpConsumption float64
+		if bpActual.After(mmMinTime) {
+			// Actual is between main meter billing period min and max.
+			// Need to calculate main meter reading value.
+			mmBpVal = mmBeginVal + (mmValPerDay * bpActual.Sub(mmMinTime).Hours() / 24)
+		} else {
+			mmBpVal = mmBeginVal
+		}
+		// Calculate main meter break point consumption.
+		mmBpConsumption = mmLaterBpVal - mmBpVal
+		mmLaterBpVal = mmBpVal
+		var invalidCount int
+		var readingsSum, laterReadingsSum float64
+		readings := breakPointReadings[bpActual]
+		for subMeterID, reading := range readings {
+			// Calculate invalid count and sums for current and later break point.
+			// Sum must be calculated every time even for later break point
+			// because later reading can be lower then current reading.
+			laterReading := laterBreakPointReadings[subMeterID]
+			if !reading.Valid || !laterReading.Valid {
+				invalidCount++
+				continue
+			}
+			readingVal := reading.Value
+			laterReadingVal := laterReading.Value
+			if readingVal > laterReadingVal {
+				invalidCount++
 			} else {
-				// No later reading, set invalid reading for all break points.
-				for _, bp := range calcBreakPoints {
-					bpActual := bp[1]
-					_, ok := breakPointReadings[bpActual]
-					if !ok {
-						breakPointReadings[bpActual] =
-							make(map[int32]*Reading)
-					}
-					breakPointReadings[bpActual][subMeterID] = &Reading{}
-				}
+				readingsSum += readingVal
+				laterReadingsSum += laterReadingVal
 			}
-			continue // There is no other reading for this sub meter.
+
 		}
-		var additionalBp [3]time.Time
-		var lrValGE bool // Later value greater or equal to current reading value.
-		var lrVal float64
-		lr, ok := laterReadings[subMeterID]
-		if ok {
-			lrVal = lr.Value
-			if lrVal >= readingVal {
-				// Later reading value is greater or equal to current reading
-				// value.
-				lrValGE = true
-			} else {
-				// Later reading value is lower than current reading value.
-				lrTime := lr.Time
-				bp := [3]time.Time{
-					lrTime.AddDate(0, 0, -dayDiff),
-					lrTime,
-					lrTime.AddDate(0, 0, dayDiff),
+		var energyConsumptionValidAddendum float64
+		var energyConsumptionInvalidAddendum float64
+		if invalidCount == 0 {
+			// All valid, split difference equally to all sub meters.
+			energyConsumptionValidAddendum =
+				(mmBpConsumption - (laterReadingsSum - readingsSum)) /
+					float64(subMeterLen)
+		} else {
+			// At least one invalid, split difference only to sub meters with invalid
+			// reading.
+			energyConsumptionInvalidAddendum =
+				(mmBpConsumption - (laterReadingsSum - readingsSum)) /
+					float64(invalidCount)
+		}
+		for subMeterID, reading := range readings {
+			// Calculate energy consumption and energy consumption price.
+			smBillingPeriod, ok :=
+				subMeterBillingPeriods[mmBillingPeriodIndex][subMeterID]
+			if !ok {
+				smBillingPeriod = &spinusdb.CreateSubMeterBillingPeriodParams{}
+				if mmServicePriceValid {
+					smBillingPeriod.ServicePrice = pgtype.Float8{
+						Float64: mmServicePricePerSm, Valid: true}
 				}
-				if !slices.Contains(calcBreakPoints, bp) &&
-					!slices.Contains(additionalBreakPoints, bp) &&
-					lrTime.Compare(mainMeterBillingMaxTime) <= 0 &&
-					lrTime.Compare(mainMeterBillingMinTime) >= 0 {
-					// Add later reading to additional break points.
-					additionalBreakPoints = append(additionalBreakPoints, bp)
+				subMeterBillingPeriods[mmBillingPeriodIndex][subMeterID] =
+					smBillingPeriod
+			}
+			var energyConsumption float64
+			laterReading := laterBreakPointReadings[subMeterID]
+			if !reading.Valid || !laterReading.Valid {
+				energyConsumption = energyConsumptionInvalidAddendum
+			} else {
+				readingVal := reading.Value
+				laterReadingVal := laterReading.Value
+				if readingVal > laterReadingVal {
+					energyConsumption = energyConsumptionInvalidAddendum
+				} else {
+					energyConsumption = laterReadingVal - readingVal +
+						energyConsumptionValidAddendum
 				}
-				bp = [3]time.Time{
-					readingTime.AddDate(0, 0, -dayDiff),
-					readingTime,
-					readingTime.AddDate(0, 0, dayDiff),
+			}
+			smBillingPeriod.EnergyConsumption += energyConsumption
+			smBillingPeriod.ConsumedEnergyPrice +=
+				energyConsumption * mmConsumedEnergyPricePerUnit
+		}
+		if bpActual == mmMinTime {
+			// Earliest break point for current main meter billing period.
+			smBillingPeriods := subMeterBillingPeriods[mmBillingPeriodIndex]
+			for smID, smBillingPeriod := range smBillingPeriods {
+				// Calculate all prices for sub meter billing periods and main
+				// meter billing period.
+				smBilling, ok := subMeterBillings[smID]
+				if !ok {
+					smBilling = &spinusdb.CreateSubMeterBillingParams{
+						FkSubMeter: smID}
+					subMeterBillings[smID] = smBilling
 				}
-				if !slices.Contains(calcBreakPoints, bp) &&
-					!slices.Contains(additionalBreakPoints, bp) &&
-					readingTime.Compare(mainMeterBillingMaxTime) <= 0 &&
-					readingTime.Compare(mainMeterBillingMinTime) >= 0 {
-					// Add reading to additional break points.
-					additionalBreakPoints = append(additionalBreakPoints, bp)
+
+				energyConsumption := smBillingPeriod.EnergyConsumption
+				consumedEnergyPrice := smBillingPeriod.ConsumedEnergyPrice
+				var servicePrice float64
+				if mmServicePriceValid {
+					servicePrice = smBillingPeriod.ServicePrice.Float64
+					smBilling.ServicePrice.Float64 += servicePrice
+					smBilling.ServicePrice.Valid = true
 				}
+				advancePrice := consumedEnergyPrice + servicePrice
+				totalPrice := consumedEnergyPrice + servicePrice + advancePrice
+				smBillingPeriod.AdvancePrice = advancePrice
+				smBillingPeriod.TotalPrice = totalPrice
+				smBilling.EnergyConsumption += energyConsumption
+				smBilling.ConsumedEnergyPrice += consumedEnergyPrice
+				smBilling.AdvancePrice += advancePrice
+				smBilling.TotalPrice += totalPrice
+				mmBillingPeriod.AdvancePrice += advancePrice
+				mmBillingPeriod.TotalPrice += advancePrice
 			}
-		} else {
-			// No later reading.
-			bp := [3]time.Time{
-				readingTime.AddDate(0, 0, -dayDiff),
-				readingTime,
-				readingTime.AddDate(0, 0, dayDiff),
+			// Update main meter billing.
+			mmBillingPeriodAdvancePrice := mmBillingPeriod.AdvancePrice
+			mainMeterBilling.AdvancePrice += mmBillingPeriodAdvancePrice
+			mainMeterBilling.TotalPrice += mmBillingPeriodAdvancePrice
+			mmBillingPeriodIndex++
+			if mmBillingPeriodIndex == billingPeriodsLen {
+				break
 			}
-			if !slices.Contains(calcBreakPoints, bp) &&
-				!slices.Contains(additionalBreakPoints, bp) &&
-				readingTime.Compare(mainMeterBillingMaxTime) <= 0 &&
-				readingTime.Compare(mainMeterBillingMinTime) >= 0 {
-				// Prepare current reading as possible additional break point.
-				additionalBp = bp
+
+			// Prepare main meter billing period.
+			subMeterBillingPeriods[mmBillingPeriodIndex] = make(
+				map[int32]*spinusdb.CreateSubMeterBillingPeriodParams)
+			mmBillingPeriod = mainMeterBillingPeriods[mmBillingPeriodIndex]
+			mmBeginTime = mmBillingPeriod.BeginDate.Time
+			mmMinTime = mmBeginTime.AddDate(0, 0, -1)
+			mmEndTime = mmBillingPeriod.EndDate.Time
+			mmDays = mmEndTime.Sub(mmMinTime).Hours() / 24
+			mmBeginVal = mmBillingPeriod.BeginReadingValue
+			mmEndVal = mmBillingPeriod.EndReadingValue
+			mmConsumption = mmEndVal - mmBeginVal
+			mmValPerDay = (mmEndVal - mmBeginVal) / mmDays
+			mmConsumedEnergyPricePerUnit =
+				mmBillingPeriod.ConsumedEnergyPrice / mmConsumption
+			mmServicePricePerSm =
+				mmBillingPeriod.ServicePrice.Float64 / float64(subMeterLen)
+			if mmBillingPeriod.ServicePrice.Valid {
+				mmServicePriceValid = true
+				mmServicePricePerSm =
+					mmBillingPeriod.ServicePrice.Float64 / float64(subMeterLen)
+			}
+			mmLaterBpVal = mmEndVal
+		}
+		laterBreakPointReadings = breakPointReadings[bpActual]
+	}
+
+	// Allocate cost items to sub meters.
+	subMeterBillingCostItems := make(
+		map[int]map[int32]*spinusdb.CreateSubMeterBillingCostItemParams)
+	for i, costItem := range mainMeterBillingCostItems {
+		weights := costItemWeights[i]
+		// Only billed sub meters take part in allocation.
+		var weightsSum float64
+		for smID := range subMeterBillings {
+			weightsSum += weights[smID]
+		}
+		if weightsSum == 0 {
+			tmplData.CostItems[i].AllocationKeyError =
+				billedAllocationKeyErrors[costItem.AllocationKey]
+			formError = true
+			continue
+		}
+		subMeterBillingCostItems[i] = make(
+			map[int32]*spinusdb.CreateSubMeterBillingCostItemParams)
+		for smID, smBilling := range subMeterBillings {
+			share := weights[smID] / weightsSum
+			price := costItem.Price * share
+			subMeterBillingCostItems[i][smID] =
+				&spinusdb.CreateSubMeterBillingCostItemParams{
+					Share: share, Price: price}
+			smBilling.CostItemPrice += price
+			smBilling.TotalPrice += price
+		}
+		mainMeterBilling.CostItemPrice += costItem.Price
+		mainMeterBilling.TotalPrice += costItem.Price
+	}
+	if formError {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	createdMainMeterBilling, err := qtx.CreateMainMeterBilling(ctx, mainMeterBilling)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	createdMainMeterBillingID := createdMainMeterBilling.ID
+
+	createdSubMeterBillingIDs := make(map[int32]int32)
+
+	// Numbers are allocated in order of sub meters.
+	smIDs := make([]int32, 0, len(subMeterBillings))
+	for smID := range subMeterBillings {
+		smIDs = append(smIDs, smID)
+	}
+	slices.Sort(smIDs)
+	numberingYear := int32(mainMeterBilling.EndDate.Time.Year())
+	for _, smID := range smIDs {
+		smBilling := subMeterBillings[smID]
+		smBilling.FkMainBilling = createdMainMeterBillingID
+		number, err := allocateNumber(ctx, qtx, mainMeter.FkUser, numberingYear)
+		if err != nil {
+			slog.Error("error allocating number", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		smBilling.Number = pgtype.Text{String: number, Valid: true}
+		createdSubMeterBilling, err := qtx.CreateSubMeterBilling(ctx, *smBilling)
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		createdSubMeterBillingIDs[smID] = createdSubMeterBilling.ID
+	}
+
+	for i, mmBillingPeriod := range mainMeterBillingPeriods {
+		mmBillingPeriod.FkMainBilling = createdMainMeterBillingID
+		createdMainMeterBillingPeriod, err := qtx.CreateMainMeterBillingPeriod(
+			ctx, *mmBillingPeriod)
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		createdMainMeterBillingPeriodID := createdMainMeterBillingPeriod.ID
+		smBillingPeriods := subMeterBillingPeriods[i]
+		for smID, smBillingPeriod := range smBillingPeriods {
+			smBillingPeriod.FkSubBilling = createdSubMeterBillingIDs[smID]
+			smBillingPeriod.FkMainBillingPeriod = createdMainMeterBillingPeriodID
+			_, err := qtx.CreateSubMeterBillingPeriod(ctx, *smBillingPeriod)
+			if err != nil {
+				slog.Error("error executing query", "err", err)
+				s.HandleInternalServerError(w, r, err)
+				return
 			}
 		}
-		var readingInBp bool // Reading in break point range.
-		for i := lastIndex; i < calcBreakPointsLen; i++ {
-			bp := calcBreakPoints[i]
-			bpMin := bp[0]
-			bpActual := bp[1]
-			bpMax := bp[2]
-			_, ok := breakPointReadings[bpActual]
-			if !ok {
-				breakPointReadings[bpActual] = make(map[int32]*Reading)
-			}
-			prevBpReading, prevBpReadingOk := breakPointReadings[bpActual][subMeterID]
-			if readingTime.After(bpMax) {
-				// After break point max.
-				if !readingInBp && !additionalBp[1].IsZero() {
-					// Add additional break point when set.
-					additionalBreakPoints = append(
-						additionalBreakPoints, additionalBp)
-				}
-				break
-			} else if readingTime.Compare(bpMin) >= 0 {
-				// Between break point min and max.
-				if !prevBpReadingOk || bpActual.Sub(readingTime) <=
-					bpActual.Sub(prevBpReading.Time) {
-					// Lower time difference or no previous reading.
-					breakPointReadings[bpActual][subMeterID] = reading
-				}
-				if bpActual.Compare(readingTime) >= 0 {
-					// No better reading possible.
-					breakPointLastIndexes[subMeterID] += 1
-				}
-				readingInBp = true
-			} else {
-				// Before break point min.
-				if lrValGE {
-					readingDayDiff := lr.Time.Sub(readingTime).Hours() / 24
-					newValPerDay := (lrVal - readingVal) / readingDayDiff
-					newVal := readingVal +
-						(newValPerDay * bpActual.Sub(readingTime).Hours() /
-							24)
-					newReading := &Reading{
-						Value: newVal,
-						Time:  bpActual,
-						Valid: true,
-					}
-					breakPointReadings[bpActual][subMeterID] = newReading
-					breakPointLastIndexes[subMeterID] += 1
-				} else {
-					// Set invalid reading for break point.
-					breakPointReadings[bpActual][subMeterID] = &Reading{}
-					breakPointLastIndexes[subMeterID] += 1
-					// Possible additional break point is set already.
-				}
+	}
+
+	for i, costItem := range mainMeterBillingCostItems {
+		costItem.FkMainBilling = createdMainMeterBillingID
+		createdCostItem, err := qtx.CreateMainMeterBillingCostItem(ctx, *costItem)
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		createdCostItemID := createdCostItem.ID
+		for smID, smCostItem := range subMeterBillingCostItems[i] {
+			smCostItem.FkSubBilling = createdSubMeterBillingIDs[smID]
+			smCostItem.FkMainBillingCostItem = createdCostItemID
+			_, err := qtx.CreateSubMeterBillingCostItem(ctx, *smCostItem)
+			if err != nil {
+				slog.Error("error executing query", "err", err)
+				s.HandleInternalServerError(w, r, err)
+				return
 			}
 		}
-		laterReadings[subMeterID] = reading
 	}
 
-	slog.Debug("billing", "additionalBreakPoints", additionalBreakPoints)
-	additionalBreakPointsLen := len(additionalBreakPoints)
-	if additionalBreakPointsLen > 0 {
-		sort.Sort(sort.Reverse(additionalBreakPoints))
-		laterReadings = make(map[int32]*Reading)
-		breakPointLastIndexes = make(map[int32]int)        // From latest to earliest.
-		for _, subMeterReading := range subMeterReadings { // From latest to earliest.
-			readingDate := subMeterReading.ReadingDate
-			subMeterID := subMeterReading.SubMeterID
-			readingVal := subMeterReading.ReadingValue.Float64
-			readingTime := readingDate.Time
-			readingValid := readingDate.Valid
-			reading := &Reading{
-				Value: readingVal,
-				Time:  readingTime,
-				Valid: readingValid,
-			}
-			lastIndex := breakPointLastIndexes[subMeterID]
-			if lastIndex > additionalBreakPointsLen {
-				continue
-			}
-			if !readingValid { // Earliest reading.
-				// Set invalid reading for break points without reading.
-				for i := lastIndex; i < additionalBreakPointsLen; i++ {
-					bp := additionalBreakPoints[i]
-					bpActual := bp[1]
-					_, ok := breakPointReadings[bpActual]
-					if !ok {
-						breakPointReadings[bpActual] =
-							make(map[int32]*Reading)
-					}
-					_, ok = breakPointReadings[bpActual][subMeterID]
-					if !ok {
-						breakPointReadings[bpActual][subMeterID] =
-							&Reading{}
-					}
-				}
-				continue
-			}
-			var lrValGE bool
-			var lrVal float64
-			lr, ok := laterReadings[subMeterID]
-			if ok {
-				lrVal = lr.Value
-				if lrVal >= readingVal {
-					// Later reading value is greater or equal to current
-					// reading value.
-					lrValGE = true
-				}
-			}
-			for i := lastIndex; i < additionalBreakPointsLen; i++ {
-				bp := additionalBreakPoints[i]
-				bpMin := bp[0]
-				bpActual := bp[1]
-				bpMax := bp[2]
-				_, ok := breakPointReadings[bpActual]
-				if !ok {
-					breakPointReadings[bpActual] = make(map[int32]*Reading)
-				}
-				prevBpReading, prevBpReadingOk :=
-					breakPointReadings[bpActual][subMeterID]
-				if readingTime.After(bpMax) {
-					// After break point max.
-					break
-				} else if readingTime.Compare(bpMin) >= 0 {
-					// Between break point min and max.
-					if !prevBpReadingOk || bpActual.Sub(readingTime) <=
-						bpActual.Sub(prevBpReading.Time) {
-						// Lower time difference or no previous reading.
-						breakPointReadings[bpActual][subMeterID] = reading
-					}
-					if bpActual.Compare(readingTime) >= 0 {
-						// No better reading possible.
-						breakPointLastIndexes[subMeterID] += 1
-					}
-				} else {
-					// Before break point min.
-					if lrValGE {
-						readingDayDiff :=
-							lr.Time.Sub(readingTime).Hours() / 24
-						newValPerDay :=
-							(lrVal - readingVal) / readingDayDiff
-						newVal := readingVal + (newValPerDay *
-							bpActual.Sub(readingTime).Hours() / 24)
-						newReading := &Reading{
-							Value: newVal,
-							Time:  bpActual,
-							Valid: true,
-						}
-						breakPointReadings[bpActual][subMeterID] =
-							newReading
-						breakPointLastIndexes[subMeterID] += 1
-					} else {
-						// Set invalid reading for break point.
-						breakPointReadings[bpActual][subMeterID] =
-							&Reading{}
-						breakPointLastIndexes[subMeterID] += 1
-					}
-				}
-			}
-			laterReadings[subMeterID] = reading
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r,
+		fmt.Sprintf(
+			"/main-meter/%d/billing/%d/overview",
+			mainMeterID, createdMainMeterBilling.Subid,
+		),
+		http.StatusSeeOther,
+	)
+}
+
+func (s *Server) HandlePostMainMeterBillingImportISDOC(
+	w http.ResponseWriter, r *http.Request,
+) {
+	const tmplName = "mainMeterBillingCreate"
+
+	ctx := r.Context()
+	mainMeter, ok := GetMainMeter(ctx)
+	if !ok {
+		slog.Error("error getting main meter", "mainMeter", mainMeter)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter"))
+		return
+	}
+	mainMeterID := mainMeter.ID
+
+	tmplData := MainMeterBillingCreateTmplData{
+		MainMeterBillingFormData: NewMainMeterBillingFormData(),
+		Upper:                    MainMeterTmplData{ID: mainMeterID},
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, _, err := r.FormFile("invoice")
+	if err != nil {
+		slog.Error("error reading form file", "err", err)
+		tmplData.GeneralError = "Upload ISDOC invoice."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+	defer file.Close()
+	invoice, err := isdoc.Parse(file)
+	if err != nil {
+		slog.Error("error parsing invoice", "err", err)
+		tmplData.GeneralError = "Upload valid ISDOC invoice."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
+
+	var lastBillingPeriod *spinusdb.MainMeterBillingPeriod
+	billingPeriod, err := s.queries.GetLastMainMeterBillingPeriod(ctx, mainMeterID)
+	if err == nil {
+		lastBillingPeriod = &billingPeriod
+	} else if err != pgx.ErrNoRows {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	tmplData.MainMeterBillingFormData, tmplData.ImportWarnings =
+		newMainMeterBillingFormDataFromInvoice(invoice, mainMeter.Energy, lastBillingPeriod)
+	s.renderTemplate(w, r, tmplName, tmplData)
+}
+
+func (s *Server) HandleGetStatementList(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "statementList"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	statements, err := s.queries.ListStatements(ctx, userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	s.renderTemplate(w, r, tmplName, statements)
+}
+
+func (s *Server) HandleGetStatementCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "statementCreate"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	tenants, err := s.queries.ListStatementTenants(ctx, userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		StatementCreateTmplData{StatementFormData: StatementFormData{}, Tenants: tenants},
+	)
+}
+
+func (s *Server) HandlePostStatementCreate(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "statementCreate"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	tenants, err := s.queries.ListStatementTenants(ctx, userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	tmplData := StatementCreateTmplData{
+		StatementFormData: StatementFormData{}, Tenants: tenants}
+	var formError bool
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		tmplData.GeneralError = "Bad request"
+		if err := s.templates.Render(w, tmplName, tmplData); err != nil {
+			slog.Error("error rendering template", "template", tmplName, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
-		// Merge all break points.
-		calcBreakPoints = append(calcBreakPoints, additionalBreakPoints...)
-		sort.Sort(sort.Reverse(calcBreakPoints))
+		return
 	}
 
-	subMeterBillings := make(map[int32]*spinusdb.CreateSubMeterBillingParams)
-	subMeterBillingPeriods := make(
-		map[int]map[int32]*spinusdb.CreateSubMeterBillingPeriodParams)
+	iTenant := r.PostFormValue("tenant")
+	tmplData.Tenant = iTenant
+	tenantID, err := parseTenantID(iTenant)
+	if err == nil {
+		if !slices.ContainsFunc(
+			tenants,
+			func(t spinusdb.ListStatementTenantsRow) bool { return t.ID == int32(tenantID) },
+		) {
+			tmplData.TenantError = "Select valid tenant."
+			formError = true
+		}
+	} else {
+		tmplData.TenantError = err.Error()
+		formError = true
+	}
 
-	calcBreakPointsLen = len(calcBreakPoints)
+	iBeginDate := r.PostFormValue("begin-date")
+	tmplData.BeginDate = iBeginDate
+	beginTime, err := parseDate(iBeginDate)
+	if err != nil {
+		tmplData.BeginDateError = err.Error()
+		formError = true
+	}
 
-	calcBpIndex := 0
-	bp := calcBreakPoints[calcBpIndex]
-	laterBreakPointReadings := breakPointReadings[bp[1]]
-	calcBpIndex++
+	iEndDate := r.PostFormValue("end-date")
+	tmplData.EndDate = iEndDate
+	endTime, err := parseDate(iEndDate)
+	if err == nil {
+		if endTime.Before(beginTime.Time) {
+			tmplData.EndDateError = "End date must be greater or equal to begin date."
+			formError = true
+		}
+	} else {
+		tmplData.EndDateError = err.Error()
+		formError = true
+	}
 
-	subMeterLen := len(breakPointReadings[calcBreakPoints[calcBpIndex][1]])
+	if formError {
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
+	}
 
-	// Prepare main meter billing period.
-	var mmServicePriceValid bool
-	var mmServicePricePerSm float64
-	mmBillingPeriodIndex = 0
-	subMeterBillingPeriods[mmBillingPeriodIndex] = make(
-		map[int32]*spinusdb.CreateSubMeterBillingPeriodParams)
-	mmBillingPeriod := mainMeterBillingPeriods[mmBillingPeriodIndex]
-	mmBeginTime := mmBillingPeriod.BeginDate.Time
-	mmMinTime := mmBeginTime.AddDate(0, 0, -1) // Shifted one day back.
-	mmEndTime := mmBillingPeriod.EndDate.Time
-	mmDays := mmEndTime.Sub(mmMinTime).Hours() / 24
-	mmBeginVal := mmBillingPeriod.BeginReadingValue
-	mmEndVal := mmBillingPeriod.EndReadingValue
-	mmConsumption := mmEndVal - mmBeginVal
-	mmValPerDay := (mmEndVal - mmBeginVal) / mmDays
-	mmConsumedEnergyPricePerUnit := mmBillingPeriod.ConsumedEnergyPrice / mmConsumption
-	if mmBillingPeriod.ServicePrice.Valid {
-		mmServicePriceValid = true
-		mmServicePricePerSm = mmBillingPeriod.ServicePrice.Float64 / float64(subMeterLen)
+	beginDate := pgtype.Date{Time: beginTime.Time, Valid: true}
+	endDate := pgtype.Date{Time: endTime.Time, Valid: true}
+	subMeterBillings, err := s.queries.ListTenantSubMeterBillings(
+		ctx,
+		spinusdb.ListTenantSubMeterBillingsParams{
+			MainUserID: userID,
+			TenantID:   int32(tenantID),
+			BeginDate:  beginDate,
+			EndDate:    endDate,
+		},
+	)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if len(subMeterBillings) == 0 {
+		tmplData.GeneralError = "There is no billing for the tenant in the given period."
+		s.renderTemplate(w, r, tmplName, tmplData)
+		return
 	}
-	mmLaterBpVal := mmEndVal // Main meter later break point value.
 
-	for ; calcBpIndex < calcBreakPointsLen; calcBpIndex++ { // From latest to earliest.
-		bp := calcBreakPoints[calcBpIndex]
-		bpActual := bp[1]
-		var mmBpVal float64
-		var mmBpConsumption float64
-		if bpActual.After(mmMinTime) {
-			// Actual is between main meter billing period min and max.
-			// Need to calculate main meter reading value.
-			mmBpVal = mmBeginVal + (mmValPerDay * bpActual.Sub(mmMinTime).Hours() / 24)
-		} else {
-			mmBpVal = mmBeginVal
-		}
-		// Calculate main meter break point consumption.
-		mmBpConsumption = mmLaterBpVal - mmBpVal
-		mmLaterBpVal = mmBpVal
-		var invalidCount int
-		var readingsSum, laterReadingsSum float64
-		readings := breakPointReadings[bpActual]
-		for subMeterID, reading := range readings {
-			// Calculate invalid count and sums for current and later break point.
-			// Sum must be calculated every time even for later break point
-			// because later reading can be lower then current reading.
-			laterReading := laterBreakPointReadings[subMeterID]
-			if !reading.Valid || !laterReading.Valid {
-				invalidCount++
-				continue
-			}
-			readingVal := reading.Value
-			laterReadingVal := laterReading.Value
-			if readingVal > laterReadingVal {
-				invalidCount++
-			} else {
-				readingsSum += readingVal
-				laterReadingsSum += laterReadingVal
-			}
+	statement := spinusdb.CreateStatementParams{
+		FkUser:    userID,
+		FkTenant:  int32(tenantID),
+		BeginDate: beginDate,
+		EndDate:   endDate,
+	}
+	for _, subMeterBilling := range subMeterBillings {
+		statement.AdvancePrice += subMeterBilling.AdvancePrice
+		statement.TotalPrice += subMeterBilling.TotalPrice
+	}
+	statement.Balance = statement.TotalPrice - statement.AdvancePrice
 
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	createdStatement, err := qtx.CreateStatement(ctx, statement)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	for _, subMeterBilling := range subMeterBillings {
+		err := qtx.CreateStatementSubMeterBilling(
+			ctx,
+			spinusdb.CreateStatementSubMeterBillingParams{
+				FkStatement:  createdStatement.ID,
+				FkSubBilling: subMeterBilling.ID,
+			},
+		)
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
 		}
-		var energyConsumptionValidAddendum float64
-		var energyConsumptionInvalidAddendum float64
-		if invalidCount == 0 {
-			// All valid, split difference equally to all sub meters.
-			energyConsumptionValidAddendum =
-				(mmBpConsumption - (laterReadingsSum - readingsSum)) /
-					float64(subMeterLen)
-		} else {
-			// At least one invalid, split difference only to sub meters with invalid
-			// reading.
-			energyConsumptionInvalidAddendum =
-				(mmBpConsumption - (laterReadingsSum - readingsSum)) /
-					float64(invalidCount)
-		}
-		for subMeterID, reading := range readings {
-			// Calculate energy consumption and energy consumption price.
-			smBillingPeriod, ok :=
-				subMeterBillingPeriods[mmBillingPeriodIndex][subMeterID]
-			if !ok {
-				smBillingPeriod = &spinusdb.CreateSubMeterBillingPeriodParams{}
-				if mmServicePriceValid {
-					smBillingPeriod.ServicePrice = pgtype.Float8{
-						Float64: mmServicePricePerSm, Valid: true}
-				}
-				subMeterBillingPeriods[mmBillingPeriodIndex][subMeterID] =
-					smBillingPeriod
-			}
-			var energyConsumption float64
-			laterReading := laterBreakPointReadings[subMeterID]
-			if !reading.Valid || !laterReading.Valid {
-				energyConsumption = energyConsumptionInvalidAddendum
-			} else {
-				readingVal := reading.Value
-				laterReadingVal := laterReading.Value
-				if readingVal > laterReadingVal {
-					energyConsumption = energyConsumptionInvalidAddendum
-				} else {
-					energyConsumption = laterReadingVal - readingVal +
-						energyConsumptionValidAddendum
-				}
-			}
-			smBillingPeriod.EnergyConsumption += energyConsumption
-			smBillingPeriod.ConsumedEnergyPrice +=
-				energyConsumption * mmConsumedEnergyPricePerUnit
-		}
-		if bpActual == mmMinTime {
-			// Earliest break point for current main meter billing period.
-			smBillingPeriods := subMeterBillingPeriods[mmBillingPeriodIndex]
-			for smID, smBillingPeriod := range smBillingPeriods {
-				// Calculate all prices for sub meter billing periods and main
-				// meter billing period.
-				smBilling, ok := subMeterBillings[smID]
-				if !ok {
-					smBilling = &spinusdb.CreateSubMeterBillingParams{
-						FkSubMeter: smID}
-					subMeterBillings[smID] = smBilling
-				}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("error committing transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(
+		w, r,
+		fmt.Sprintf("/statement/%d/overview", createdStatement.Subid),
+		http.StatusSeeOther,
+	)
+}
+
+func (s *Server) HandleGetStatementOverview(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "statementOverview"
+
+	ctx := r.Context()
+	statement, ok := GetStatement(ctx)
+	if !ok {
+		slog.Error("error getting statement", "statement", statement)
+		s.HandleInternalServerError(w, r, errors.New("error getting statement"))
+		return
+	}
+	subMeterBillings, err := s.queries.ListStatementSubMeterBillings(ctx, statement.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	s.renderTemplate(
+		w, r,
+		tmplName,
+		StatementOverviewTmplData{
+			GetStatementRow: statement,
+			Sections:        newStatementSections(subMeterBillings),
+			Upper:           StatementTmplData{Subid: statement.Subid},
+		},
+	)
+}
+
+func (s *Server) HandleGetStatementExportCSV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	statement, ok := GetStatement(ctx)
+	if !ok {
+		slog.Error("error getting statement", "statement", statement)
+		s.HandleInternalServerError(w, r, errors.New("error getting statement"))
+		return
+	}
+	subMeterBillings, err := s.queries.ListStatementSubMeterBillings(ctx, statement.ID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"statement-%d.csv\"", statement.Subid),
+	)
+	if err := writeStatementCSV(w, statement, subMeterBillings); err != nil {
+		slog.Error("error writing csv", "err", err)
+	}
+}
+
+func (s *Server) HandleGetSubMeterBillingStatementPDF(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	mainMeterBilling, ok := GetMainMeterBilling(ctx)
+	if !ok {
+		slog.Error("error getting main meter billing", "mainMeterBilling", mainMeterBilling)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter billing"))
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "subMeterID"), 10, 32)
+	if err != nil {
+		s.HandleNotFound(w, r)
+		return
+	}
+	subMeterSubid := int32(id)
+
+	mainMeter, err := s.queries.GetMainMeter(ctx, mainMeterBilling.FkMainMeter)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	mainMeterBillingID := mainMeterBilling.ID
+	subMeterBillings, err := s.queries.ListSubMeterBillings(ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	subMeterBillingIndex := slices.IndexFunc(
+		subMeterBillings,
+		func(smBilling spinusdb.ListSubMeterBillingsRow) bool {
+			return smBilling.SubMeterSubid == subMeterSubid
+		},
+	)
+	if subMeterBillingIndex == -1 {
+		s.HandleNotFound(w, r)
+		return
+	}
+	costItems, err := s.queries.ListSubMeterBillingCostItems(ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	issuer, err := s.getIssuer(ctx, mainMeterBilling.MainUserID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	statement, err := s.newSubMeterStatement(
+		ctx,
+		mainMeter,
+		mainMeterBilling,
+		subMeterBillings[subMeterBillingIndex],
+		costItems,
+		issuer,
+	)
+	if err != nil {
+		slog.Error("error creating sub meter statement", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
 
-				energyConsumption := smBillingPeriod.EnergyConsumption
-				consumedEnergyPrice := smBillingPeriod.ConsumedEnergyPrice
-				var servicePrice float64
-				if mmServicePriceValid {
-					servicePrice = smBillingPeriod.ServicePrice.Float64
-					smBilling.ServicePrice.Float64 += servicePrice
-					smBilling.ServicePrice.Valid = true
-				}
-				advancePrice := consumedEnergyPrice + servicePrice
-				totalPrice := consumedEnergyPrice + servicePrice + advancePrice
-				smBillingPeriod.AdvancePrice = advancePrice
-				smBillingPeriod.TotalPrice = totalPrice
-				smBilling.EnergyConsumption += energyConsumption
-				smBilling.ConsumedEnergyPrice += consumedEnergyPrice
-				smBilling.AdvancePrice += advancePrice
-				smBilling.TotalPrice += totalPrice
-				mmBillingPeriod.AdvancePrice += advancePrice
-				mmBillingPeriod.TotalPrice += advancePrice
-			}
-			// Update main meter billing.
-			mmBillingPeriodAdvancePrice := mmBillingPeriod.AdvancePrice
-			mainMeterBilling.AdvancePrice += mmBillingPeriodAdvancePrice
-			mainMeterBilling.TotalPrice += mmBillingPeriodAdvancePrice
-			mmBillingPeriodIndex++
-			if mmBillingPeriodIndex == billingPeriodsLen {
-				break
-			}
+	var buf bytes.Buffer
+	if err := pdf.WriteSubMeterStatement(&buf, statement); err != nil {
+		slog.Error("error writing pdf", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf(
+			"attachment; filename=\"%s\"",
+			subMeterStatementFilename(mainMeterBilling.Subid, subMeterSubid),
+		),
+	)
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("error writing to buffer", "err", err)
+	}
+}
 
-			// Prepare main meter billing period.
-			subMeterBillingPeriods[mmBillingPeriodIndex] = make(
-				map[int32]*spinusdb.CreateSubMeterBillingPeriodParams)
-			mmBillingPeriod = mainMeterBillingPeriods[mmBillingPeriodIndex]
-			mmBeginTime = mmBillingPeriod.BeginDate.Time
-			mmMinTime = mmBeginTime.AddDate(0, 0, -1)
-			mmEndTime = mmBillingPeriod.EndDate.Time
-			mmDays = mmEndTime.Sub(mmMinTime).Hours() / 24
-			mmBeginVal = mmBillingPeriod.BeginReadingValue
-			mmEndVal = mmBillingPeriod.EndReadingValue
-			mmConsumption = mmEndVal - mmBeginVal
-			mmValPerDay = (mmEndVal - mmBeginVal) / mmDays
-			mmConsumedEnergyPricePerUnit =
-				mmBillingPeriod.ConsumedEnergyPrice / mmConsumption
-			mmServicePricePerSm =
-				mmBillingPeriod.ServicePrice.Float64 / float64(subMeterLen)
-			if mmBillingPeriod.ServicePrice.Valid {
-				mmServicePriceValid = true
-				mmServicePricePerSm =
-					mmBillingPeriod.ServicePrice.Float64 / float64(subMeterLen)
-			}
-			mmLaterBpVal = mmEndVal
-		}
-		laterBreakPointReadings = breakPointReadings[bpActual]
+func (s *Server) HandleGetMainMeterBillingStatementZip(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	mainMeterBilling, ok := GetMainMeterBilling(ctx)
+	if !ok {
+		slog.Error("error getting main meter billing", "mainMeterBilling", mainMeterBilling)
+		s.HandleInternalServerError(w, r, errors.New("error getting main meter billing"))
+		return
 	}
 
-	tx, err := s.postgresClient.Begin(ctx)
+	mainMeter, err := s.queries.GetMainMeter(ctx, mainMeterBilling.FkMainMeter)
 	if err != nil {
-		slog.Error("error beginning transaction", "err", err)
+		slog.Error("error executing query", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
-	defer tx.Rollback(ctx)
-	qtx := s.queries.WithTx(tx)
-	createdMainMeterBilling, err := qtx.CreateMainMeterBilling(ctx, mainMeterBilling)
+	mainMeterBillingID := mainMeterBilling.ID
+	subMeterBillings, err := s.queries.ListSubMeterBillings(ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	costItems, err := s.queries.ListSubMeterBillingCostItems(ctx, mainMeterBillingID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	issuer, err := s.getIssuer(ctx, mainMeterBilling.MainUserID)
 	if err != nil {
 		slog.Error("error executing query", "err", err)
 		s.HandleInternalServerError(w, r, err)
 		return
 	}
-	createdMainMeterBillingID := createdMainMeterBilling.ID
-
-	createdSubMeterBillingIDs := make(map[int32]int32)
 
-	for smID, smBilling := range subMeterBillings {
-		smBilling.FkMainBilling = createdMainMeterBillingID
-		createdSubMeterBilling, err := qtx.CreateSubMeterBilling(ctx, *smBilling)
+	statements := make([]pdf.SubMeterStatement, len(subMeterBillings))
+	for i, subMeterBilling := range subMeterBillings {
+		statements[i], err = s.newSubMeterStatement(
+			ctx, mainMeter, mainMeterBilling, subMeterBilling, costItems, issuer)
 		if err != nil {
-			slog.Error("error executing query", "err", err)
+			slog.Error("error creating sub meter statement", "err", err)
 			s.HandleInternalServerError(w, r, err)
 			return
 		}
-		createdSubMeterBillingIDs[smID] = createdSubMeterBilling.ID
 	}
 
-	for i, mmBillingPeriod := range mainMeterBillingPeriods {
-		mmBillingPeriod.FkMainBilling = createdMainMeterBillingID
-		createdMainMeterBillingPeriod, err := qtx.CreateMainMeterBillingPeriod(
-			ctx, *mmBillingPeriod)
+	// Archive is streamed, errors after the first file can only be logged.
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"billing-%d.zip\"", mainMeterBilling.Subid),
+	)
+	zipWriter := zip.NewWriter(w)
+	for i, subMeterBilling := range subMeterBillings {
+		fileWriter, err := zipWriter.Create(subMeterStatementFilename(
+			mainMeterBilling.Subid, subMeterBilling.SubMeterSubid))
 		if err != nil {
-			slog.Error("error executing query", "err", err)
-			s.HandleInternalServerError(w, r, err)
+			slog.Error("error creating zip file", "err", err)
 			return
 		}
-		createdMainMeterBillingPeriodID := createdMainMeterBillingPeriod.ID
-		smBillingPeriods := subMeterBillingPeriods[i]
-		for smID, smBillingPeriod := range smBillingPeriods {
-			smBillingPeriod.FkSubBilling = createdSubMeterBillingIDs[smID]
-			smBillingPeriod.FkMainBillingPeriod = createdMainMeterBillingPeriodID
-			_, err := qtx.CreateSubMeterBillingPeriod(ctx, *smBillingPeriod)
-			if err != nil {
-				slog.Error("error executing query", "err", err)
-				s.HandleInternalServerError(w, r, err)
-				return
-			}
+		if err := pdf.WriteSubMeterStatement(fileWriter, statements[i]); err != nil {
+			slog.Error("error writing pdf", "err", err)
+			return
 		}
 	}
+	if err := zipWriter.Close(); err != nil {
+		slog.Error("error closing zip", "err", err)
+	}
+}
+
+func (s *Server) HandleGetBankAccount(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "bankAccount"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	bankAccount, err := s.getBankAccount(ctx, userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	formData := BankAccountFormData{}
+	if bankAccount != nil {
+		formData.IBAN = bankAccount.Iban
+		formData.BIC = bankAccount.Bic.String
+	}
+	s.renderTemplate(w, r, tmplName, formData)
+}
+
+func (s *Server) HandlePostBankAccount(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "bankAccount"
+	formData := BankAccountFormData{}
+	var formError bool
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		formData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, formData)
+		return
+	}
+
+	iIBAN := r.PostFormValue("iban")
+	formData.IBAN = iIBAN
+	iban, err := parseIBAN(iIBAN)
+	if err != nil {
+		formData.IBANError = err.Error()
+		formError = true
+	}
+
+	iBIC := r.PostFormValue("bic")
+	formData.BIC = iBIC
+	bic, err := parseBIC(iBIC)
+	if err != nil {
+		formData.BICError = err.Error()
+		formError = true
+	}
+
+	if formError {
+		s.renderTemplate(w, r, tmplName, formData)
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	if _, err := s.queries.UpsertBankAccount(
+		ctx,
+		spinusdb.UpsertBankAccountParams{
+			FkUser: userID,
+			Iban:   string(iban),
+			Bic:    pgtype.Text{String: bic.String, Valid: bic.Valid},
+		},
+	); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/bank-account", http.StatusSeeOther)
+}
+
+func (s *Server) HandleGetLandlordProfile(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "landlordProfile"
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
+	landlordProfile, err := s.getLandlordProfile(ctx, userID)
+	if err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	formData := LandlordProfileFormData{NumberingPattern: defaultNumberingPattern}
+	if landlordProfile != nil {
+		formData.Name = landlordProfile.Name
+		formData.CompanyID = landlordProfile.CompanyID.String
+		formData.VATID = landlordProfile.VatID.String
+		formData.Address = landlordProfile.Address
+	}
+	numberingSeries, err := s.queries.GetNumberingSeries(ctx, userID)
+	if err == nil {
+		formData.NumberingPattern = numberingSeries.Pattern
+	} else if err != pgx.ErrNoRows {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+
+	s.renderTemplate(w, r, tmplName, formData)
+}
+
+func (s *Server) HandlePostLandlordProfile(w http.ResponseWriter, r *http.Request) {
+	const tmplName = "landlordProfile"
+	formData := LandlordProfileFormData{}
+	var formError bool
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form", "err", err)
+		formData.GeneralError = "Bad request"
+		s.renderTemplate(w, r, tmplName, formData)
+		return
+	}
+
+	iName := r.PostFormValue("name")
+	formData.Name = iName
+	name, err := parseLandlordName(iName)
+	if err != nil {
+		formData.NameError = err.Error()
+		formError = true
+	}
+
+	iCompanyID := r.PostFormValue("company-id")
+	formData.CompanyID = iCompanyID
+	companyID, err := parseCompanyID(iCompanyID)
+	if err != nil {
+		formData.CompanyIDError = err.Error()
+		formError = true
+	}
+
+	iVATID := r.PostFormValue("vat-id")
+	formData.VATID = iVATID
+	vatID, err := parseVATID(iVATID)
+	if err != nil {
+		formData.VATIDError = err.Error()
+		formError = true
+	}
+
+	iAddress := r.PostFormValue("address")
+	formData.Address = iAddress
+	address, err := parseAddress(iAddress)
+	if err != nil {
+		formData.AddressError = err.Error()
+		formError = true
+	}
+
+	iNumberingPattern := r.PostFormValue("numbering-pattern")
+	formData.NumberingPattern = iNumberingPattern
+	numberingPattern, err := parseNumberingPattern(iNumberingPattern)
+	if err != nil {
+		formData.NumberingPatternError = err.Error()
+		formError = true
+	}
+
+	if formError {
+		s.renderTemplate(w, r, tmplName, formData)
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := UserID(ctx)
+	if !ok {
+		slog.Error("error getting user ID", "userID", userID)
+		s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+		return
+	}
 
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		slog.Error("error beginning transaction", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	if _, err := qtx.UpsertLandlordProfile(
+		ctx,
+		spinusdb.UpsertLandlordProfileParams{
+			FkUser:    userID,
+			Name:      string(name),
+			CompanyID: pgtype.Text{String: companyID.String, Valid: companyID.Valid},
+			VatID:     pgtype.Text{String: vatID.String, Valid: vatID.Valid},
+			Address:   string(address),
+		},
+	); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
+	if _, err := qtx.UpsertNumberingSeries(
+		ctx,
+		spinusdb.UpsertNumberingSeriesParams{
+			FkUser: userID, Pattern: string(numberingPattern)},
+	); err != nil {
+		slog.Error("error executing query", "err", err)
+		s.HandleInternalServerError(w, r, err)
+		return
+	}
 	err = tx.Commit(ctx)
 	if err != nil {
 		slog.Error("error committing transaction", "err", err)
@@ -1496,5 +5046,5 @@ func (s *Server) HandlePostMainMeterBillingCreate(w http.ResponseWriter, r *http
 		return
 	}
 
-	s.renderTemplate(w, r, tmplName, tmplData)
+	http.Redirect(w, r, "/landlord-profile", http.StatusSeeOther)
 }
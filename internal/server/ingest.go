@@ -0,0 +1,321 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/dsmr"
+	"github.com/svoboond/spinus/internal/ingest"
+)
+
+const (
+	maxIngestBodySize  = 1 << 20
+	maxIngestBatchSize = 100
+	deviceTokenPrefix  = "spd_"
+)
+
+const (
+	IngestStatusCreated   = "created"
+	IngestStatusDuplicate = "duplicate"
+	IngestStatusRejected  = "rejected"
+)
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+type IngestReading struct {
+	Timestamp string      `json:"timestamp"`
+	Value     json.Number `json:"value"`
+}
+
+// IngestRequest is either a single reading or a batch of readings.
+type IngestRequest struct {
+	IngestReading
+	Readings []IngestReading `json:"readings"`
+}
+
+type IngestResult struct {
+	Timestamp string   `json:"timestamp"`
+	Status    string   `json:"status"`
+	Error     string   `json:"error,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+type IngestResponse struct {
+	Results []IngestResult `json:"results"`
+}
+
+func parseIngestRequest(r *http.Request) ([]IngestReading, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	var request IngestRequest
+	if err := decoder.Decode(&request); err != nil {
+		return nil, errors.New("Send valid JSON.")
+	}
+	readings := request.Readings
+	single := request.IngestReading
+	switch {
+	case len(readings) > 0 && (single.Timestamp != "" || single.Value != ""):
+		return nil, errors.New("Send either a single reading or readings.")
+	case len(readings) == 0 && single.Timestamp == "" && single.Value == "":
+		return nil, errors.New("Send a reading.")
+	case len(readings) == 0:
+		readings = []IngestReading{single}
+	case len(readings) > maxIngestBatchSize:
+		return nil, fmt.Errorf(
+			"Send no more than %d readings at once.", maxIngestBatchSize)
+	}
+	return readings, nil
+}
+
+func parseIngestTimestamp(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, errors.New("Enter valid timestamp.")
+	}
+	return t, nil
+}
+
+// newDSMRIngestReading reads value of the sub meter energy from DSMR
+// telegram, electricity delivered to the client or the connected gas or water
+// meter.
+func newDSMRIngestReading(data []byte, energy spinusdb.Energy) (IngestReading, error) {
+	var reading IngestReading
+	telegram, err := dsmr.Parse(data)
+	if err != nil {
+		return reading, fmt.Errorf("Send valid DSMR telegram, %s.", err)
+	}
+	var t time.Time
+	var value float64
+	switch energy {
+	case spinusdb.EnergyElectricity:
+		if telegram.Time.IsZero() {
+			return reading, errors.New("Send DSMR telegram with time.")
+		}
+		t, value = telegram.Time, telegram.Import()
+	case spinusdb.EnergyGas, spinusdb.EnergyWater:
+		deviceType := dsmr.DeviceTypeGas
+		if energy == spinusdb.EnergyWater {
+			deviceType = dsmr.DeviceTypeWater
+		}
+		mbusReading, ok := telegram.FindMBus(deviceType)
+		if !ok {
+			return reading, fmt.Errorf("Send DSMR telegram with %s meter reading.", energy)
+		}
+		t, value = mbusReading.Time, mbusReading.Value
+	case spinusdb.EnergyHeat:
+		return reading, errors.New("DSMR telegrams have no heat meter readings.")
+	default:
+		return reading, fmt.Errorf("unknown energy %q", energy)
+	}
+	reading.Timestamp = t.Format(time.RFC3339)
+	reading.Value = json.Number(strconv.FormatFloat(value, 'f', -1, 64))
+	return reading, nil
+}
+
+type ingestedReading struct {
+	index int
+	time  time.Time
+	value float64
+}
+
+// ingestReadings stores readings of a device, validated the same way as
+// readings entered in the form. Readings repeated with the same timestamp are
+// reported as duplicates, so devices can safely retry. Warnings cannot be
+// confirmed by devices, so readings with warnings are stored.
+func (s *Server) ingestReadings(
+	ctx context.Context, qtx *spinusdb.Queries, device spinusdb.Device,
+	readings []IngestReading,
+) ([]IngestResult, error) {
+	results := make([]IngestResult, len(readings))
+	var valid []ingestedReading
+	for i, reading := range readings {
+		result := &results[i]
+		result.Timestamp = reading.Timestamp
+		t, err := parseIngestTimestamp(reading.Timestamp)
+		if err != nil {
+			result.Status, result.Error = IngestStatusRejected, err.Error()
+			continue
+		}
+		value, err := parseReadingValue(reading.Value.String())
+		if err != nil {
+			result.Status, result.Error = IngestStatusRejected, err.Error()
+			continue
+		}
+		valid = append(valid, ingestedReading{index: i, time: t, value: float64(value)})
+	}
+	// Batches are processed in time order, so values are checked against
+	// readings sent before them.
+	slices.SortStableFunc(valid, func(a, b ingestedReading) int {
+		return a.time.Compare(b.time)
+	})
+
+	subMeterReadings, err := qtx.ListSubMeterReadings(ctx, device.FkSubMeter)
+	if err != nil {
+		return nil, fmt.Errorf("could not list sub meter readings: %w", err)
+	}
+	fkDevice := pgtype.Int4{Int32: device.ID, Valid: true}
+	for _, reading := range valid {
+		result := &results[reading.index]
+		readingTime := pgtype.Timestamptz{Time: reading.time, Valid: true}
+		_, err := qtx.GetDeviceReading(
+			ctx,
+			spinusdb.GetDeviceReadingParams{FkDevice: fkDevice, ReadingTime: readingTime},
+		)
+		if err == nil {
+			result.Status = IngestStatusDuplicate
+			continue
+		} else if err != pgx.ErrNoRows {
+			return nil, fmt.Errorf("could not get device reading: %w", err)
+		}
+
+		// Reading date is the date where the device is.
+		readingDate := pgtype.Date{
+			Time: time.Date(
+				reading.time.Year(), reading.time.Month(), reading.time.Day(),
+				0, 0, 0, 0, time.UTC),
+			Valid: true,
+		}
+		_, err = qtx.GetSubMeterReadingForDate(
+			ctx,
+			spinusdb.GetSubMeterReadingForDateParams{
+				FkSubMeter:  device.FkSubMeter,
+				ReadingDate: readingDate,
+			},
+		)
+		if err == nil {
+			result.Status = IngestStatusRejected
+			result.Error = "Reading for the given date already exists."
+			continue
+		} else if err != pgx.ErrNoRows {
+			return nil, fmt.Errorf("could not get sub meter reading: %w", err)
+		}
+		check := checkNewReading(subMeterReadings, reading.value, readingDate.Time)
+		if check.Error != "" {
+			result.Status, result.Error = IngestStatusRejected, check.Error
+			continue
+		}
+
+		// Retry of a batch still being stored is found only on insert.
+		subMeterReading, err := qtx.CreateDeviceReading(
+			ctx,
+			spinusdb.CreateDeviceReadingParams{
+				FkSubMeter:   device.FkSubMeter,
+				ReadingValue: reading.value,
+				ReadingDate:  readingDate,
+				Source:       spinusdb.ReadingSourceDevice,
+				FkDevice:     fkDevice,
+				ReadingTime:  readingTime,
+			},
+		)
+		if err == pgx.ErrNoRows {
+			result.Status = IngestStatusDuplicate
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not create sub meter reading: %w", err)
+		}
+		result.Status, result.Warnings = IngestStatusCreated, check.Warnings
+		subMeterReadings = insertSubMeterReading(subMeterReadings, subMeterReading)
+	}
+	return results, nil
+}
+
+// StoreDailyReading stores reading of sub meter with meter identification,
+// unless there already is a reading for the day. It is used for meters
+// publishing values continuously, like over MQTT or Modbus. Sub meter is
+// looked up only in the configured main meter, as meter identification is set
+// freely by users and other users' sub meters can have it too.
+func (s *Server) StoreDailyReading(
+	ctx context.Context, mainMeterID int32, meterID string, value float64, t time.Time,
+) (bool, error) {
+	subMeterIDs, err := s.queries.ListSubMeterIDsByMeterID(
+		ctx,
+		spinusdb.ListSubMeterIDsByMeterIDParams{
+			FkMainMeter: mainMeterID,
+			MeterID:     pgtype.Text{String: meterID, Valid: true},
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not list sub meters: %w", err)
+	}
+	switch len(subMeterIDs) {
+	case 0:
+		return false, fmt.Errorf("%w: unknown sub meter", ingest.ErrRejected)
+	case 1:
+	default:
+		return false, fmt.Errorf(
+			"%w: %d sub meters with the meter ID", ingest.ErrRejected, len(subMeterIDs))
+	}
+	subMeterID := subMeterIDs[0]
+	if value < 0 {
+		return false, fmt.Errorf("%w: negative value", ingest.ErrRejected)
+	}
+
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	readingDate := pgtype.Date{
+		Time: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), Valid: true}
+	_, err = qtx.GetSubMeterReadingForDate(
+		ctx,
+		spinusdb.GetSubMeterReadingForDateParams{
+			FkSubMeter:  subMeterID,
+			ReadingDate: readingDate,
+		},
+	)
+	if err == nil {
+		return false, nil
+	} else if err != pgx.ErrNoRows {
+		return false, fmt.Errorf("could not get sub meter reading: %w", err)
+	}
+	subMeterReadings, err := qtx.ListSubMeterReadings(ctx, subMeterID)
+	if err != nil {
+		return false, fmt.Errorf("could not list sub meter readings: %w", err)
+	}
+	check := checkNewReading(subMeterReadings, value, readingDate.Time)
+	if check.Error != "" {
+		return false, fmt.Errorf("%w: %s", ingest.ErrRejected, check.Error)
+	}
+	for _, warning := range check.Warnings {
+		slog.Warn(
+			"reading warning",
+			"mainMeterID", mainMeterID, "meterID", meterID, "warning", warning)
+	}
+	_, err = qtx.CreateSubMeterReading(
+		ctx,
+		spinusdb.CreateSubMeterReadingParams{
+			FkSubMeter:   subMeterID,
+			ReadingValue: value,
+			ReadingDate:  readingDate,
+			Source:       spinusdb.ReadingSourceDevice,
+			ReadingTime:  pgtype.Timestamptz{Time: t, Valid: true},
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not create sub meter reading: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Only first errors are shown, distributor exports have thousands of rows.
+	maxIntervalImportErrors = 20
+)
+
+var intervalLengths = []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour}
+
+var (
+	intervalTimeLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2.1.2006 15:04:05",
+		"2.1.2006 15:04",
+	}
+	intervalDateLayouts  = []string{"2006-01-02", "2.1.2006"}
+	intervalClockLayouts = []string{"15:04:05", "15:04"}
+)
+
+// completeDaySeconds returns seconds of intervals covering the day of local
+// wall clock time in the location. Day when daylight saving time starts has 23
+// hours. Day when it ends has 24 hours, as repeated intervals are summed.
+func completeDaySeconds(day time.Time, location *time.Location) int32 {
+	begin := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, location)
+	length := begin.AddDate(0, 0, 1).Sub(begin)
+	return int32(min(length, 24*time.Hour) / time.Second)
+}
+
+// IntervalImportOptions describe rows with a single time column.
+type IntervalImportOptions struct {
+	// Time marks interval end, as distributors usually export, or its begin.
+	TimeMarksEnd bool
+	// Value is average power in kW instead of energy consumed in the interval.
+	AveragePower bool
+	Length       time.Duration
+}
+
+type intervalReading struct {
+	line        int
+	begin       time.Time
+	end         time.Time
+	consumption float64
+}
+
+type IntervalImportError struct {
+	Line  int
+	Error string
+}
+
+// IntervalImport is a parsed distributor export with summary for preview.
+type IntervalImport struct {
+	Errors      []IntervalImportError
+	ErrorCount  int
+	MergedCount int
+	BeginTime   time.Time
+	EndTime     time.Time
+	DayCount    int
+	Consumption float64
+
+	intervals []intervalReading
+}
+
+func (i *IntervalImport) IntervalCount() int { return len(i.intervals) }
+
+func (i *IntervalImport) addError(line int, err string) {
+	i.ErrorCount++
+	if len(i.Errors) < maxIntervalImportErrors {
+		i.Errors = append(i.Errors, IntervalImportError{Line: line, Error: err})
+	}
+}
+
+// parseIntervalImportCSV parses interval readings exported by distributors or
+// smart meters. Rows have either begin time, end time and value, or a single
+// time and value, and times may be split to date and clock columns. Header
+// lines are skipped and semicolon is accepted as separator, with decimal
+// comma.
+//
+// Times are kept as local wall clock time in UTC, so that days match dates of
+// readings and billing periods. Intervals repeated when daylight saving time
+// ends are therefore summed.
+func parseIntervalImportCSV(s string, options IntervalImportOptions) (*IntervalImport, error) {
+	csvReader := csv.NewReader(strings.NewReader(s))
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	firstLine, _, _ := strings.Cut(s, "\n")
+	if strings.Contains(firstLine, ";") {
+		csvReader.Comma = ';'
+	}
+
+	imp := &IntervalImport{}
+	intervals := make(map[time.Time]*intervalReading)
+	var header = true
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read intervals: %w", err)
+		}
+		line, _ := csvReader.FieldPos(0)
+		interval, err := parseIntervalRecord(record, options)
+		if err != nil {
+			if !header {
+				imp.addError(line, err.Error())
+			}
+			continue
+		}
+		header = false
+		interval.line = line
+		if previous, ok := intervals[interval.begin]; ok {
+			if !previous.end.Equal(interval.end) {
+				imp.addError(line, fmt.Sprintf(
+					"Interval overlaps interval on line %d.", previous.line))
+				continue
+			}
+			previous.consumption += interval.consumption
+			imp.MergedCount++
+			continue
+		}
+		intervals[interval.begin] = &interval
+	}
+	if len(intervals) == 0 && imp.ErrorCount == 0 {
+		return nil, errors.New("no intervals")
+	}
+
+	imp.intervals = make([]intervalReading, 0, len(intervals))
+	for _, interval := range intervals {
+		imp.intervals = append(imp.intervals, *interval)
+	}
+	slices.SortFunc(imp.intervals, func(a, b intervalReading) int {
+		return a.begin.Compare(b.begin)
+	})
+	days := make(map[time.Time]bool)
+	for i, interval := range imp.intervals {
+		if i > 0 && interval.begin.Before(imp.intervals[i-1].end) {
+			imp.addError(interval.line, fmt.Sprintf(
+				"Interval overlaps interval on line %d.", imp.intervals[i-1].line))
+		}
+		days[interval.begin.Truncate(24*time.Hour)] = true
+		imp.Consumption += interval.consumption
+	}
+	if len(imp.intervals) > 0 {
+		imp.BeginTime = imp.intervals[0].begin
+		imp.EndTime = imp.intervals[len(imp.intervals)-1].end
+	}
+	imp.DayCount = len(days)
+	return imp, nil
+}
+
+func parseIntervalRecord(
+	record []string, options IntervalImportOptions,
+) (intervalReading, error) {
+	var interval intervalReading
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+	if len(record) < 2 {
+		return interval, errors.New("Enter interval time and value.")
+	}
+
+	var t time.Time
+	var iValue string
+	if begin, ok := parseIntervalTime(record[0]); ok {
+		if end, ok := parseIntervalTime(record[1]); ok && len(record) >= 3 {
+			interval.begin, interval.end = begin, end
+			iValue = record[2]
+		} else {
+			t, iValue = begin, record[1]
+		}
+	} else if begin, ok := parseIntervalDateClock(record[0], record[1]); ok &&
+		len(record) >= 3 {
+
+		if end, ok := parseIntervalDateClock(record[0], record[2]); ok &&
+			len(record) >= 4 {
+
+			// Interval ending at midnight ends on the next day.
+			if !end.After(begin) {
+				end = end.AddDate(0, 0, 1)
+			}
+			interval.begin, interval.end = begin, end
+			iValue = record[3]
+		} else {
+			t, iValue = begin, record[2]
+		}
+	} else {
+		return interval, errors.New("Enter valid interval time.")
+	}
+	if !t.IsZero() {
+		if options.TimeMarksEnd {
+			interval.begin, interval.end = t.Add(-options.Length), t
+		} else {
+			interval.begin, interval.end = t, t.Add(options.Length)
+		}
+	}
+
+	length := interval.end.Sub(interval.begin)
+	if !slices.Contains(intervalLengths, length) {
+		return interval, errors.New("Interval must be 15, 30 or 60 minutes long.")
+	}
+	if !interval.begin.Truncate(length).Equal(interval.begin) {
+		return interval, errors.New("Interval must begin at a multiple of its length.")
+	}
+
+	if !strings.Contains(iValue, ".") {
+		iValue = strings.Replace(iValue, ",", ".", 1)
+	}
+	value, err := strconv.ParseFloat(iValue, 64)
+	if err != nil || value < 0 {
+		return interval, errors.New("Enter valid interval value.")
+	}
+	if options.AveragePower {
+		value *= length.Hours()
+	}
+	interval.consumption = value
+	return interval, nil
+}
+
+// parseIntervalTime parses time and returns its wall clock time in UTC. Time
+// 24:00 is the midnight at the end of the day.
+func parseIntervalTime(s string) (time.Time, bool) {
+	s, endOfDay := cutEndOfDay(s)
+	for _, layout := range intervalTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return wallClockUTC(t, endOfDay), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseIntervalDateClock(date, clock string) (time.Time, bool) {
+	clock, endOfDay := cutEndOfDay(clock)
+	for _, dateLayout := range intervalDateLayouts {
+		for _, clockLayout := range intervalClockLayouts {
+			t, err := time.Parse(dateLayout+" "+clockLayout, date+" "+clock)
+			if err == nil {
+				return wallClockUTC(t, endOfDay), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// cutEndOfDay replaces clock 24:00 with 00:00 and reports whether it did.
+func cutEndOfDay(s string) (string, bool) {
+	i := strings.LastIndexAny(s, " T") + 1
+	switch s[i:] {
+	case "24:00":
+		return s[:i] + "00:00", true
+	case "24:00:00":
+		return s[:i] + "00:00:00", true
+	}
+	return s, false
+}
+
+func wallClockUTC(t time.Time, endOfDay bool) time.Time {
+	t = time.Date(
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/isdoc"
+)
+
+// Units of consumption which can be turned into main meter readings,
+// with multipliers converting them to units of the readings.
+var readingUnits = map[spinusdb.Energy]map[string]float64{
+	spinusdb.EnergyElectricity: {"kwh": 1, "mwh": 1000},
+	spinusdb.EnergyGas:         {"m3": 1, "mtq": 1},
+	spinusdb.EnergyWater:       {"m3": 1, "mtq": 1},
+	spinusdb.EnergyHeat:        {"kwh": 1, "mwh": 1000, "gj": 1e9 / 3.6e6},
+}
+
+// Invoice lines in these units are priced by consumption, other lines are
+// services.
+var consumptionUnits = []string{"kwh", "mwh", "gj", "m3", "mtq"}
+
+type invoicePeriod struct {
+	isdoc.Period
+	ConsumedEnergyPrice float64
+	ServicePrice        float64
+	HasServicePrice     bool
+	Consumptions        []float64
+	UnmappedConsumption bool
+}
+
+// Consumption is known only if all lines of the period agree on it, lines
+// of one period usually repeat consumption for energy and distribution.
+func (ip *invoicePeriod) Consumption() (float64, bool) {
+	if ip.UnmappedConsumption || len(ip.Consumptions) == 0 {
+		return 0, false
+	}
+	for _, c := range ip.Consumptions[1:] {
+		if math.Abs(c-ip.Consumptions[0]) > 0.0005 {
+			return 0, false
+		}
+	}
+	return ip.Consumptions[0], true
+}
+
+// Days include both begin and end date.
+func (ip *invoicePeriod) Days() float64 {
+	return ip.End.Sub(ip.Begin).Hours()/24 + 1
+}
+
+func getInvoicePeriod(periods []*invoicePeriod, p isdoc.Period) *invoicePeriod {
+	i := slices.IndexFunc(
+		periods, func(period *invoicePeriod) bool { return period.Period == p })
+	return periods[i]
+}
+
+func invoiceLineName(line isdoc.Line) string {
+	if line.Description == "" {
+		return fmt.Sprintf("Line %s", line.ID)
+	}
+	return fmt.Sprintf("Line %s (%s)", line.ID, line.Description)
+}
+
+// Last billing period of the main meter is used for begin reading value,
+// it is nil if there is none.
+func newMainMeterBillingFormDataFromInvoice(
+	invoice *isdoc.Invoice,
+	energy spinusdb.Energy,
+	lastBillingPeriod *spinusdb.MainMeterBillingPeriod,
+) (MainMeterBillingFormData, []string) {
+	formData := NewMainMeterBillingFormData()
+	var warnings []string
+
+	var periods []*invoicePeriod
+	for _, line := range invoice.Lines {
+		if p, ok := line.Period(); ok && !slices.ContainsFunc(
+			periods, func(period *invoicePeriod) bool { return period.Period == p }) {
+			periods = append(periods, &invoicePeriod{Period: p})
+		}
+	}
+	// Without periods stated by lines the invoice period is used.
+	if len(periods) == 0 {
+		if p, ok := invoice.Period(); ok {
+			periods = append(periods, &invoicePeriod{Period: p})
+		}
+	}
+
+	for _, line := range invoice.Lines {
+		price := line.Price()
+		if price == 0 {
+			continue
+		}
+		if price < 0 {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s has negative price %s, it is not imported.",
+				invoiceLineName(line), formatPrice(price)))
+			continue
+		}
+		if len(periods) == 0 {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s has no billing period, it is not imported.", invoiceLineName(line)))
+			continue
+		}
+		// Lines without period are split among all periods by their days.
+		linePeriods := periods
+		if p, ok := line.Period(); ok {
+			linePeriods = []*invoicePeriod{getInvoicePeriod(periods, p)}
+		}
+		var days float64
+		for _, period := range linePeriods {
+			days += period.Days()
+		}
+
+		var unit string
+		if line.Quantity != nil {
+			unit = line.Quantity.Unit()
+		}
+		isConsumption := slices.Contains(consumptionUnits, unit)
+		multiplier, isReading := readingUnits[energy][unit]
+		if isConsumption && !isReading {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s consumption %s does not match %s readings.",
+				invoiceLineName(line), line.Quantity, energy))
+		}
+		for _, period := range linePeriods {
+			periodPrice := price * period.Days() / days
+			switch {
+			case !isConsumption:
+				period.ServicePrice += periodPrice
+				period.HasServicePrice = true
+			case isReading && len(linePeriods) == 1:
+				period.ConsumedEnergyPrice += periodPrice
+				period.Consumptions = append(
+					period.Consumptions, line.Quantity.Value*multiplier)
+			default:
+				period.ConsumedEnergyPrice += periodPrice
+				period.UnmappedConsumption = true
+			}
+		}
+	}
+
+	if len(periods) == 0 {
+		formData.GeneralError = "No billing period found in invoice."
+		return formData, warnings
+	}
+	slices.SortFunc(periods, func(a, b *invoicePeriod) int {
+		return a.Begin.Compare(b.Begin)
+	})
+
+	var readingValue float64
+	var hasReadingValue bool
+	if lastBillingPeriod != nil {
+		if lastBillingPeriod.EndDate.Time.AddDate(0, 0, 1).Equal(periods[0].Begin) {
+			readingValue, hasReadingValue = lastBillingPeriod.EndReadingValue, true
+		}
+	}
+	if !hasReadingValue {
+		warnings = append(warnings, fmt.Sprintf(
+			"Begin reading value is not known, no billing ends on %s.",
+			periods[0].Begin.AddDate(0, 0, -1).Format("2006-01-02")))
+	}
+
+	formData.BillingPeriods = nil
+	for _, period := range periods {
+		periodForm := &MainMeterBillingPeriodFormData{
+			BeginDate:           period.Begin.Format("2006-01-02"),
+			EndDate:             period.End.Format("2006-01-02"),
+			ConsumedEnergyPrice: formatPrice(period.ConsumedEnergyPrice),
+		}
+		if period.HasServicePrice {
+			periodForm.ServicePrice = formatPrice(period.ServicePrice)
+		}
+		consumption, hasConsumption := period.Consumption()
+		if !hasConsumption {
+			warnings = append(warnings, fmt.Sprintf(
+				"Consumption of billing period %s - %s is not known.",
+				periodForm.BeginDate, periodForm.EndDate))
+		}
+		if hasReadingValue {
+			periodForm.BeginReadingValue = formatConsumption(readingValue)
+			if hasConsumption {
+				readingValue += consumption
+				periodForm.EndReadingValue = formatConsumption(readingValue)
+			} else {
+				hasReadingValue = false
+			}
+		}
+		formData.BillingPeriods = append(formData.BillingPeriods, periodForm)
+	}
+	return formData, warnings
+}
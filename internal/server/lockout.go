@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Failed attempts are counted per client IP and per username in Redis, so the
+// limits hold across server instances. Once a key reaches its limit, it is
+// locked out and each further failure doubles the lockout.
+const (
+	failureWindow            = 24 * time.Hour
+	minLockout               = time.Minute
+	maxLockout               = time.Hour
+	maxIPLogInFailures       = 20
+	maxUsernameLogInFailures = 5
+	maxIPSignUps             = 10
+)
+
+type lockoutKey struct {
+	name  string
+	limit int64
+}
+
+func logInIPKey(ip string) lockoutKey {
+	return lockoutKey{name: "login:ip:" + ip, limit: maxIPLogInFailures}
+}
+
+// logInUsernameKey counts also usernames without account, so lockout does not
+// reveal whether the account exists.
+func logInUsernameKey(username Username) lockoutKey {
+	return lockoutKey{name: "login:username:" + string(username), limit: maxUsernameLogInFailures}
+}
+
+// signUpIPKey counts every sign up as a failure, so lockout does not reveal
+// whether the email was registered.
+func signUpIPKey(ip string) lockoutKey {
+	return lockoutKey{name: "signup:ip:" + ip, limit: maxIPSignUps}
+}
+
+// clientIP is the address set by the WithRealIP middleware, without port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// getLockout returns the longest remaining lockout of the keys, zero if none
+// is locked out.
+func (s *Server) getLockout(ctx context.Context, keys ...lockoutKey) (time.Duration, error) {
+	var lockout time.Duration
+	for _, key := range keys {
+		ttl, err := s.redisClient.PTTL(ctx, "lockout:"+key.name).Result()
+		if err != nil {
+			return 0, fmt.Errorf("could not get lockout: %w", err)
+		}
+		lockout = max(lockout, ttl)
+	}
+	return lockout, nil
+}
+
+func (s *Server) recordFailure(ctx context.Context, keys ...lockoutKey) error {
+	for _, key := range keys {
+		failuresKey := "failures:" + key.name
+		var incr *redis.IntCmd
+		_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			incr = pipe.Incr(ctx, failuresKey)
+			pipe.Expire(ctx, failuresKey, failureWindow)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not count failure: %w", err)
+		}
+		failures := incr.Val()
+		if failures < key.limit {
+			continue
+		}
+		lockout := min(minLockout<<min(failures-key.limit, 16), maxLockout)
+		err = s.redisClient.Set(ctx, "lockout:"+key.name, failures, lockout).Err()
+		if err != nil {
+			return fmt.Errorf("could not lock out: %w", err)
+		}
+		slog.Warn("locked out", "key", key.name, "failures", failures, "lockout", lockout)
+	}
+	return nil
+}
+
+func (s *Server) resetFailures(ctx context.Context, keys ...lockoutKey) error {
+	for _, key := range keys {
+		err := s.redisClient.Del(ctx, "failures:"+key.name, "lockout:"+key.name).Err()
+		if err != nil {
+			return fmt.Errorf("could not reset failures: %w", err)
+		}
+	}
+	return nil
+}
+
+func lockoutError(lockout time.Duration) string {
+	return fmt.Sprintf(
+		"Too many failed attempts, try again in %d minutes.",
+		int(math.Ceil(lockout.Minutes())))
+}
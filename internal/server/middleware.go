@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
@@ -23,6 +26,80 @@ func WithCacheControl(h http.Handler, maxAge int) http.Handler {
 	})
 }
 
+// WithRealIP replaces remote address of requests from trusted proxies with the
+// client address they forwarded. X-Forwarded-For is read from the right, as
+// addresses on the left are sent by the client and can be forged.
+func WithRealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		for _, prefix := range trustedProxies {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			remoteAddr, err := netip.ParseAddrPort(r.RemoteAddr)
+			if err != nil || !trusted(remoteAddr.Addr()) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			var forwarded []string
+			for _, header := range r.Header.Values("X-Forwarded-For") {
+				forwarded = append(forwarded, strings.Split(header, ",")...)
+			}
+			if len(forwarded) == 0 {
+				forwarded = r.Header.Values("X-Real-IP")
+			}
+			for i := len(forwarded) - 1; i >= 0; i-- {
+				addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+				if err != nil {
+					break
+				}
+				r.RemoteAddr = netip.AddrPortFrom(addr, 0).String()
+				if !trusted(addr) {
+					break
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithWriteTimeout replaces write timeout of the server for responses taking
+// long to generate.
+func WithWriteTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+			if err != nil {
+				slog.Error("error setting write deadline", "err", err)
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithReadTimeout replaces read timeout of the server for large request bodies.
+// Write timeout is replaced as well, as it is counted from the end of reading
+// request headers.
+func WithReadTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			responseController := http.NewResponseController(w)
+			deadline := time.Now().Add(timeout)
+			if err := responseController.SetReadDeadline(deadline); err != nil {
+				slog.Error("error setting read deadline", "err", err)
+			}
+			if err := responseController.SetWriteDeadline(deadline); err != nil {
+				slog.Error("error setting write deadline", "err", err)
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
 const userIDKey = "userID"
 const emptyUserIDValue int32 = 0
 
@@ -59,6 +136,49 @@ func (s *Server) WithRequiredLogin(h http.Handler) http.Handler {
 	})
 }
 
+// WithRequiredAPILogin responds to requests of JSON APIs without login with
+// an error instead of redirecting them to the login page.
+func (s *Server) WithRequiredAPILogin(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := UserID(r.Context())
+		if !ok {
+			slog.Error("error getting user ID", "userID", userID)
+			writeInternalError(w)
+			return
+		}
+		if userID == emptyUserIDValue {
+			writeJSON(w, http.StatusUnauthorized, APIErrorResponse{Error: "Log in."})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// WithVerifiedEmail lets only users with verified email issue billings and
+// statements, which are sent to tenants.
+func (s *Server) WithVerifiedEmail(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID, ok := UserID(ctx)
+		if !ok {
+			slog.Error("error getting user ID", "userID", userID)
+			s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+			return
+		}
+		user, err := s.queries.GetUserByID(ctx, userID)
+		if err != nil {
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		if !user.EmailVerified {
+			http.Redirect(w, r, "/email/verification", http.StatusSeeOther)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 const mainMeterKey = "mainMeter"
 
 func GetMainMeter(ctx context.Context) (spinusdb.GetMainMeterRow, bool) {
@@ -103,6 +223,10 @@ func (s *Server) WithMainMeter(h http.Handler) http.Handler {
 
 const subMeterKey = "subMeter"
 
+func canAccessSubMeter(userID int32, subMeter spinusdb.GetSubMeterRow) bool {
+	return userID == subMeter.SubUserID && userID == subMeter.MainUserID
+}
+
 func GetSubMeter(ctx context.Context) (spinusdb.GetSubMeterRow, bool) {
 	subMeter, ok := ctx.Value(subMeterKey).(spinusdb.GetSubMeterRow)
 	return subMeter, ok
@@ -142,7 +266,7 @@ func (s *Server) WithSubMeter(h http.Handler) http.Handler {
 			s.HandleInternalServerError(w, r, err)
 			return
 		}
-		if userID != subMeter.SubUserID || userID != subMeter.MainUserID {
+		if !canAccessSubMeter(userID, subMeter) {
 			s.HandleForbidden(w, r)
 			return
 		}
@@ -151,3 +275,170 @@ func (s *Server) WithSubMeter(h http.Handler) http.Handler {
 		h.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+const subMeterReadingKey = "subMeterReading"
+
+func GetSubMeterReading(ctx context.Context) (spinusdb.SubMeterReading, bool) {
+	subMeterReading, ok := ctx.Value(subMeterReadingKey).(spinusdb.SubMeterReading)
+	return subMeterReading, ok
+}
+
+// WithSubMeterReading has to be used after WithSubMeter.
+func (s *Server) WithSubMeterReading(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "readingID"), 10, 32)
+		if err != nil {
+			s.HandleNotFound(w, r)
+			return
+		}
+		readingID := int32(id)
+		ctx := r.Context()
+		subMeter, ok := GetSubMeter(ctx)
+		if !ok {
+			slog.Error("error getting sub meter", "subMeter", subMeter)
+			s.HandleInternalServerError(w, r, errors.New("error getting sub meter"))
+			return
+		}
+		subMeterReading, err := s.queries.GetSubMeterReading(
+			ctx,
+			spinusdb.GetSubMeterReadingParams{FkSubMeter: subMeter.ID, Subid: readingID},
+		)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				s.HandleNotFound(w, r)
+				return
+			}
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		ctx = context.WithValue(ctx, subMeterReadingKey, subMeterReading)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+const mainMeterBillingKey = "mainMeterBilling"
+
+func GetMainMeterBilling(ctx context.Context) (spinusdb.GetMainMeterBillingRow, bool) {
+	mainMeterBilling, ok := ctx.Value(mainMeterBillingKey).(spinusdb.GetMainMeterBillingRow)
+	return mainMeterBilling, ok
+}
+
+func (s *Server) WithMainMeterBilling(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "mainMeterID"), 10, 32)
+		if err != nil {
+			s.HandleNotFound(w, r)
+			return
+		}
+		mainMeterID := int32(id)
+		id, err = strconv.ParseInt(chi.URLParam(r, "billingID"), 10, 32)
+		if err != nil {
+			s.HandleNotFound(w, r)
+			return
+		}
+		billingID := int32(id)
+		ctx := r.Context()
+		userID, ok := UserID(ctx)
+		if !ok {
+			slog.Error("error getting user ID", "userID", userID)
+			s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+			return
+		}
+		mainMeterBilling, err := s.queries.GetMainMeterBilling(
+			ctx,
+			spinusdb.GetMainMeterBillingParams{FkMainMeter: mainMeterID, Subid: billingID},
+		)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				s.HandleNotFound(w, r)
+				return
+			}
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		if userID != mainMeterBilling.MainUserID {
+			s.HandleForbidden(w, r)
+			return
+		}
+		ctx = context.WithValue(ctx, userIDKey, userID)
+		ctx = context.WithValue(ctx, mainMeterBillingKey, mainMeterBilling)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+const statementKey = "statement"
+
+func GetStatement(ctx context.Context) (spinusdb.GetStatementRow, bool) {
+	statement, ok := ctx.Value(statementKey).(spinusdb.GetStatementRow)
+	return statement, ok
+}
+
+func (s *Server) WithStatement(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "statementID"), 10, 32)
+		if err != nil {
+			s.HandleNotFound(w, r)
+			return
+		}
+		statementID := int32(id)
+		ctx := r.Context()
+		userID, ok := UserID(ctx)
+		if !ok {
+			slog.Error("error getting user ID", "userID", userID)
+			s.HandleInternalServerError(w, r, errors.New("error getting user ID"))
+			return
+		}
+		statement, err := s.queries.GetStatement(
+			ctx, spinusdb.GetStatementParams{FkUser: userID, Subid: statementID})
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				s.HandleNotFound(w, r)
+				return
+			}
+			slog.Error("error executing query", "err", err)
+			s.HandleInternalServerError(w, r, err)
+			return
+		}
+		ctx = context.WithValue(ctx, userIDKey, userID)
+		ctx = context.WithValue(ctx, statementKey, statement)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+const deviceKey = "device"
+
+func GetDevice(ctx context.Context) (spinusdb.Device, bool) {
+	device, ok := ctx.Value(deviceKey).(spinusdb.Device)
+	return device, ok
+}
+
+// WithDeviceToken authenticates devices by bearer token instead of session.
+func (s *Server) WithDeviceToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(
+				w, http.StatusUnauthorized, APIErrorResponse{Error: "Send device token."})
+			return
+		}
+		ctx := r.Context()
+		device, err := s.queries.GetDeviceByTokenHash(ctx, hashToken(token))
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				writeJSON(
+					w, http.StatusUnauthorized,
+					APIErrorResponse{Error: "Send valid device token."})
+				return
+			}
+			slog.Error("error executing query", "err", err)
+			writeInternalError(w)
+			return
+		}
+		ctx = context.WithValue(ctx, deviceKey, device)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithRealIP(t *testing.T) {
+	trustedProxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		want         string
+	}{
+		{"no proxy", "198.51.100.7:1234", nil, "", "198.51.100.7"},
+		{
+			"forged by client", "198.51.100.7:1234", []string{"203.0.113.1"}, "203.0.113.2",
+			"198.51.100.7",
+		},
+		{"trusted proxy", "10.1.2.3:1234", []string{"203.0.113.1"}, "", "203.0.113.1"},
+		{"forged behind proxy", "10.1.2.3:1234", []string{"203.0.113.9, 198.51.100.7"}, "", "198.51.100.7"},
+		{"proxy chain", "10.1.2.3:1234", []string{"198.51.100.7", "192.0.2.1"}, "", "198.51.100.7"},
+		{"real IP header", "192.0.2.1:1234", nil, "198.51.100.7", "198.51.100.7"},
+		{"invalid header", "10.1.2.3:1234", []string{"unknown"}, "", "10.1.2.3"},
+		{"only proxies", "10.1.2.3:1234", []string{"10.4.5.6"}, "", "10.4.5.6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ip string
+			h := WithRealIP(trustedProxies)(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) { ip = clientIP(r) }))
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if ip != tt.want {
+				t.Errorf("clientIP() = %s, want %s", ip, tt.want)
+			}
+		})
+	}
+}
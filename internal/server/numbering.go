@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+)
+
+const defaultNumberingPattern = "{year}-{seq:04}"
+
+// Placeholders are {year}, {seq} and {seq:0N} with sequence padded to N digits.
+var numberingPlaceholderRe = regexp.MustCompile(`\{(year|seq)(?::0([1-9]))?\}`)
+
+func formatNumber(pattern string, year, seq int32) string {
+	return numberingPlaceholderRe.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		match := numberingPlaceholderRe.FindStringSubmatch(placeholder)
+		switch {
+		case match[1] == "year" && match[2] == "":
+			return fmt.Sprintf("%d", year)
+		case match[1] == "seq" && match[2] != "":
+			return fmt.Sprintf("%0"+match[2]+"d", seq)
+		case match[1] == "seq":
+			return fmt.Sprintf("%d", seq)
+		default:
+			return placeholder
+		}
+	})
+}
+
+// allocateNumber returns the next number of the user's series. Numbers issued
+// before are skipped, as they can be entered manually or repeated by a changed
+// pattern.
+func allocateNumber(
+	ctx context.Context, qtx *spinusdb.Queries, userID, year int32,
+) (string, error) {
+	pattern := defaultNumberingPattern
+	numberingSeries, err := qtx.GetNumberingSeries(ctx, userID)
+	if err == nil {
+		pattern = numberingSeries.Pattern
+	} else if err != pgx.ErrNoRows {
+		return "", fmt.Errorf("could not get numbering series: %w", err)
+	}
+	// Sequence restarts every year only if the pattern contains year.
+	seqYear := year
+	if !strings.Contains(pattern, "{year}") {
+		seqYear = 0
+	}
+	for {
+		seq, err := qtx.AllocateNumber(
+			ctx, spinusdb.AllocateNumberParams{FkUser: userID, SeqYear: seqYear})
+		if err != nil {
+			return "", fmt.Errorf("could not allocate number: %w", err)
+		}
+		number := formatNumber(pattern, year, seq)
+		issued, err := qtx.IssueNumber(
+			ctx, spinusdb.IssueNumberParams{FkUser: userID, Number: number})
+		if err != nil {
+			return "", fmt.Errorf("could not issue number: %w", err)
+		}
+		if issued == 1 {
+			return number, nil
+		}
+	}
+}
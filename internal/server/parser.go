@@ -1,8 +1,10 @@
 package server
 
 import (
+	"encoding/hex"
 	"errors"
 	"net/mail"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -63,6 +65,37 @@ func parsePassword(s string) (Password, error) {
 	}
 }
 
+type TwoFactorCode string
+
+var twoFactorCodeReplacer = strings.NewReplacer(" ", "", "-", "")
+
+// Recovery codes are shown with a hyphen, which can be left out.
+func parseTwoFactorCode(s string) (TwoFactorCode, error) {
+	v := TwoFactorCode(strings.ToLower(twoFactorCodeReplacer.Replace(s)))
+	switch {
+	case v == "":
+		return v, errors.New("Enter code.")
+	case len(v) > 32:
+		return v, errors.New("Enter valid code.")
+	default:
+		return v, nil
+	}
+}
+
+type PasskeyName string
+
+func parsePasskeyName(s string) (PasskeyName, error) {
+	v := PasskeyName(strings.TrimSpace(s))
+	switch {
+	case v == "":
+		return v, errors.New("Enter passkey name.")
+	case len(v) > 64:
+		return v, errors.New("Enter passkey name with maximum of 64 characters.")
+	default:
+		return v, nil
+	}
+}
+
 type MainMeterID string
 
 func parseMainMeterID(s string) (MainMeterID, error) {
@@ -198,3 +231,306 @@ func parseServicePrice(s string) (ServicePrice, error) {
 	}
 	return ServicePrice{Float64: p, Valid: true}, nil
 }
+
+type FloorArea struct {
+	Float64 float64
+	Valid   bool
+}
+
+func parseFloorArea(s string) (FloorArea, error) {
+	var v FloorArea
+	if s == "" {
+		return v, nil
+	}
+	p, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return v, errors.New("Enter valid floor area.")
+	}
+	if p < 0.0 {
+		return v, errors.New("Enter floor area that is no less than 0.")
+	}
+	return FloorArea{Float64: p, Valid: true}, nil
+}
+
+type Occupants struct {
+	Int32 int32
+	Valid bool
+}
+
+func parseOccupants(s string) (Occupants, error) {
+	var v Occupants
+	if s == "" {
+		return v, nil
+	}
+	p, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return v, errors.New("Enter valid number of occupants.")
+	}
+	if p < 0 {
+		return v, errors.New("Enter number of occupants that is no less than 0.")
+	}
+	return Occupants{Int32: int32(p), Valid: true}, nil
+}
+
+type CostShare struct {
+	Float64 float64
+	Valid   bool
+}
+
+func parseCostShare(s string) (CostShare, error) {
+	var v CostShare
+	if s == "" {
+		return v, nil
+	}
+	p, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return v, errors.New("Enter valid cost share.")
+	}
+	if p < 0.0 {
+		return v, errors.New("Enter cost share that is no less than 0.")
+	}
+	return CostShare{Float64: p, Valid: true}, nil
+}
+
+type CostItemName string
+
+func parseCostItemName(s string) (CostItemName, error) {
+	v := CostItemName(strings.TrimSpace(s))
+	vLen := len(v)
+	switch {
+	case v == "":
+		return v, errors.New("Enter cost item name.")
+	case vLen > 128:
+		return v, errors.New("Enter cost item name with maximum of 128 characters.")
+	default:
+		return v, nil
+	}
+}
+
+type DeviceName string
+
+func parseDeviceName(s string) (DeviceName, error) {
+	v := DeviceName(strings.TrimSpace(s))
+	vLen := len(v)
+	switch {
+	case v == "":
+		return v, errors.New("Enter device name.")
+	case vLen > 64:
+		return v, errors.New("Enter device name with maximum of 64 characters.")
+	default:
+		return v, nil
+	}
+}
+
+type WmbusKey []byte
+
+// Keys are usually written as 32 hexadecimal digits, sometimes in groups.
+func parseWmbusKey(s string) (WmbusKey, error) {
+	s = strings.Join(strings.Fields(s), "")
+	v, err := hex.DecodeString(s)
+	if err != nil || len(v) != 16 {
+		return nil, errors.New("Enter key of 32 hexadecimal digits.")
+	}
+	return WmbusKey(v), nil
+}
+
+func parseAllocationKey(s string) (spinusdb.AllocationKey, error) {
+	v := spinusdb.AllocationKey(s)
+	if !v.Valid() {
+		return v, errors.New("Enter valid allocation key.")
+	}
+	return v, nil
+}
+
+type CostItemPrice float64
+
+func parseCostItemPrice(s string) (CostItemPrice, error) {
+	var v CostItemPrice
+	p, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return v, errors.New("Enter valid cost item price.")
+	}
+	if p < 0.0 {
+		return v, errors.New("Enter cost item price that is no less than 0.")
+	}
+	return CostItemPrice(p), nil
+}
+
+type TenantID int32
+
+func parseTenantID(s string) (TenantID, error) {
+	var v TenantID
+	if s == "" {
+		return v, errors.New("Select tenant.")
+	}
+	p, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return v, errors.New("Select valid tenant.")
+	}
+	return TenantID(p), nil
+}
+
+type IBAN string
+
+func parseIBAN(s string) (IBAN, error) {
+	v := IBAN(strings.ToUpper(strings.ReplaceAll(s, " ", "")))
+	vLen := len(v)
+	switch {
+	case v == "":
+		return v, errors.New("Enter IBAN.")
+	case vLen < 15 || vLen > 34:
+		return v, errors.New("Enter valid IBAN.")
+	}
+	// Check digits are valid if the rearranged IBAN taken as a number
+	// gives remainder 1 when divided by 97.
+	var remainder int
+	for _, c := range v[4:] + v[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		default:
+			return v, errors.New("Enter valid IBAN.")
+		}
+	}
+	if remainder != 1 {
+		return v, errors.New("Enter valid IBAN.")
+	}
+	return v, nil
+}
+
+type BIC struct {
+	String string
+	Valid  bool
+}
+
+func parseBIC(s string) (BIC, error) {
+	var v BIC
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return v, nil
+	}
+	if len(s) != 8 && len(s) != 11 {
+		return v, errors.New("Enter valid BIC.")
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z') {
+			return v, errors.New("Enter valid BIC.")
+		}
+	}
+	return BIC{String: s, Valid: true}, nil
+}
+
+type LandlordName string
+
+func parseLandlordName(s string) (LandlordName, error) {
+	v := LandlordName(strings.TrimSpace(s))
+	vLen := len(v)
+	switch {
+	case v == "":
+		return v, errors.New("Enter name.")
+	case vLen > 255:
+		return v, errors.New("Enter name with maximum of 255 characters.")
+	default:
+		return v, nil
+	}
+}
+
+type CompanyID struct {
+	String string
+	Valid  bool
+}
+
+func parseCompanyID(s string) (CompanyID, error) {
+	var v CompanyID
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return v, nil
+	}
+	if len(s) > 16 {
+		return v, errors.New("Enter company ID with maximum of 16 characters.")
+	}
+	return CompanyID{String: s, Valid: true}, nil
+}
+
+type VATID struct {
+	String string
+	Valid  bool
+}
+
+func parseVATID(s string) (VATID, error) {
+	var v VATID
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	if s == "" {
+		return v, nil
+	}
+	if len(s) < 4 || len(s) > 16 {
+		return v, errors.New("Enter valid VAT ID.")
+	}
+	for i, c := range s {
+		if i < 2 && !(c >= 'A' && c <= 'Z') ||
+			!(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z') {
+			return v, errors.New("Enter valid VAT ID.")
+		}
+	}
+	return VATID{String: s, Valid: true}, nil
+}
+
+type NumberingPattern string
+
+func parseNumberingPattern(s string) (NumberingPattern, error) {
+	v := NumberingPattern(strings.TrimSpace(s))
+	if v == "" {
+		return v, errors.New("Enter numbering pattern.")
+	}
+	if len(v) > 64 {
+		return v, errors.New("Enter numbering pattern with maximum of 64 characters.")
+	}
+	var hasSeq bool
+	for _, match := range numberingPlaceholderRe.FindAllStringSubmatch(string(v), -1) {
+		switch {
+		case match[1] == "year" && match[2] != "":
+			return v, errors.New("Enter numbering pattern with year without padding.")
+		case match[1] == "seq":
+			hasSeq = true
+		}
+	}
+	if !hasSeq {
+		return v, errors.New("Enter numbering pattern with sequence placeholder.")
+	}
+	if strings.ContainsAny(numberingPlaceholderRe.ReplaceAllString(string(v), ""), "{}") {
+		return v, errors.New("Enter numbering pattern with valid placeholders.")
+	}
+	return v, nil
+}
+
+func parseTimeMark(s string) (timeMarksEnd bool, err error) {
+	switch s {
+	case "end":
+		return true, nil
+	case "begin":
+		return false, nil
+	default:
+		return false, errors.New("Select valid interval time mark.")
+	}
+}
+
+func parseValueUnit(s string) (averagePower bool, err error) {
+	switch s {
+	case "energy":
+		return false, nil
+	case "power":
+		return true, nil
+	default:
+		return false, errors.New("Select valid value unit.")
+	}
+}
+
+func parseIntervalLength(s string) (time.Duration, error) {
+	minutes, err := strconv.Atoi(s)
+	if err != nil || !slices.Contains(intervalLengths, time.Duration(minutes)*time.Minute) {
+		return 0, errors.New("Select valid interval length.")
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/svoboond/spinus/internal/conf"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+)
+
+// Challenges of registration and login ceremonies wait in the session for
+// the response of the authenticator.
+const (
+	passkeyRegistrationKey = "passkeyRegistration"
+	passkeyLogInKey        = "passkeyLogIn"
+)
+
+type PasskeyLogInResponse struct {
+	Redirect string `json:"redirect"`
+}
+
+// newWebAuthn makes the relying party the host of the base URL. Passkeys are
+// required to verify the user, with PIN or biometrics, so they replace both
+// password and two-factor code.
+func newWebAuthn(config *conf.Conf) (*webauthn.WebAuthn, error) {
+	baseURL, err := url.Parse(config.Service.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse base URL: %w", err)
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          baseURL.Hostname(),
+		RPDisplayName: "Spinus",
+		RPOrigins:     []string{baseURL.Scheme + "://" + baseURL.Host},
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+	})
+}
+
+// passkeyUser is the user as seen by WebAuthn. User handle is the user ID,
+// which identifies the user without personal data.
+type passkeyUser struct {
+	user        spinusdb.SpinusUser
+	credentials []spinusdb.WebauthnCredential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte          { return passkeyUserHandle(u.user.ID) }
+func (u *passkeyUser) WebAuthnName() string        { return u.user.Username }
+func (u *passkeyUser) WebAuthnDisplayName() string { return u.user.Username }
+func (u *passkeyUser) WebAuthnIcon() string        { return "" }
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.credentials))
+	for i, credential := range u.credentials {
+		transports := make([]protocol.AuthenticatorTransport, len(credential.Transports))
+		for j, transport := range credential.Transports {
+			transports[j] = protocol.AuthenticatorTransport(transport)
+		}
+		credentials[i] = webauthn.Credential{
+			ID:              credential.CredentialID,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: credential.BackupEligible,
+				BackupState:    credential.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    credential.Aaguid,
+				SignCount: uint32(credential.SignCount),
+			},
+		}
+	}
+	return credentials
+}
+
+func passkeyUserHandle(userID int32) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(userID))
+}
+
+func (s *Server) getPasskeyUser(ctx context.Context, userID int32) (*passkeyUser, error) {
+	user, err := s.queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	credentials, err := s.queries.ListUserWebauthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not list credentials: %w", err)
+	}
+	return &passkeyUser{user: user, credentials: credentials}, nil
+}
+
+// getDiscoverablePasskeyUser finds the user of a passkey chosen in the browser
+// without entering a username.
+func (s *Server) getDiscoverablePasskeyUser(
+	ctx context.Context, userHandle []byte,
+) (*passkeyUser, error) {
+	if len(userHandle) != 4 {
+		return nil, errors.New("user handle is not user ID")
+	}
+	return s.getPasskeyUser(ctx, int32(binary.BigEndian.Uint32(userHandle)))
+}
+
+func (s *Server) putPasskeySession(
+	ctx context.Context, key string, session *webauthn.SessionData,
+) error {
+	b, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("could not marshal session: %w", err)
+	}
+	s.sessionManager.Put(ctx, key, b)
+	return nil
+}
+
+// popPasskeySession makes each challenge usable once.
+func (s *Server) popPasskeySession(
+	ctx context.Context, key string,
+) (webauthn.SessionData, bool) {
+	var session webauthn.SessionData
+	b := s.sessionManager.PopBytes(ctx, key)
+	if b == nil {
+		return session, false
+	}
+	if err := json.Unmarshal(b, &session); err != nil {
+		return session, false
+	}
+	return session, true
+}
+
+func newCreateWebauthnCredentialParams(
+	userID int32, credential *webauthn.Credential, name PasskeyName,
+) spinusdb.CreateWebauthnCredentialParams {
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+	return spinusdb.CreateWebauthnCredentialParams{
+		FkUser:          userID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		Aaguid:          credential.Authenticator.AAGUID,
+		SignCount:       int64(credential.Authenticator.SignCount),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            string(name),
+	}
+}
@@ -0,0 +1,535 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/svoboond/spinus/internal/conf"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+)
+
+const (
+	passkeyTestOrigin = "https://spinus.example"
+	passkeyTestUserID = 42
+)
+
+// passkeyDB answers the queries of passkey handlers from memory.
+type passkeyDB struct {
+	mu          sync.Mutex
+	users       []spinusdb.SpinusUser
+	credentials []spinusdb.WebauthnCredential
+}
+
+// queryName returns name of sqlc query from its leading comment.
+func queryName(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) < 3 {
+		return ""
+	}
+	return fields[2]
+}
+
+func (db *passkeyDB) Exec(
+	_ context.Context, sql string, args ...interface{},
+) (pgconn.CommandTag, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	switch queryName(sql) {
+	case "CreateWebauthnCredential":
+		db.credentials = append(db.credentials, spinusdb.WebauthnCredential{
+			ID:              int32(len(db.credentials) + 1),
+			FkUser:          args[0].(int32),
+			CredentialID:    args[1].([]byte),
+			PublicKey:       args[2].([]byte),
+			AttestationType: args[3].(string),
+			Transports:      args[4].([]string),
+			Aaguid:          args[5].([]byte),
+			SignCount:       args[6].(int64),
+			BackupEligible:  args[7].(bool),
+			BackupState:     args[8].(bool),
+			Name:            args[9].(string),
+		})
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+	case "UpdateWebauthnCredentialUse":
+		for i := range db.credentials {
+			if bytes.Equal(db.credentials[i].CredentialID, args[0].([]byte)) {
+				db.credentials[i].SignCount = args[1].(int64)
+				db.credentials[i].BackupState = args[2].(bool)
+			}
+		}
+		return pgconn.NewCommandTag("UPDATE 1"), nil
+	}
+	return pgconn.CommandTag{}, fmt.Errorf("unexpected query %s", queryName(sql))
+}
+
+func (db *passkeyDB) Query(
+	_ context.Context, sql string, args ...interface{},
+) (pgx.Rows, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	switch queryName(sql) {
+	case "ListUserWebauthnCredentials":
+		var rows []any
+		for _, credential := range db.credentials {
+			if credential.FkUser == args[0].(int32) {
+				rows = append(rows, credential)
+			}
+		}
+		return &passkeyRows{rows: rows}, nil
+	}
+	return nil, fmt.Errorf("unexpected query %s", queryName(sql))
+}
+
+func (db *passkeyDB) QueryRow(
+	_ context.Context, sql string, args ...interface{},
+) pgx.Row {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	switch queryName(sql) {
+	case "GetUserByID":
+		for _, user := range db.users {
+			if user.ID == args[0].(int32) {
+				return &passkeyRows{rows: []any{user}}
+			}
+		}
+		return &passkeyRows{}
+	}
+	return &passkeyRows{err: fmt.Errorf("unexpected query %s", queryName(sql))}
+}
+
+func (db *passkeyDB) CopyFrom(
+	context.Context, pgx.Identifier, []string, pgx.CopyFromSource,
+) (int64, error) {
+	return 0, errors.New("unexpected copy")
+}
+
+func (db *passkeyDB) credential(t *testing.T) spinusdb.WebauthnCredential {
+	t.Helper()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.credentials) != 1 {
+		t.Fatalf("%d credentials stored, want 1", len(db.credentials))
+	}
+	return db.credentials[0]
+}
+
+// passkeyRows scans fields of model structs in order of their columns.
+type passkeyRows struct {
+	rows []any
+	row  any
+	err  error
+}
+
+func (r *passkeyRows) Close()                        {}
+func (r *passkeyRows) Err() error                    { return r.err }
+func (r *passkeyRows) CommandTag() pgconn.CommandTag { return pgconn.CommandTag{} }
+func (r *passkeyRows) RawValues() [][]byte           { return nil }
+func (r *passkeyRows) Conn() *pgx.Conn               { return nil }
+
+func (r *passkeyRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+
+func (r *passkeyRows) Values() ([]any, error) {
+	return nil, errors.New("values are not supported")
+}
+
+func (r *passkeyRows) Next() bool {
+	if r.err != nil || len(r.rows) == 0 {
+		return false
+	}
+	r.row, r.rows = r.rows[0], r.rows[1:]
+	return true
+}
+
+func (r *passkeyRows) Scan(dest ...any) error {
+	if r.row == nil {
+		if r.err != nil {
+			return r.err
+		}
+		if !r.Next() {
+			return pgx.ErrNoRows
+		}
+	}
+	v := reflect.ValueOf(r.row)
+	if v.NumField() != len(dest) {
+		return fmt.Errorf("scanning %d columns to %d fields", v.NumField(), len(dest))
+	}
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(v.Field(i))
+	}
+	return nil
+}
+
+// softAuthenticator is a passkey authenticator with a P-256 key in memory.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+type passkeyOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+		RPID      string `json:"rpId"`
+		RP        struct {
+			ID string `json:"id"`
+		} `json:"rp"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	} `json:"publicKey"`
+}
+
+func encodeBase64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony, challenge string) []byte {
+	t.Helper()
+	clientData, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    passkeyTestOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientData
+}
+
+// authenticatorData has user present and verified, and backup eligible and
+// backed up, as synced passkeys do.
+func (a *softAuthenticator) authenticatorData(rpID string, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags|0x1D)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+// create answers options of registration with attestation of format none.
+func (a *softAuthenticator) create(t *testing.T, optionsJSON []byte) []byte {
+	t.Helper()
+	var options passkeyOptions
+	if err := json.Unmarshal(optionsJSON, &options); err != nil {
+		t.Fatal(err)
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(options.PublicKey.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // EC2 key type
+		3:  -7, // ES256
+		-1: 1,  // P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authData := a.authenticatorData(options.PublicKey.RP.ID, 0x40)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientData := a.clientData(t, "webauthn.create", options.PublicKey.Challenge)
+	body, err := json.Marshal(map[string]any{
+		"id":    encodeBase64(a.credentialID),
+		"rawId": encodeBase64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encodeBase64(clientData),
+			"attestationObject": encodeBase64(attestation),
+			"transports":        []string{"internal", "hybrid"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// get answers options of discoverable login with signed assertion.
+func (a *softAuthenticator) get(t *testing.T, optionsJSON []byte) []byte {
+	t.Helper()
+	var options passkeyOptions
+	if err := json.Unmarshal(optionsJSON, &options); err != nil {
+		t.Fatal(err)
+	}
+	a.signCount++
+	authData := a.authenticatorData(options.PublicKey.RPID, 0)
+	clientData := a.clientData(t, "webauthn.get", options.PublicKey.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(map[string]any{
+		"id":    encodeBase64(a.credentialID),
+		"rawId": encodeBase64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encodeBase64(clientData),
+			"authenticatorData": encodeBase64(authData),
+			"signature":         encodeBase64(signature),
+			"userHandle":        encodeBase64(a.userHandle),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// newPasskeyTestServer serves passkey routes with sessions in memory. Posting
+// to /test/login logs the browser in as the test user and /test/user answers
+// the ID of the logged in user.
+func newPasskeyTestServer(t *testing.T) (*httptest.Server, *passkeyDB) {
+	t.Helper()
+	config := &conf.Conf{}
+	config.Service.BaseURL = passkeyTestOrigin
+	webAuthn, err := newWebAuthn(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &passkeyDB{
+		users: []spinusdb.SpinusUser{{ID: passkeyTestUserID, Username: "john"}},
+	}
+	app := &Server{
+		queries:        spinusdb.New(db),
+		sessionManager: scs.New(),
+		webAuthn:       webAuthn,
+	}
+
+	router := chi.NewRouter()
+	router.Use(app.sessionManager.LoadAndSave, app.WithUserID)
+	router.Post("/login/passkey/begin", app.HandlePostLogInPasskeyBegin)
+	router.Post("/login/passkey/finish", app.HandlePostLogInPasskeyFinish)
+	router.Post("/passkey/register/begin", app.HandlePostPasskeyRegisterBegin)
+	router.Post("/passkey/register/finish", app.HandlePostPasskeyRegisterFinish)
+	router.Post("/test/login", func(w http.ResponseWriter, r *http.Request) {
+		app.sessionManager.Put(r.Context(), userIDKey, int32(passkeyTestUserID))
+	})
+	router.Get("/test/user", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserID(r.Context())
+		fmt.Fprint(w, userID)
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, db
+}
+
+// passkeyBrowser keeps the session cookie like a browser.
+type passkeyBrowser struct {
+	t      *testing.T
+	server *httptest.Server
+	client *http.Client
+}
+
+func newPasskeyBrowser(t *testing.T, server *httptest.Server) *passkeyBrowser {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &passkeyBrowser{t: t, server: server, client: &http.Client{Jar: jar}}
+}
+
+func (b *passkeyBrowser) do(method, path string, body []byte) (int, []byte) {
+	b.t.Helper()
+	req, err := http.NewRequest(method, b.server.URL+path, bytes.NewReader(body))
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	return resp.StatusCode, respBody
+}
+
+func (b *passkeyBrowser) post(path string, body []byte, wantStatus int) []byte {
+	b.t.Helper()
+	status, respBody := b.do(http.MethodPost, path, body)
+	if status != wantStatus {
+		b.t.Fatalf("POST %s = %d %s, want %d", path, status, respBody, wantStatus)
+	}
+	return respBody
+}
+
+func (b *passkeyBrowser) userID() int32 {
+	b.t.Helper()
+	_, body := b.do(http.MethodGet, "/test/user", nil)
+	userID, err := strconv.ParseInt(string(body), 10, 32)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	return int32(userID)
+}
+
+func (b *passkeyBrowser) register(authenticator *softAuthenticator) {
+	b.t.Helper()
+	b.post("/test/login", nil, http.StatusOK)
+	options := b.post("/passkey/register/begin", nil, http.StatusOK)
+	b.post(
+		"/passkey/register/finish?name=Phone", authenticator.create(b.t, options),
+		http.StatusNoContent)
+}
+
+func TestPasskeyRegisterAndLogIn(t *testing.T) {
+	server, db := newPasskeyTestServer(t)
+	authenticator := newSoftAuthenticator(t)
+	newPasskeyBrowser(t, server).register(authenticator)
+
+	credential := db.credential(t)
+	if credential.FkUser != passkeyTestUserID || credential.Name != "Phone" ||
+		!bytes.Equal(credential.CredentialID, authenticator.credentialID) ||
+		!credential.BackupEligible || !credential.BackupState ||
+		!reflect.DeepEqual(credential.Transports, []string{"internal", "hybrid"}) {
+
+		t.Errorf("stored credential = %+v", credential)
+	}
+	if !bytes.Equal(authenticator.userHandle, passkeyUserHandle(passkeyTestUserID)) {
+		t.Errorf("user handle = %X", authenticator.userHandle)
+	}
+
+	browser := newPasskeyBrowser(t, server)
+	options := browser.post("/login/passkey/begin", nil, http.StatusOK)
+	body := browser.post(
+		"/login/passkey/finish?next=/main-meter/list", authenticator.get(t, options),
+		http.StatusOK)
+	var resp PasskeyLogInResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Redirect != "/main-meter/list" {
+		t.Errorf("redirect = %q", resp.Redirect)
+	}
+	if userID := browser.userID(); userID != passkeyTestUserID {
+		t.Errorf("logged in user ID = %d, want %d", userID, passkeyTestUserID)
+	}
+	if signCount := db.credential(t).SignCount; signCount != 1 {
+		t.Errorf("stored sign count = %d, want 1", signCount)
+	}
+}
+
+func TestPasskeyRegisterReplayedChallenge(t *testing.T) {
+	server, db := newPasskeyTestServer(t)
+	authenticator := newSoftAuthenticator(t)
+	browser := newPasskeyBrowser(t, server)
+	browser.post("/test/login", nil, http.StatusOK)
+	options := browser.post("/passkey/register/begin", nil, http.StatusOK)
+	body := authenticator.create(t, options)
+	browser.post("/passkey/register/finish?name=Phone", body, http.StatusNoContent)
+
+	browser.post("/passkey/register/finish?name=Phone", body, http.StatusBadRequest)
+	db.credential(t)
+}
+
+func TestPasskeyLogInReplayedChallenge(t *testing.T) {
+	tests := []struct {
+		name string
+		// newChallenge begins another login before the response is replayed.
+		newChallenge bool
+		status       int
+	}{
+		{"same challenge", false, http.StatusBadRequest},
+		{"new challenge", true, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newPasskeyTestServer(t)
+			authenticator := newSoftAuthenticator(t)
+			newPasskeyBrowser(t, server).register(authenticator)
+
+			browser := newPasskeyBrowser(t, server)
+			options := browser.post("/login/passkey/begin", nil, http.StatusOK)
+			body := authenticator.get(t, options)
+			browser.post("/login/passkey/finish", body, http.StatusOK)
+
+			attacker := newPasskeyBrowser(t, server)
+			if tt.newChallenge {
+				attacker.post("/login/passkey/begin", nil, http.StatusOK)
+			}
+			attacker.post("/login/passkey/finish", body, tt.status)
+			if userID := attacker.userID(); userID != emptyUserIDValue {
+				t.Errorf("replayed response logged in user %d", userID)
+			}
+		})
+	}
+}
+
+func TestPasskeyLogInSignCountRegression(t *testing.T) {
+	server, db := newPasskeyTestServer(t)
+	authenticator := newSoftAuthenticator(t)
+	newPasskeyBrowser(t, server).register(authenticator)
+
+	authenticator.signCount = 9
+	browser := newPasskeyBrowser(t, server)
+	options := browser.post("/login/passkey/begin", nil, http.StatusOK)
+	browser.post("/login/passkey/finish", authenticator.get(t, options), http.StatusOK)
+
+	// Copy of the key with an older counter.
+	authenticator.signCount = 5
+	clone := newPasskeyBrowser(t, server)
+	options = clone.post("/login/passkey/begin", nil, http.StatusOK)
+	clone.post(
+		"/login/passkey/finish", authenticator.get(t, options), http.StatusUnauthorized)
+	if userID := clone.userID(); userID != emptyUserIDValue {
+		t.Errorf("cloned passkey logged in user %d", userID)
+	}
+	if signCount := db.credential(t).SignCount; signCount != 10 {
+		t.Errorf("stored sign count = %d, want 10", signCount)
+	}
+}
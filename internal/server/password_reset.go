@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/mail"
+)
+
+const (
+	passwordResetTokenPrefix = "spr_"
+	passwordResetTokenTTL    = time.Hour
+	mailTimeout              = 30 * time.Second
+)
+
+// sendMail sends message in background, so responses do not wait for the SMTP
+// server and do not reveal whether a message was sent.
+func (s *Server) sendMail(message mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, message); err != nil {
+			slog.Error("error sending mail", "subject", message.Subject, "err", err)
+		}
+	}()
+}
+
+func (s *Server) newPasswordResetMessage(user spinusdb.SpinusUser, token string) mail.Message {
+	link := s.baseURL + "/password/reset?" + url.Values{"token": {token}}.Encode()
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Spinus password reset",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\n"+
+				"somebody asked to reset the password of your Spinus account. "+
+				"Open the link below to choose a new password, "+
+				"the link is valid for %d minutes and can be used once.\n\n"+
+				"%s\n\n"+
+				"If it was not you, ignore this email, your password stays the same.\n",
+			user.Username, int(passwordResetTokenTTL.Minutes()), link),
+	}
+}
+
+// destroyUserSessions logs the user out of all browsers.
+func (s *Server) destroyUserSessions(ctx context.Context, userID int32) error {
+	return s.sessionManager.Iterate(ctx, func(ctx context.Context) error {
+		if s.sessionManager.GetInt32(ctx, userIDKey) != userID {
+			return nil
+		}
+		return s.sessionManager.Destroy(ctx)
+	})
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	_ "image/png"
+
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	maxPhotoSize   = 5 << 20
+	maxPhotoCount  = 5
+	maxPhotoPixels = 50_000_000
+	thumbnailSize  = 160
+	// Leaves room for other form fields.
+	maxPhotoUploadSize = maxPhotoCount*maxPhotoSize + 1<<20
+	// Photos are uploaded from phones, often on slow connections.
+	photoUploadTimeout = 2 * time.Minute
+)
+
+var photoContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+type readingPhoto struct {
+	contentType string
+	data        []byte
+	thumbnail   []byte
+}
+
+func readingPhotoKey(id int32) string { return fmt.Sprintf("reading-photo/%d/original", id) }
+
+func readingPhotoThumbnailKey(id int32) string {
+	return fmt.Sprintf("reading-photo/%d/thumbnail", id)
+}
+
+// parseReadingPhotos reads and validates uploaded photos. Returned errors are
+// meant for the user.
+func parseReadingPhotos(fileHeaders []*multipart.FileHeader) ([]readingPhoto, error) {
+	if len(fileHeaders) > maxPhotoCount {
+		return nil, fmt.Errorf("Upload no more than %d photos.", maxPhotoCount)
+	}
+	photos := make([]readingPhoto, 0, len(fileHeaders))
+	for _, fileHeader := range fileHeaders {
+		photo, err := parseReadingPhoto(fileHeader)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, photo)
+	}
+	return photos, nil
+}
+
+func parseReadingPhoto(fileHeader *multipart.FileHeader) (readingPhoto, error) {
+	var photo readingPhoto
+	if fileHeader.Size > maxPhotoSize {
+		return photo, fmt.Errorf(
+			"Upload photo no larger than %d MB.", maxPhotoSize>>20)
+	}
+	f, err := fileHeader.Open()
+	if err != nil {
+		return photo, errors.New("Upload valid photo.")
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxPhotoSize+1))
+	if err != nil {
+		return photo, errors.New("Upload valid photo.")
+	}
+	if len(data) > maxPhotoSize {
+		return photo, fmt.Errorf(
+			"Upload photo no larger than %d MB.", maxPhotoSize>>20)
+	}
+	contentType := http.DetectContentType(data)
+	if !photoContentTypes[contentType] {
+		return photo, errors.New("Upload JPEG, PNG or WebP photo.")
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return photo, errors.New("Upload valid photo.")
+	}
+	if config.Width*config.Height > maxPhotoPixels {
+		return photo, errors.New("Upload photo with lower resolution.")
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return photo, errors.New("Upload valid photo.")
+	}
+	thumbnail, err := newThumbnail(img)
+	if err != nil {
+		return photo, errors.New("Upload valid photo.")
+	}
+	return readingPhoto{contentType: contentType, data: data, thumbnail: thumbnail}, nil
+}
+
+// newThumbnail returns JPEG image scaled down to fit in a square of
+// thumbnailSize pixels.
+func newThumbnail(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailSize || height > thumbnailSize {
+		if width > height {
+			width, height = thumbnailSize, max(1, height*thumbnailSize/width)
+		} else {
+			width, height = max(1, width*thumbnailSize/height), thumbnailSize
+		}
+	}
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	// JPEG has no transparency.
+	draw.Draw(thumbnail, thumbnail.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.ApproxBiLinear.Scale(thumbnail, thumbnail.Bounds(), img, bounds, draw.Over, nil)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("could not encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// createReadingPhotos creates photos of the reading and stores them. IDs of
+// created photos are returned even on error, so that stored blobs can be
+// deleted if the transaction is rolled back.
+func (s *Server) createReadingPhotos(
+	ctx context.Context,
+	qtx *spinusdb.Queries,
+	readingID int32,
+	photos []readingPhoto,
+) ([]int32, error) {
+	ids := make([]int32, 0, len(photos))
+	for _, photo := range photos {
+		readingPhoto, err := qtx.CreateSubMeterReadingPhoto(
+			ctx,
+			spinusdb.CreateSubMeterReadingPhotoParams{
+				FkReading:   readingID,
+				ContentType: photo.contentType,
+				Size:        int32(len(photo.data)),
+			},
+		)
+		if err != nil {
+			return ids, fmt.Errorf("could not create photo: %w", err)
+		}
+		ids = append(ids, readingPhoto.ID)
+		if err := s.blobStorage.Put(
+			ctx,
+			readingPhotoKey(readingPhoto.ID),
+			bytes.NewReader(photo.data),
+			photo.contentType,
+		); err != nil {
+			return ids, fmt.Errorf("could not store photo: %w", err)
+		}
+		if err := s.blobStorage.Put(
+			ctx,
+			readingPhotoThumbnailKey(readingPhoto.ID),
+			bytes.NewReader(photo.thumbnail),
+			"image/jpeg",
+		); err != nil {
+			return ids, fmt.Errorf("could not store thumbnail: %w", err)
+		}
+	}
+	return ids, nil
+}
+
+// deleteReadingPhotoBlobs deletes stored photos. Failures are only logged, as
+// the photos are no longer referenced.
+func (s *Server) deleteReadingPhotoBlobs(ctx context.Context, ids []int32) {
+	for _, id := range ids {
+		for _, key := range []string{readingPhotoKey(id), readingPhotoThumbnailKey(id)} {
+			if err := s.blobStorage.Delete(ctx, key); err != nil {
+				slog.Error("error deleting blob", "key", key, "err", err)
+			}
+		}
+	}
+}
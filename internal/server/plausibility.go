@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+)
+
+const (
+	// Daily consumption since previous reading higher than historic average
+	// daily consumption times the factor is suspicious.
+	maxDailyConsumptionFactor = 5
+	// Readings further apart are suspicious, a reading was probably missed.
+	maxReadingGapDays = 366
+)
+
+type readingPoint struct {
+	value float64
+	time  time.Time
+}
+
+// readingCheck is result of plausibility check of a reading. Error blocks the
+// reading, warnings have to be confirmed by the user.
+type readingCheck struct {
+	Error    string
+	Warnings []string
+}
+
+func (c readingCheck) ok() bool { return c.Error == "" && len(c.Warnings) == 0 }
+
+func daysBetween(begin, end time.Time) int {
+	return int(end.Sub(begin).Hours() / 24)
+}
+
+// checkReading checks reading against history of readings taken before it,
+// sorted by date.
+func checkReading(history []readingPoint, value float64, t time.Time) readingCheck {
+	var check readingCheck
+	if len(history) == 0 {
+		return check
+	}
+	previous := history[len(history)-1]
+	if value < previous.value {
+		check.Error = fmt.Sprintf(
+			"Reading value is lower than previous reading %s from %s.",
+			formatConsumption(previous.value), previous.time.Format("2006-01-02"))
+		return check
+	}
+
+	days := daysBetween(previous.time, t)
+	if days > maxReadingGapDays {
+		check.Warnings = append(check.Warnings, fmt.Sprintf(
+			"There are %d days since previous reading from %s.",
+			days, previous.time.Format("2006-01-02")))
+	}
+	if len(history) < 2 || days <= 0 {
+		return check
+	}
+	first := history[0]
+	historyDays := daysBetween(first.time, previous.time)
+	if historyDays <= 0 {
+		return check
+	}
+	averageConsumption := (previous.value - first.value) / float64(historyDays)
+	dailyConsumption := (value - previous.value) / float64(days)
+	if averageConsumption > 0 &&
+		dailyConsumption > maxDailyConsumptionFactor*averageConsumption {
+		check.Warnings = append(check.Warnings, fmt.Sprintf(
+			"Daily consumption %s is more than %d times the average %s.",
+			formatConsumption(dailyConsumption),
+			maxDailyConsumptionFactor,
+			formatConsumption(averageConsumption)))
+	}
+	return check
+}
+
+// checkNewReading checks reading being added to readings of a sub meter, sorted
+// by date descending. Reading must not be higher than the next one.
+func checkNewReading(
+	readings []spinusdb.SubMeterReading, value float64, t time.Time,
+) readingCheck {
+	var history []readingPoint
+	for i := len(readings) - 1; i >= 0; i-- {
+		reading := readings[i]
+		readingTime := reading.ReadingDate.Time
+		if !readingTime.Before(t) {
+			if value > reading.ReadingValue {
+				return readingCheck{Error: fmt.Sprintf(
+					"Reading value is higher than next reading %s from %s.",
+					formatConsumption(reading.ReadingValue),
+					formatDate(reading.ReadingDate))}
+			}
+			break
+		}
+		history = append(history, readingPoint{reading.ReadingValue, readingTime})
+	}
+	return checkReading(history, value, t)
+}
+
+// findReadingAnomalies checks every reading against readings of the same sub
+// meter taken before it. Readings must be sorted by sub meter and date.
+func findReadingAnomalies(
+	readings []spinusdb.ListMainMeterReadingsRow,
+) []ReadingAnomalyTmplData {
+	var anomalies []ReadingAnomalyTmplData
+	var history []readingPoint
+	for i, reading := range readings {
+		if i > 0 && readings[i-1].SubMeterSubid != reading.SubMeterSubid {
+			history = nil
+		}
+		readingTime := reading.ReadingDate.Time
+		check := checkReading(history, reading.ReadingValue, readingTime)
+		if !check.ok() {
+			anomalies = append(anomalies, ReadingAnomalyTmplData{
+				ListMainMeterReadingsRow: reading,
+				Error:                    check.Error,
+				Warnings:                 check.Warnings,
+			})
+		}
+		history = append(history, readingPoint{reading.ReadingValue, readingTime})
+	}
+	return anomalies
+}
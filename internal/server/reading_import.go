@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+)
+
+// ReadingImportRow is a row of imported readings with its validation errors.
+type ReadingImportRow struct {
+	Line         int
+	SubMeter     string
+	ReadingDate  string
+	ReadingValue string
+	Errors       []string
+
+	subMeterID   int32
+	readingTime  time.Time
+	readingValue float64
+}
+
+func (r *ReadingImportRow) Valid() bool { return len(r.Errors) == 0 }
+
+// Rows have sub meter SubID or meter identification, date and value. Header
+// is optional and semicolon is accepted as separator, with decimal comma.
+func parseReadingImportCSV(s string) ([]*ReadingImportRow, error) {
+	csvReader := csv.NewReader(strings.NewReader(s))
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	firstLine, _, _ := strings.Cut(s, "\n")
+	semicolon := strings.Contains(firstLine, ";")
+	if semicolon {
+		csvReader.Comma = ';'
+	}
+
+	var rows []*ReadingImportRow
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read readings: %w", err)
+		}
+		line, _ := csvReader.FieldPos(0)
+		row := &ReadingImportRow{Line: line}
+		if len(record) != 3 {
+			row.Errors = append(row.Errors, "Enter sub meter, date and value.")
+			rows = append(rows, row)
+			continue
+		}
+		row.SubMeter = strings.TrimSpace(record[0])
+		row.ReadingDate = strings.TrimSpace(record[1])
+		row.ReadingValue = strings.TrimSpace(record[2])
+
+		iReadingValue := row.ReadingValue
+		if semicolon {
+			iReadingValue = strings.Replace(iReadingValue, ",", ".", 1)
+		}
+		readingTime, dateErr := parseDate(row.ReadingDate)
+		readingValue, valueErr := parseReadingValue(iReadingValue)
+		if line == 1 && dateErr != nil && valueErr != nil {
+			// Header.
+			continue
+		}
+		if dateErr != nil {
+			row.Errors = append(row.Errors, dateErr.Error())
+		}
+		row.readingTime = readingTime.Time
+		if valueErr != nil {
+			row.Errors = append(row.Errors, valueErr.Error())
+		}
+		row.readingValue = float64(readingValue)
+		if row.SubMeter == "" {
+			row.Errors = append(row.Errors, "Enter sub meter.")
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no readings")
+	}
+	return rows, nil
+}
+
+type importedReading struct {
+	time  time.Time
+	value float64
+	row   *ReadingImportRow
+}
+
+// Rows are checked against existing readings and each other, sub meters are
+// found by SubID first and then by meter identification, unless rows already
+// have them.
+func (s *Server) validateReadingImport(
+	ctx context.Context, mainMeterID int32, rows []*ReadingImportRow,
+) error {
+	subMeters, err := s.queries.ListSubMeters(ctx, mainMeterID)
+	if err != nil {
+		return fmt.Errorf("could not list sub meters: %w", err)
+	}
+	findSubMeter := func(v string) (spinusdb.ListSubMetersRow, bool) {
+		if subid, err := strconv.ParseInt(v, 10, 32); err == nil {
+			i := slices.IndexFunc(subMeters, func(sm spinusdb.ListSubMetersRow) bool {
+				return sm.Subid == int32(subid)
+			})
+			if i != -1 {
+				return subMeters[i], true
+			}
+		}
+		i := slices.IndexFunc(subMeters, func(sm spinusdb.ListSubMetersRow) bool {
+			return sm.MeterID.Valid && sm.MeterID.String == v
+		})
+		if i == -1 {
+			return spinusdb.ListSubMetersRow{}, false
+		}
+		return subMeters[i], true
+	}
+
+	readings := make(map[int32][]importedReading)
+	for _, row := range rows {
+		if row.SubMeter == "" {
+			continue
+		}
+		if row.subMeterID == 0 {
+			subMeter, ok := findSubMeter(row.SubMeter)
+			if !ok {
+				row.Errors = append(row.Errors, "Unknown sub meter.")
+				continue
+			}
+			row.subMeterID = subMeter.ID
+		}
+		if !row.Valid() {
+			continue
+		}
+		smReadings, ok := readings[row.subMeterID]
+		if !ok {
+			existingReadings, err := s.queries.ListSubMeterReadings(ctx, row.subMeterID)
+			if err != nil {
+				return fmt.Errorf("could not list sub meter readings: %w", err)
+			}
+			for _, reading := range existingReadings {
+				smReadings = append(smReadings, importedReading{
+					time: reading.ReadingDate.Time, value: reading.ReadingValue})
+			}
+		}
+		if i := slices.IndexFunc(smReadings, func(reading importedReading) bool {
+			return reading.time.Equal(row.readingTime)
+		}); i != -1 {
+			if smReadings[i].row == nil {
+				row.Errors = append(
+					row.Errors, "Reading for the given date already exists.")
+			} else {
+				row.Errors = append(row.Errors, fmt.Sprintf(
+					"Reading for the given date is already on line %d.",
+					smReadings[i].row.Line))
+			}
+		} else {
+			smReadings = append(smReadings, importedReading{
+				time: row.readingTime, value: row.readingValue, row: row})
+		}
+		readings[row.subMeterID] = smReadings
+	}
+
+	// Reading values must not decrease in time.
+	for _, smReadings := range readings {
+		slices.SortFunc(smReadings, func(a, b importedReading) int {
+			return a.time.Compare(b.time)
+		})
+		for i, reading := range smReadings {
+			if reading.row == nil {
+				continue
+			}
+			if i > 0 && smReadings[i-1].value > reading.value {
+				reading.row.Errors = append(reading.row.Errors, fmt.Sprintf(
+					"Reading value is lower than reading on %s.",
+					smReadings[i-1].time.Format("2006-01-02")))
+			}
+			if i < len(smReadings)-1 && smReadings[i+1].value < reading.value {
+				reading.row.Errors = append(reading.row.Errors, fmt.Sprintf(
+					"Reading value is greater than reading on %s.",
+					smReadings[i+1].time.Format("2006-01-02")))
+			}
+		}
+	}
+	return nil
+}
+
+// createImportedReadings creates readings of valid rows at once.
+func (s *Server) createImportedReadings(
+	ctx context.Context, userID int32, rows []*ReadingImportRow,
+) error {
+	tx, err := s.postgresClient.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+	for _, row := range rows {
+		if !row.Valid() {
+			continue
+		}
+		_, err := qtx.CreateSubMeterReading(
+			ctx,
+			spinusdb.CreateSubMeterReadingParams{
+				FkSubMeter:   row.subMeterID,
+				ReadingValue: row.readingValue,
+				ReadingDate:  pgtype.Date{Time: row.readingTime, Valid: true},
+				Source:       spinusdb.ReadingSourceImport,
+				FkUser:       pgtype.Int4{Int32: userID, Valid: true},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("could not create sub meter reading: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
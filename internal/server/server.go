@@ -2,23 +2,30 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"net/url"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/alexedwards/scs/goredisstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-chi/chi/v5"
 	chi_middleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 	"github.com/redis/go-redis/v9"
+	"github.com/svoboond/spinus/internal/blob"
 	"github.com/svoboond/spinus/internal/conf"
 	"github.com/svoboond/spinus/internal/db"
 	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/mail"
 	"github.com/svoboond/spinus/internal/tmpl"
 	"github.com/svoboond/spinus/ui"
 )
@@ -30,6 +37,12 @@ type Server struct {
 	queries        *spinusdb.Queries
 	redisClient    *redis.Client
 	sessionManager *scs.SessionManager
+	blobStorage    blob.Storage
+	mailer         mail.Mailer
+	baseURL        string
+	secretKey      []byte
+	webAuthn       *webauthn.WebAuthn
+	location       *time.Location
 }
 
 func New(config *conf.Conf) (*Server, error) {
@@ -69,6 +82,37 @@ func New(config *conf.Conf) (*Server, error) {
 	sessionManager := scs.New()
 	sessionManager.Store = goredisstore.New(redisClient)
 
+	slog.Debug("creating blob storage...")
+	blobStorage, err := newBlobStorage(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not create blob storage: %w", err)
+	}
+
+	mailer, err := newMailer(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not create mailer: %w", err)
+	}
+
+	secretKey, err := newSecretKey(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not create secret key: %w", err)
+	}
+
+	location, err := time.LoadLocation(config.Service.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("could not load time zone: %w", err)
+	}
+
+	webAuthn, err := newWebAuthn(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not create WebAuthn: %w", err)
+	}
+
+	trustedProxies, err := parseTrustedProxies(config.Service.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse trusted proxies: %w", err)
+	}
+
 	// TODO - make timeout configurable
 	server := &http.Server{
 		Addr:              fmt.Sprintf(":%d", config.Service.Port),
@@ -88,11 +132,17 @@ func New(config *conf.Conf) (*Server, error) {
 		queries:        dbQueries,
 		redisClient:    redisClient,
 		sessionManager: sessionManager,
+		blobStorage:    blobStorage,
+		mailer:         mailer,
+		baseURL:        strings.TrimSuffix(config.Service.BaseURL, "/"),
+		secretKey:      secretKey,
+		webAuthn:       webAuthn,
+		location:       location,
 	}
 
 	// middlewares
 	router.Use(chi_middleware.Recoverer)
-	router.Use(chi_middleware.RealIP)
+	router.Use(WithRealIP(trustedProxies))
 	router.Use(chi_middleware.Logger)
 	router.Use(chi_middleware.RequestID)
 	router.Use(sessionManager.LoadAndSave)
@@ -113,16 +163,77 @@ func New(config *conf.Conf) (*Server, error) {
 
 	router.Get("/signup", app.HandleGetSignUp)
 	router.Post("/signup", app.HandlePostSignUp)
+	router.Get("/signup/verify", app.HandleGetSignUpVerify)
 	router.Get("/login", app.HandleGetLogIn)
 	router.Post("/login", app.HandlePostLogIn)
+	router.Get("/login/two-factor", app.HandleGetLogInTwoFactor)
+	router.Post("/login/two-factor", app.HandlePostLogInTwoFactor)
+	router.Post("/login/passkey/begin", app.HandlePostLogInPasskeyBegin)
+	router.Post("/login/passkey/finish", app.HandlePostLogInPasskeyFinish)
+	router.Get("/password/forgot", app.HandleGetPasswordForgot)
+	router.Post("/password/forgot", app.HandlePostPasswordForgot)
+	router.Get("/password/reset", app.HandleGetPasswordReset)
+	router.Post("/password/reset", app.HandlePostPasswordReset)
+	router.Get("/email/verify", app.HandleGetEmailVerify)
+
+	router.Group(func(deviceRouter chi.Router) {
+		deviceRouter.Use(router.Middlewares()...)
+		deviceRouter.Use(app.WithDeviceToken)
+		deviceRouter.Post("/ingest/v1/readings", app.HandlePostIngestReadings)
+		deviceRouter.Post("/ingest/v1/dsmr", app.HandlePostIngestDSMR)
+	})
+
+	router.Group(func(syncRouter chi.Router) {
+		syncRouter.Use(router.Middlewares()...)
+		syncRouter.Use(app.WithRequiredAPILogin)
+		syncRouter.Post("/sync/v1/readings", app.HandlePostSyncReadings)
+	})
 
 	router.Group(func(loggedInRouter chi.Router) {
 		loggedInRouter.Use(router.Middlewares()...)
 		loggedInRouter.Use(app.WithRequiredLogin)
 		loggedInRouter.Post("/logout", app.HandlePostLogOut)
+		loggedInRouter.Get("/email/verification", app.HandleGetEmailVerification)
+		loggedInRouter.Post("/email/verification", app.HandlePostEmailVerification)
+		loggedInRouter.Get("/two-factor", app.HandleGetTwoFactor)
+		loggedInRouter.Post("/two-factor/setup", app.HandlePostTwoFactorSetup)
+		loggedInRouter.Post("/two-factor/enable", app.HandlePostTwoFactorEnable)
+		loggedInRouter.Post("/two-factor/disable", app.HandlePostTwoFactorDisable)
+		loggedInRouter.Get("/passkey/list", app.HandleGetPasskeyList)
+		loggedInRouter.Post("/passkey/register/begin", app.HandlePostPasskeyRegisterBegin)
+		loggedInRouter.Post("/passkey/register/finish", app.HandlePostPasskeyRegisterFinish)
+		loggedInRouter.Post(
+			"/passkey/{passkeyID:^[0-9]+$}/delete", app.HandlePostPasskeyDelete)
 		loggedInRouter.Get("/main-meter/list", app.HandleGetMainMeterList)
+		loggedInRouter.With(WithWriteTimeout(exportWriteTimeout)).Get(
+			"/main-meter/list/export/{format:^(csv|xlsx)$}",
+			app.HandleGetMainMeterListExport,
+		)
 		loggedInRouter.Get("/main-meter/new", app.HandleGetMainMeterCreate)
 		loggedInRouter.Post("/main-meter/new", app.HandlePostMainMeterCreate)
+		loggedInRouter.Get("/landlord-profile", app.HandleGetLandlordProfile)
+		loggedInRouter.Post("/landlord-profile", app.HandlePostLandlordProfile)
+		loggedInRouter.Get("/bank-account", app.HandleGetBankAccount)
+		loggedInRouter.Post("/bank-account", app.HandlePostBankAccount)
+		loggedInRouter.Get("/statement/list", app.HandleGetStatementList)
+		loggedInRouter.Group(func(verifiedRouter chi.Router) {
+			verifiedRouter.Use(loggedInRouter.Middlewares()...)
+			verifiedRouter.Use(app.WithVerifiedEmail)
+			verifiedRouter.Get("/statement/new", app.HandleGetStatementCreate)
+			verifiedRouter.Post("/statement/new", app.HandlePostStatementCreate)
+		})
+		loggedInRouter.Group(func(statementDetailRouter chi.Router) {
+			statementDetailRouter.Use(loggedInRouter.Middlewares()...)
+			statementDetailRouter.Use(app.WithStatement)
+			statementDetailRouter.Get(
+				"/statement/{statementID:^[0-9]+$}/overview",
+				app.HandleGetStatementOverview,
+			)
+			statementDetailRouter.With(WithWriteTimeout(exportWriteTimeout)).Get(
+				"/statement/{statementID:^[0-9]+$}/export/csv",
+				app.HandleGetStatementExportCSV,
+			)
+		})
 		loggedInRouter.Group(func(mainMeterDetailRouter chi.Router) {
 			mainMeterDetailRouter.Use(loggedInRouter.Middlewares()...)
 			mainMeterDetailRouter.Use(app.WithMainMeter)
@@ -142,18 +253,93 @@ func New(config *conf.Conf) (*Server, error) {
 				"/main-meter/{mainMeterID:^[0-9]+$}/sub-meter/new",
 				app.HandlePostSubMeterCreate,
 			)
+			mainMeterDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/sub-meter/reading/import",
+				app.HandleGetSubMeterReadingImport,
+			)
+			mainMeterDetailRouter.Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/sub-meter/reading/import",
+				app.HandlePostSubMeterReadingImport,
+			)
+			mainMeterDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/sub-meter/reading/import/wmbus",
+				app.HandleGetWmbusImport,
+			)
+			mainMeterDetailRouter.Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/sub-meter/reading/import/wmbus",
+				app.HandlePostWmbusImport,
+			)
+			mainMeterDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/sub-meter/wmbus-key/list",
+				app.HandleGetWmbusKeyList,
+			)
+			mainMeterDetailRouter.Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/sub-meter/wmbus-key/list",
+				app.HandlePostWmbusKeyList,
+			)
+			mainMeterDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/sub-meter/reading/anomaly/list",
+				app.HandleGetReadingAnomalyList,
+			)
+			mainMeterDetailRouter.With(WithWriteTimeout(exportWriteTimeout)).Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/reading/export/{format:^(csv|xlsx)$}",
+				app.HandleGetMainMeterReadingExport,
+			)
+			mainMeterDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/reading-round/new",
+				app.HandleGetReadingRoundCreate,
+			)
+			mainMeterDetailRouter.Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/reading-round/new",
+				app.HandlePostReadingRoundCreate,
+			)
 			mainMeterDetailRouter.Get(
 				"/main-meter/{mainMeterID:^[0-9]+$}/billing/list",
 				app.HandleGetMainMeterBillingList,
 			)
-			mainMeterDetailRouter.Get(
+		})
+		loggedInRouter.Group(func(verifiedMainMeterDetailRouter chi.Router) {
+			verifiedMainMeterDetailRouter.Use(loggedInRouter.Middlewares()...)
+			verifiedMainMeterDetailRouter.Use(app.WithVerifiedEmail)
+			verifiedMainMeterDetailRouter.Use(app.WithMainMeter)
+			verifiedMainMeterDetailRouter.Get(
 				"/main-meter/{mainMeterID:^[0-9]+$}/billing/new",
 				app.HandleGetMainMeterBillingCreate,
 			)
-			mainMeterDetailRouter.Post(
+			verifiedMainMeterDetailRouter.Post(
 				"/main-meter/{mainMeterID:^[0-9]+$}/billing/new",
 				app.HandlePostMainMeterBillingCreate,
 			)
+			verifiedMainMeterDetailRouter.Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/billing/new/isdoc",
+				app.HandlePostMainMeterBillingImportISDOC,
+			)
+		})
+		loggedInRouter.Group(func(mainMeterBillingDetailRouter chi.Router) {
+			mainMeterBillingDetailRouter.Use(loggedInRouter.Middlewares()...)
+			mainMeterBillingDetailRouter.Use(app.WithMainMeterBilling)
+			mainMeterBillingDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"billing/{billingID:^[0-9]+$}/overview",
+				app.HandleGetMainMeterBillingOverview,
+			)
+			mainMeterBillingDetailRouter.With(WithWriteTimeout(exportWriteTimeout)).Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"billing/{billingID:^[0-9]+$}/export/{format:^(csv|xlsx)$}",
+				app.HandleGetMainMeterBillingExport,
+			)
+			mainMeterBillingDetailRouter.With(WithWriteTimeout(statementZipWriteTimeout)).Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"billing/{billingID:^[0-9]+$}/statement/zip",
+				app.HandleGetMainMeterBillingStatementZip,
+			)
+			mainMeterBillingDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"billing/{billingID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/statement/pdf",
+				app.HandleGetSubMeterBillingStatementPDF,
+			)
 		})
 		loggedInRouter.Group(func(subMeterDetailRouter chi.Router) {
 			subMeterDetailRouter.Use(loggedInRouter.Middlewares()...)
@@ -168,22 +354,149 @@ func New(config *conf.Conf) (*Server, error) {
 					"sub-meter/{subMeterID:^[0-9]+$}/reading/list",
 				app.HandleGetSubMeterReadingList,
 			)
+			subMeterDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/interval/list",
+				app.HandleGetSubMeterIntervalList,
+			)
+			subMeterDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/interval/import",
+				app.HandleGetSubMeterIntervalImport,
+			)
+			subMeterDetailRouter.Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/interval/import",
+				app.HandlePostSubMeterIntervalImport,
+			)
+			subMeterDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/device/list",
+				app.HandleGetDeviceList,
+			)
+			subMeterDetailRouter.Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/device/new",
+				app.HandlePostDeviceCreate,
+			)
+			subMeterDetailRouter.Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/device/{deviceID:^[0-9]+$}/delete",
+				app.HandlePostDeviceDelete,
+			)
+			subMeterDetailRouter.With(WithWriteTimeout(exportWriteTimeout)).Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/reading/export/{format:^(csv|xlsx)$}",
+				app.HandleGetSubMeterReadingExport,
+			)
 			subMeterDetailRouter.Get(
 				"/main-meter/{mainMeterID:^[0-9]+$}/"+
 					"sub-meter/{subMeterID:^[0-9]+$}/reading/new",
 				app.HandleGetSubMeterReadingCreate,
 			)
-			subMeterDetailRouter.Post(
+			subMeterDetailRouter.With(WithReadTimeout(photoUploadTimeout)).Post(
 				"/main-meter/{mainMeterID:^[0-9]+$}/"+
 					"sub-meter/{subMeterID:^[0-9]+$}/reading/new",
 				app.HandlePostSubMeterReadingCreate,
 			)
 		})
+		loggedInRouter.Group(func(subMeterReadingDetailRouter chi.Router) {
+			subMeterReadingDetailRouter.Use(loggedInRouter.Middlewares()...)
+			subMeterReadingDetailRouter.Use(app.WithSubMeter)
+			subMeterReadingDetailRouter.Use(app.WithSubMeterReading)
+			subMeterReadingDetailRouter.Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/"+
+					"reading/{readingID:^[0-9]+$}/delete",
+				app.HandlePostSubMeterReadingDelete,
+			)
+			subMeterReadingDetailRouter.With(WithReadTimeout(photoUploadTimeout)).Post(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/"+
+					"reading/{readingID:^[0-9]+$}/photo/new",
+				app.HandlePostSubMeterReadingPhotoCreate,
+			)
+			subMeterReadingDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/"+
+					"reading/{readingID:^[0-9]+$}/photo/{photoID:^[0-9]+$}",
+				app.HandleGetSubMeterReadingPhoto,
+			)
+			subMeterReadingDetailRouter.Get(
+				"/main-meter/{mainMeterID:^[0-9]+$}/"+
+					"sub-meter/{subMeterID:^[0-9]+$}/"+
+					"reading/{readingID:^[0-9]+$}/photo/{photoID:^[0-9]+$}/thumbnail",
+				app.HandleGetSubMeterReadingPhotoThumbnail,
+			)
+		})
 	})
 
 	return app, nil
 }
 
+func newBlobStorage(config *conf.Conf) (blob.Storage, error) {
+	switch storage := config.Storage; storage.Type {
+	case "", "local":
+		return blob.NewLocalStorage(storage.Local.Path)
+	case "s3":
+		return blob.NewS3Storage(
+			storage.S3.Endpoint,
+			storage.S3.Region,
+			storage.S3.Bucket,
+			storage.S3.AccessKey,
+			storage.S3.SecretKey,
+		)
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", storage.Type)
+	}
+}
+
+func newMailer(config *conf.Conf) (mail.Mailer, error) {
+	switch mailConf := config.Mail; mailConf.Type {
+	case "", "log":
+		return mail.LogMailer{}, nil
+	case "smtp":
+		return mail.NewSMTPMailer(
+			mailConf.SMTP.Host,
+			mailConf.SMTP.Port,
+			mailConf.SMTP.Username,
+			mailConf.SMTP.Password,
+			mailConf.From,
+		)
+	default:
+		return nil, fmt.Errorf("unknown mail type %q", mailConf.Type)
+	}
+}
+
+func newSecretKey(config *conf.Conf) ([]byte, error) {
+	if config.Service.SecretKey != "" {
+		return []byte(config.Service.SecretKey), nil
+	}
+	slog.Warn("secret key is not set, links in emails are not valid after restart")
+	secretKey := make([]byte, 32)
+	if _, err := rand.Read(secretKey); err != nil {
+		return nil, fmt.Errorf("could not read random bytes: %w", err)
+	}
+	return secretKey, nil
+}
+
+// parseTrustedProxies accepts single addresses as well as networks.
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, len(proxies))
+	for i, proxy := range proxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes[i] = netip.PrefixFrom(addr, addr.BitLen())
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q", proxy)
+		}
+		prefixes[i] = prefix.Masked()
+	}
+	return prefixes, nil
+}
+
 func (s *Server) ListenAndServe() error { return s.server.ListenAndServe() }
 func (s *Server) Shutdown(ctx context.Context) error {
 	defer s.postgresClient.Close()
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/mail"
+)
+
+const (
+	signUpTokenPrefix    = "sps_"
+	signUpTokenTTL       = 24 * time.Hour
+	signUpNoticeInterval = time.Hour
+	uniqueViolationCode  = "23505"
+)
+
+// pendingSignUp waits in Redis until the link sent to the email is opened.
+// The account is created only then, so sign up with a registered email looks
+// the same as with a new one, both before and after, and cannot be used to
+// probe registered emails.
+type pendingSignUp struct {
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+}
+
+func pendingSignUpKey(tokenHash []byte) string {
+	return fmt.Sprintf("signup:%x", tokenHash)
+}
+
+// reserveUsername keeps the username for a pending sign up, also when the
+// email is registered and no account will be created. It reports whether the
+// username was free.
+func (s *Server) reserveUsername(ctx context.Context, username Username) (bool, error) {
+	ok, err := s.redisClient.SetNX(
+		ctx, "signup-username:"+string(username), 1, signUpTokenTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("could not reserve username: %w", err)
+	}
+	return ok, nil
+}
+
+func (s *Server) sendSignUp(ctx context.Context, signUp pendingSignUp) error {
+	token, tokenHash, err := newToken(signUpTokenPrefix)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(signUp)
+	if err != nil {
+		return fmt.Errorf("could not marshal sign up: %w", err)
+	}
+	err = s.redisClient.Set(ctx, pendingSignUpKey(tokenHash), b, signUpTokenTTL).Err()
+	if err != nil {
+		return fmt.Errorf("could not store sign up: %w", err)
+	}
+	s.sendMail(s.newSignUpMessage(signUp, token))
+	return nil
+}
+
+// popPendingSignUp makes each link usable once. It reports false for unknown
+// or expired tokens.
+func (s *Server) popPendingSignUp(
+	ctx context.Context, token string,
+) (pendingSignUp, bool, error) {
+	var signUp pendingSignUp
+	b, err := s.redisClient.GetDel(ctx, pendingSignUpKey(hashToken(token))).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return signUp, false, nil
+		}
+		return signUp, false, fmt.Errorf("could not get sign up: %w", err)
+	}
+	if err := json.Unmarshal(b, &signUp); err != nil {
+		return signUp, false, fmt.Errorf("could not unmarshal sign up: %w", err)
+	}
+	return signUp, true, nil
+}
+
+func (s *Server) newSignUpMessage(signUp pendingSignUp, token string) mail.Message {
+	link := s.baseURL + "/signup/verify?" + url.Values{"token": {token}}.Encode()
+	return mail.Message{
+		To:      signUp.Email,
+		Subject: "Spinus sign up",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\n"+
+				"open the link below to finish creating your Spinus account, "+
+				"the link is valid for %d hours and can be used once.\n\n"+
+				"%s\n\n"+
+				"If you did not sign up, ignore this email, no account is created.\n",
+			signUp.Username, int(signUpTokenTTL.Hours()), link),
+	}
+}
+
+// sendSignUpNotice tells the user that somebody signed up with their email,
+// at most once per interval, so the owner cannot be flooded with emails.
+func (s *Server) sendSignUpNotice(ctx context.Context, user spinusdb.SpinusUser) error {
+	key := fmt.Sprintf("signup-notice:%d", user.ID)
+	ok, err := s.redisClient.SetNX(ctx, key, 1, signUpNoticeInterval).Result()
+	if err != nil {
+		return fmt.Errorf("could not limit sign up notice: %w", err)
+	}
+	if ok {
+		s.sendMail(s.newSignUpNoticeMessage(user))
+	}
+	return nil
+}
+
+func (s *Server) newSignUpNoticeMessage(user spinusdb.SpinusUser) mail.Message {
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Spinus sign up",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\n"+
+				"somebody tried to sign up to Spinus with your email, "+
+				"which already belongs to your account, so no account was created. "+
+				"If it was you, log in with your username, "+
+				"or reset your password if you forgot it.\n\n"+
+				"%s\n\n"+
+				"If it was not you, ignore this email.\n",
+			user.Username, s.baseURL+"/password/forgot"),
+	}
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/pdf"
+	"github.com/svoboond/spinus/internal/spayd"
+)
+
+const (
+	paymentCurrency   = "CZK"
+	paymentQRCodeSize = 256
+	// Archive of statements of a large building takes long to write.
+	statementZipWriteTimeout = 5 * time.Minute
+)
+
+// StatementSection holds statement sub meter billings of one energy.
+type StatementSection struct {
+	Energy           spinusdb.Energy
+	SubMeterBillings []spinusdb.ListStatementSubMeterBillingsRow
+	AdvancePrice     float64
+	TotalPrice       float64
+}
+
+func (ss *StatementSection) Balance() float64 { return ss.TotalPrice - ss.AdvancePrice }
+
+// Sub meter billings must be ordered by energy.
+func newStatementSections(
+	subMeterBillings []spinusdb.ListStatementSubMeterBillingsRow,
+) []*StatementSection {
+	var sections []*StatementSection
+	var section *StatementSection
+	for _, subMeterBilling := range subMeterBillings {
+		if section == nil || section.Energy != subMeterBilling.Energy {
+			section = &StatementSection{Energy: subMeterBilling.Energy}
+			sections = append(sections, section)
+		}
+		section.SubMeterBillings = append(section.SubMeterBillings, subMeterBilling)
+		section.AdvancePrice += subMeterBilling.AdvancePrice
+		section.TotalPrice += subMeterBilling.TotalPrice
+	}
+	return sections
+}
+
+func formatPrice(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+
+func formatConsumption(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+
+func formatDate(d pgtype.Date) string {
+	if !d.Valid {
+		return ""
+	}
+	return d.Time.Format("2006-01-02")
+}
+
+func writeStatementCSV(
+	w io.Writer,
+	statement spinusdb.GetStatementRow,
+	subMeterBillings []spinusdb.ListStatementSubMeterBillingsRow,
+) error {
+	csvWriter := csv.NewWriter(w)
+	records := [][]string{
+		{"Statement", strconv.Itoa(int(statement.Subid))},
+		{"Tenant", statement.TenantEmail},
+		{"Begin Date", formatDate(statement.BeginDate)},
+		{"End Date", formatDate(statement.EndDate)},
+		{},
+		{
+			"Energy",
+			"Main Meter",
+			"Address",
+			"Sub Meter",
+			"Sub Meter Identification",
+			"Begin Date",
+			"End Date",
+			"Energy Consumption",
+			"Consumed Energy Price",
+			"Service Price",
+			"Cost Item Price",
+			"Advance Price",
+			"Total Price",
+		},
+	}
+	for _, smBilling := range subMeterBillings {
+		var servicePrice string
+		if smBilling.ServicePrice.Valid {
+			servicePrice = formatPrice(smBilling.ServicePrice.Float64)
+		}
+		records = append(records, []string{
+			string(smBilling.Energy),
+			smBilling.MainMeterID,
+			smBilling.Address,
+			strconv.Itoa(int(smBilling.SubMeterSubid)),
+			smBilling.SubMeterID.String,
+			formatDate(smBilling.BeginDate),
+			formatDate(smBilling.EndDate),
+			formatConsumption(smBilling.EnergyConsumption),
+			formatPrice(smBilling.ConsumedEnergyPrice),
+			servicePrice,
+			formatPrice(smBilling.CostItemPrice),
+			formatPrice(smBilling.AdvancePrice),
+			formatPrice(smBilling.TotalPrice),
+		})
+	}
+	records = append(
+		records,
+		[]string{},
+		[]string{"Total Price", formatPrice(statement.TotalPrice)},
+		[]string{"Advance Price", formatPrice(statement.AdvancePrice)},
+		[]string{"Balance", formatPrice(statement.Balance)},
+	)
+	if err := csvWriter.WriteAll(records); err != nil {
+		return fmt.Errorf("could not write statement: %w", err)
+	}
+	return nil
+}
+
+func subMeterStatementFilename(billingSubid, subMeterSubid int32) string {
+	return fmt.Sprintf("billing-%d-sub-meter-%d.pdf", billingSubid, subMeterSubid)
+}
+
+// Bank account is nil if the user has not set it.
+func (s *Server) getBankAccount(
+	ctx context.Context, userID int32,
+) (*spinusdb.BankAccount, error) {
+	bankAccount, err := s.queries.GetBankAccount(ctx, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get bank account: %w", err)
+	}
+	return &bankAccount, nil
+}
+
+// Landlord profile is nil if the user has not set it.
+func (s *Server) getLandlordProfile(
+	ctx context.Context, userID int32,
+) (*spinusdb.LandlordProfile, error) {
+	landlordProfile, err := s.queries.GetLandlordProfile(ctx, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get landlord profile: %w", err)
+	}
+	return &landlordProfile, nil
+}
+
+// Statement issuer holds landlord settings used on statements.
+type statementIssuer struct {
+	landlordProfile *spinusdb.LandlordProfile
+	bankAccount     *spinusdb.BankAccount
+}
+
+func (s *Server) getIssuer(ctx context.Context, userID int32) (statementIssuer, error) {
+	var i statementIssuer
+	var err error
+	if i.landlordProfile, err = s.getLandlordProfile(ctx, userID); err != nil {
+		return i, err
+	}
+	if i.bankAccount, err = s.getBankAccount(ctx, userID); err != nil {
+		return i, err
+	}
+	return i, nil
+}
+
+func (i statementIssuer) party(mainMeter spinusdb.GetMainMeterRow) pdf.Party {
+	party := pdf.Party{Email: mainMeter.Email, Address: mainMeter.Address}
+	if profile := i.landlordProfile; profile != nil {
+		party.Name = profile.Name
+		party.CompanyID = profile.CompanyID.String
+		party.VATID = profile.VatID.String
+		party.Address = profile.Address
+	}
+	if i.bankAccount != nil {
+		party.BankAccount = i.bankAccount.Iban
+	}
+	return party
+}
+
+// Variable symbol consists of at most 10 digits, so the document number is
+// used only if its digits fit.
+func variableSymbol(subMeterBilling spinusdb.ListSubMeterBillingsRow) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, subMeterBilling.Number.String)
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" || len(digits) > 10 {
+		return strconv.Itoa(int(subMeterBilling.ID))
+	}
+	return digits
+}
+
+// Payment is created only for positive balance of the sub meter billing.
+func newSubMeterBillingPayment(
+	bankAccount *spinusdb.BankAccount,
+	mainMeterBilling spinusdb.GetMainMeterBillingRow,
+	subMeterBilling spinusdb.ListSubMeterBillingsRow,
+) (spayd.Payment, bool) {
+	balance := subMeterBilling.TotalPrice - subMeterBilling.AdvancePrice
+	if bankAccount == nil || balance <= 0 {
+		return spayd.Payment{}, false
+	}
+	return spayd.Payment{
+		IBAN:           bankAccount.Iban,
+		BIC:            bankAccount.Bic.String,
+		Amount:         balance,
+		Currency:       paymentCurrency,
+		VariableSymbol: variableSymbol(subMeterBilling),
+		Message: fmt.Sprintf(
+			"Billing %d Sub Meter %d",
+			mainMeterBilling.Subid, subMeterBilling.SubMeterSubid,
+		),
+	}, true
+}
+
+func newPaymentTmplData(payment spayd.Payment) (*PaymentTmplData, error) {
+	qrCode, err := payment.QRCode(paymentQRCodeSize)
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentTmplData{
+		Payment: payment,
+		QRCode: template.URL(
+			"data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCode)),
+	}, nil
+}
+
+func (s *Server) newSubMeterStatement(
+	ctx context.Context,
+	mainMeter spinusdb.GetMainMeterRow,
+	mainMeterBilling spinusdb.GetMainMeterBillingRow,
+	subMeterBilling spinusdb.ListSubMeterBillingsRow,
+	costItems []spinusdb.ListSubMeterBillingCostItemsRow,
+	issuer statementIssuer,
+) (pdf.SubMeterStatement, error) {
+	beginTime := mainMeterBilling.BeginDate.Time
+	endTime := mainMeterBilling.EndDate.Time
+	statement := pdf.SubMeterStatement{
+		Title: fmt.Sprintf(
+			"Billing %d - Sub Meter %d",
+			mainMeterBilling.Subid, subMeterBilling.SubMeterSubid,
+		),
+		Number:              subMeterBilling.Number.String,
+		Landlord:            issuer.party(mainMeter),
+		Tenant:              pdf.Party{Email: subMeterBilling.Email},
+		Energy:              string(mainMeter.Energy),
+		MainMeterID:         mainMeter.MeterID,
+		SubMeterID:          subMeterBilling.MeterID.String,
+		BeginDate:           beginTime,
+		EndDate:             endTime,
+		EnergyConsumption:   subMeterBilling.EnergyConsumption,
+		ConsumedEnergyPrice: subMeterBilling.ConsumedEnergyPrice,
+		ServicePrice:        subMeterBilling.ServicePrice.Float64,
+		CostItemPrice:       subMeterBilling.CostItemPrice,
+		AdvancePrice:        subMeterBilling.AdvancePrice,
+		TotalPrice:          subMeterBilling.TotalPrice,
+	}
+
+	billingPeriods, err := s.queries.ListSubMeterBillingPeriods(ctx, subMeterBilling.ID)
+	if err != nil {
+		return statement, fmt.Errorf("could not list sub meter billing periods: %w", err)
+	}
+	for _, billingPeriod := range billingPeriods {
+		statement.Periods = append(statement.Periods, pdf.Period{
+			BeginDate:           billingPeriod.BeginDate.Time,
+			EndDate:             billingPeriod.EndDate.Time,
+			EnergyConsumption:   billingPeriod.EnergyConsumption,
+			ConsumedEnergyPrice: billingPeriod.ConsumedEnergyPrice,
+			ServicePrice:        billingPeriod.ServicePrice.Float64,
+			TotalPrice:          billingPeriod.TotalPrice,
+		})
+	}
+
+	// Readings which could have been used for break points of the billing.
+	dayDiff := int(mainMeterBilling.MaxDayDiff)
+	readings, err := s.queries.ListSubMeterReadingsBetween(
+		ctx,
+		spinusdb.ListSubMeterReadingsBetweenParams{
+			FkSubMeter: subMeterBilling.FkSubMeter,
+			DateMin: pgtype.Date{
+				Time: beginTime.AddDate(0, 0, -dayDiff-1), Valid: true},
+			DateMax: pgtype.Date{Time: endTime.AddDate(0, 0, dayDiff), Valid: true},
+		},
+	)
+	if err != nil {
+		return statement, fmt.Errorf("could not list sub meter readings: %w", err)
+	}
+	for _, reading := range readings {
+		statement.Readings = append(statement.Readings, pdf.Reading{
+			Date:      reading.ReadingDate.Time,
+			Value:     reading.ReadingValue,
+			Estimated: reading.Estimated,
+		})
+	}
+
+	for _, costItem := range costItems {
+		if costItem.FkSubBilling != subMeterBilling.ID {
+			continue
+		}
+		statement.CostItems = append(statement.CostItems, pdf.CostItem{
+			Name:          costItem.Name,
+			AllocationKey: string(costItem.AllocationKey),
+			Share:         costItem.Share,
+			Price:         costItem.Price,
+		})
+	}
+
+	if payment, ok := newSubMeterBillingPayment(
+		issuer.bankAccount, mainMeterBilling, subMeterBilling); ok {
+		qrCode, err := payment.QRCode(paymentQRCodeSize)
+		if err != nil {
+			return statement, err
+		}
+		statement.Payment = &pdf.Payment{
+			IBAN:           payment.IBAN,
+			VariableSymbol: payment.VariableSymbol,
+			Amount:         payment.Amount,
+			QRCode:         qrCode,
+		}
+	}
+
+	return statement, nil
+}
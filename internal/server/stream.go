@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+)
+
+// Streaming variants of the ListMainMeters, ListSubMeterReadings and
+// ListMainMeterReadings queries, which sqlc does not generate. Rows are passed
+// to the callback as they are read, so large exports are written without
+// holding all rows in memory. Columns are matched to the sqlc models by name,
+// so a column missing from the model fails the export instead of being scanned
+// into a wrong field.
+
+const streamMainMetersSQL = `
+SELECT * FROM main_meter
+WHERE fk_user = $1
+ORDER BY id
+`
+
+const streamSubMeterReadingsSQL = `
+SELECT * FROM sub_meter_reading
+WHERE fk_sub_meter = $1
+ORDER BY reading_date DESC
+`
+
+const streamMainMeterReadingsSQL = `
+SELECT
+	sub_meter.subid AS sub_meter_subid,
+	sub_meter.meter_id,
+	sub_meter_reading.subid,
+	sub_meter_reading.reading_value,
+	sub_meter_reading.reading_date,
+	sub_meter_reading.source,
+	sub_meter_reading.estimated
+FROM sub_meter_reading
+JOIN sub_meter
+	ON sub_meter_reading.fk_sub_meter = sub_meter.id
+WHERE sub_meter.fk_main_meter = $1
+ORDER BY sub_meter.subid, sub_meter_reading.reading_date
+`
+
+// streamRows calls fn with every row of the query scanned into T by column
+// names.
+func streamRows[T any](
+	ctx context.Context, db spinusdb.DBTX, sql string, arg any, fn func(T) error,
+) error {
+	rows, err := db.Query(ctx, sql, arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := pgx.RowToStructByName[T](rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Server) streamMainMeters(
+	ctx context.Context, fkUser int32, fn func(spinusdb.MainMeter) error,
+) error {
+	return streamRows(ctx, s.postgresClient, streamMainMetersSQL, fkUser, fn)
+}
+
+func (s *Server) streamSubMeterReadings(
+	ctx context.Context, fkSubMeter int32, fn func(spinusdb.SubMeterReading) error,
+) error {
+	return streamRows(ctx, s.postgresClient, streamSubMeterReadingsSQL, fkSubMeter, fn)
+}
+
+func (s *Server) streamMainMeterReadings(
+	ctx context.Context,
+	fkMainMeter int32,
+	fn func(spinusdb.ListMainMeterReadingsRow) error,
+) error {
+	return streamRows(ctx, s.postgresClient, streamMainMeterReadingsSQL, fkMainMeter, fn)
+}
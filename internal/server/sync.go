@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+)
+
+const (
+	maxSyncBatchSize   = 500
+	maxSyncClientIDLen = 64
+)
+
+const (
+	SyncStatusApplied   = "applied"
+	SyncStatusDuplicate = "duplicate"
+	SyncStatusConflict  = "conflict"
+	SyncStatusRejected  = "rejected"
+)
+
+// SyncResolutionOverwrite replaces reading of the same date with a different
+// value, which was returned as conflict before.
+const SyncResolutionOverwrite = "overwrite"
+
+// SyncReading is a reading recorded offline. Client ID is generated by the
+// client and makes sending the reading again harmless.
+type SyncReading struct {
+	ClientID    string      `json:"client_id"`
+	MainMeterID int32       `json:"main_meter_id"`
+	SubMeterID  int32       `json:"sub_meter_id"`
+	RecordedAt  string      `json:"recorded_at"`
+	Value       json.Number `json:"value"`
+	Estimated   bool        `json:"estimated"`
+	Resolution  string      `json:"resolution"`
+}
+
+type SyncRequest struct {
+	Readings []SyncReading `json:"readings"`
+}
+
+type SyncServerReading struct {
+	ID          int32   `json:"id"`
+	ReadingDate string  `json:"reading_date"`
+	Value       float64 `json:"value"`
+	Source      string  `json:"source"`
+	Estimated   bool    `json:"estimated"`
+}
+
+type SyncResult struct {
+	ClientID string   `json:"client_id"`
+	Status   string   `json:"status"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Reading is the stored reading, or the reading in conflict.
+	Reading *SyncServerReading `json:"reading,omitempty"`
+}
+
+type SyncResponse struct {
+	Results []SyncResult `json:"results"`
+}
+
+func newSyncServerReading(reading spinusdb.SubMeterReading) *SyncServerReading {
+	return &SyncServerReading{
+		ID:          reading.Subid,
+		ReadingDate: reading.ReadingDate.Time.Format(time.DateOnly),
+		Value:       reading.ReadingValue,
+		Source:      string(reading.Source),
+		Estimated:   reading.Estimated,
+	}
+}
+
+func parseSyncRequest(r *http.Request) ([]SyncReading, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	var request SyncRequest
+	if err := decoder.Decode(&request); err != nil {
+		return nil, errors.New("Send valid JSON.")
+	}
+	switch {
+	case len(request.Readings) == 0:
+		return nil, errors.New("Send readings.")
+	case len(request.Readings) > maxSyncBatchSize:
+		return nil, fmt.Errorf("Send no more than %d readings at once.", maxSyncBatchSize)
+	}
+	return request.Readings, nil
+}
+
+type syncedReading struct {
+	index int
+	time  time.Time
+	value float64
+}
+
+// syncSubMeter caches sub meter with its readings sorted by date descending.
+type syncSubMeter struct {
+	id       int32
+	readings []spinusdb.SubMeterReading
+}
+
+// syncReadings applies readings recorded offline by the user. Each reading is
+// applied at most once, readings failing validation or in conflict with a
+// reading of the same date are reported without failing the others.
+func (s *Server) syncReadings(
+	ctx context.Context, qtx *spinusdb.Queries, userID int32, readings []SyncReading,
+) ([]SyncResult, error) {
+	results := make([]SyncResult, len(readings))
+	var valid []syncedReading
+	clientIDs := make(map[string]bool, len(readings))
+	for i, reading := range readings {
+		result := &results[i]
+		result.ClientID = reading.ClientID
+		if clientIDs[reading.ClientID] {
+			result.Status = SyncStatusRejected
+			result.Error = "Client ID is repeated in the batch."
+			continue
+		}
+		clientIDs[reading.ClientID] = true
+		t, value, err := parseSyncReading(reading)
+		if err != nil {
+			result.Status, result.Error = SyncStatusRejected, err.Error()
+			continue
+		}
+		valid = append(valid, syncedReading{index: i, time: t, value: value})
+	}
+	// Readings are applied in the order they were recorded.
+	slices.SortStableFunc(valid, func(a, b syncedReading) int {
+		return a.time.Compare(b.time)
+	})
+
+	fkUser := pgtype.Int4{Int32: userID, Valid: true}
+	subMeters := make(map[[2]int32]*syncSubMeter)
+	for _, synced := range valid {
+		reading := readings[synced.index]
+		result := &results[synced.index]
+		clientID := pgtype.Text{String: reading.ClientID, Valid: true}
+		clientReading, err := qtx.GetClientReading(
+			ctx, spinusdb.GetClientReadingParams{FkUser: fkUser, ClientID: clientID})
+		if err == nil {
+			result.Status = SyncStatusDuplicate
+			result.Reading = newSyncServerReading(clientReading)
+			continue
+		} else if err != pgx.ErrNoRows {
+			return nil, fmt.Errorf("could not get client reading: %w", err)
+		}
+
+		key := [2]int32{reading.MainMeterID, reading.SubMeterID}
+		subMeter, ok := subMeters[key]
+		if !ok {
+			subMeter, err = s.getSyncSubMeter(ctx, qtx, userID, key[0], key[1])
+			if err != nil {
+				return nil, err
+			}
+			subMeters[key] = subMeter
+		}
+		if subMeter == nil {
+			result.Status, result.Error = SyncStatusRejected, "Unknown sub meter."
+			continue
+		}
+
+		// Reading date is the date where the reading was recorded.
+		readingDate := pgtype.Date{
+			Time: time.Date(
+				synced.time.Year(), synced.time.Month(), synced.time.Day(),
+				0, 0, 0, 0, time.UTC),
+			Valid: true,
+		}
+		i := slices.IndexFunc(subMeter.readings, func(r spinusdb.SubMeterReading) bool {
+			return r.ReadingDate.Time.Equal(readingDate.Time)
+		})
+		otherReadings := subMeter.readings
+		if i != -1 {
+			dateReading := subMeter.readings[i]
+			if dateReading.ReadingValue == synced.value {
+				result.Status = SyncStatusDuplicate
+				result.Reading = newSyncServerReading(dateReading)
+				continue
+			}
+			if reading.Resolution != SyncResolutionOverwrite {
+				result.Status = SyncStatusConflict
+				result.Reading = newSyncServerReading(dateReading)
+				continue
+			}
+			otherReadings = slices.Delete(slices.Clone(otherReadings), i, i+1)
+		}
+		check := checkNewReading(otherReadings, synced.value, readingDate.Time)
+		if check.Error != "" {
+			result.Status, result.Error = SyncStatusRejected, check.Error
+			continue
+		}
+
+		readingTime := pgtype.Timestamptz{Time: synced.time, Valid: true}
+		var subMeterReading spinusdb.SubMeterReading
+		if i != -1 {
+			subMeterReading, err = qtx.UpdateSubMeterReadingValue(
+				ctx,
+				spinusdb.UpdateSubMeterReadingValueParams{
+					ID:           subMeter.readings[i].ID,
+					ReadingValue: synced.value,
+					Source:       spinusdb.ReadingSourceManual,
+					FkUser:       fkUser,
+					Estimated:    reading.Estimated,
+					ReadingTime:  readingTime,
+					ClientID:     clientID,
+				},
+			)
+			if err != nil {
+				return nil, fmt.Errorf("could not update sub meter reading: %w", err)
+			}
+			subMeter.readings[i] = subMeterReading
+		} else {
+			subMeterReading, err = qtx.CreateSubMeterReading(
+				ctx,
+				spinusdb.CreateSubMeterReadingParams{
+					FkSubMeter:   subMeter.id,
+					ReadingValue: synced.value,
+					ReadingDate:  readingDate,
+					Source:       spinusdb.ReadingSourceManual,
+					FkUser:       fkUser,
+					Estimated:    reading.Estimated,
+					ReadingTime:  readingTime,
+					ClientID:     clientID,
+				},
+			)
+			if err != nil {
+				return nil, fmt.Errorf("could not create sub meter reading: %w", err)
+			}
+			subMeter.readings = insertSubMeterReading(subMeter.readings, subMeterReading)
+		}
+		result.Status, result.Warnings = SyncStatusApplied, check.Warnings
+		result.Reading = newSyncServerReading(subMeterReading)
+	}
+	return results, nil
+}
+
+func parseSyncReading(reading SyncReading) (time.Time, float64, error) {
+	switch {
+	case reading.ClientID == "":
+		return time.Time{}, 0, errors.New("Send client ID.")
+	case len(reading.ClientID) > maxSyncClientIDLen:
+		return time.Time{}, 0, fmt.Errorf(
+			"Send client ID with maximum of %d characters.", maxSyncClientIDLen)
+	case reading.Resolution != "" && reading.Resolution != SyncResolutionOverwrite:
+		return time.Time{}, 0, errors.New("Send valid resolution.")
+	}
+	t, err := parseIngestTimestamp(reading.RecordedAt)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	value, err := parseReadingValue(reading.Value.String())
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return t, float64(value), nil
+}
+
+// getSyncSubMeter returns nil if the sub meter does not exist or the user
+// cannot access it.
+func (s *Server) getSyncSubMeter(
+	ctx context.Context, qtx *spinusdb.Queries, userID, mainMeterID, subid int32,
+) (*syncSubMeter, error) {
+	subMeter, err := qtx.GetSubMeter(
+		ctx, spinusdb.GetSubMeterParams{FkMainMeter: mainMeterID, Subid: subid})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get sub meter: %w", err)
+	}
+	if !canAccessSubMeter(userID, subMeter) {
+		return nil, nil
+	}
+	readings, err := qtx.ListSubMeterReadings(ctx, subMeter.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not list sub meter readings: %w", err)
+	}
+	return &syncSubMeter{id: subMeter.ID, readings: readings}, nil
+}
+
+// insertSubMeterReading keeps readings sorted by date descending.
+func insertSubMeterReading(
+	readings []spinusdb.SubMeterReading, reading spinusdb.SubMeterReading,
+) []spinusdb.SubMeterReading {
+	i := slices.IndexFunc(readings, func(r spinusdb.SubMeterReading) bool {
+		return r.ReadingDate.Time.Before(reading.ReadingDate.Time)
+	})
+	if i == -1 {
+		i = len(readings)
+	}
+	return slices.Insert(readings, i, reading)
+}
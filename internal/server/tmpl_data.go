@@ -1,6 +1,61 @@
 package server
 
-import spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+import (
+	"html/template"
+
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/spayd"
+)
+
+// PasswordForgotTmplData is the same whether the account with the email exists
+// or not.
+type PasswordForgotTmplData struct {
+	PasswordForgotFormData
+	Sent bool
+}
+
+type PasswordResetTmplData struct {
+	PasswordResetFormData
+	InvalidToken bool
+	Done         bool
+}
+
+type EmailVerificationTmplData struct {
+	Email        string
+	Verified     bool
+	Sent         bool
+	GeneralError string
+}
+
+type SignUpSentTmplData struct {
+	Email string
+}
+
+type EmailVerifyTmplData struct {
+	InvalidToken bool
+}
+
+type TwoFactorTmplData struct {
+	TwoFactorFormData
+	Enabled           bool
+	RecoveryCodesLeft int64
+}
+
+// TwoFactorSetupTmplData shows secret for authenticator apps which cannot scan
+// QR codes.
+type TwoFactorSetupTmplData struct {
+	TwoFactorFormData
+	Secret string
+	QRCode template.URL
+}
+
+type TwoFactorRecoveryCodesTmplData struct {
+	RecoveryCodes []string
+}
+
+type PasskeyListTmplData struct {
+	Passkeys []spinusdb.WebauthnCredential
+}
 
 type MainMeterTmplData struct {
 	ID int32
@@ -8,7 +63,8 @@ type MainMeterTmplData struct {
 
 type MainMeterOverviewTmplData struct {
 	spinusdb.GetMainMeterRow
-	Upper MainMeterTmplData
+	ConsumptionShareChart template.HTML
+	Upper                 MainMeterTmplData
 }
 
 type SubMeterListTmplData struct {
@@ -28,24 +84,140 @@ type SubMeterTmplData struct {
 
 type SubMeterOverviewTmplData struct {
 	spinusdb.GetSubMeterRow
-	Upper SubMeterTmplData
+	ReadingChart     template.HTML
+	ConsumptionChart template.HTML
+	Upper            SubMeterTmplData
+}
+
+type SubMeterReadingTmplData struct {
+	spinusdb.ListSubMeterReadingsWithUserRow
+	PhotoIDs []int32
 }
 
 type SubMeterReadingListTmplData struct {
-	SubMeterReadings []spinusdb.SubMeterReading
+	GeneralError     string
+	SubMeterReadings []SubMeterReadingTmplData
 	Upper            SubMeterTmplData
 }
 
 type SubMeterReadingCreateTmplData struct {
 	SubMeterReadingFormData
+	Warnings []string
+	Upper    SubMeterTmplData
+}
+
+type SubMeterReadingImportTmplData struct {
+	GeneralError   string
+	CSV            string
+	Rows           []*ReadingImportRow
+	ValidRowsCount int
+	Upper          MainMeterTmplData
+}
+
+type WmbusKeyListTmplData struct {
+	GeneralError string
+	Keys         []*WmbusKeyFormData
+	Upper        MainMeterTmplData
+}
+
+// WmbusImportTmplData has counts of telegrams which are not turned into rows.
+type WmbusImportTmplData struct {
+	GeneralError   string
+	Log            string
+	Rows           []*ReadingImportRow
+	ValidRowsCount int
+	Stats          WmbusLogStats
+	Upper          MainMeterTmplData
+}
+
+type IntervalDayTmplData struct {
+	spinusdb.ListSubMeterIntervalDaysRow
+	Complete bool
+}
+
+type SubMeterIntervalListTmplData struct {
+	Days  []IntervalDayTmplData
 	Upper SubMeterTmplData
 }
 
-type MainMeterBillingListTmplData struct {
+type DeviceListTmplData struct {
+	DeviceFormData
+	Devices []spinusdb.Device
+	// Token of created device is shown only once.
+	Token string
+	Upper SubMeterTmplData
+}
+
+type SubMeterIntervalImportTmplData struct {
+	IntervalImportFormData
+	CSV    string
+	Import *IntervalImport
+	Upper  SubMeterTmplData
+}
+
+// ReadingAnomalyTmplData is a suspicious reading of a sub meter.
+type ReadingAnomalyTmplData struct {
+	spinusdb.ListMainMeterReadingsRow
+	Error    string
+	Warnings []string
+}
+
+type ReadingAnomalyListTmplData struct {
+	Anomalies []ReadingAnomalyTmplData
+	Upper     MainMeterTmplData
+}
+
+type ReadingRoundCreateTmplData struct {
+	ReadingRoundFormData
 	Upper MainMeterTmplData
 }
 
+type MainMeterBillingListTmplData struct {
+	MainMeterBillings []spinusdb.MainMeterBilling
+	Upper             MainMeterTmplData
+}
+
 type MainMeterBillingCreateTmplData struct {
 	MainMeterBillingFormData
-	Upper MainMeterTmplData
+	ImportWarnings []string
+	Upper          MainMeterTmplData
+}
+
+type MainMeterBillingTmplData struct {
+	MainMeterID int32
+	Subid       int32
+}
+
+type SubMeterBillingTmplData struct {
+	spinusdb.ListSubMeterBillingsRow
+	CostItems []spinusdb.ListSubMeterBillingCostItemsRow
+	Payment   *PaymentTmplData
+}
+
+type PaymentTmplData struct {
+	spayd.Payment
+	QRCode template.URL
+}
+
+type MainMeterBillingOverviewTmplData struct {
+	spinusdb.GetMainMeterBillingRow
+	BillingPeriods   []spinusdb.MainMeterBillingPeriod
+	CostItems        []spinusdb.MainMeterBillingCostItem
+	SubMeterBillings []SubMeterBillingTmplData
+	Upper            MainMeterBillingTmplData
+}
+
+type StatementCreateTmplData struct {
+	StatementFormData
+	Tenants []spinusdb.ListStatementTenantsRow
+}
+
+type StatementTmplData struct {
+	Subid int32
+}
+
+type StatementOverviewTmplData struct {
+	spinusdb.GetStatementRow
+	Sections []*StatementSection
+	Upper    StatementTmplData
 }
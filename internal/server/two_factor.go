@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/totp"
+)
+
+const (
+	twoFactorIssuer      = "Spinus"
+	twoFactorQRCodeSize  = 256
+	recoveryCodeCount    = 10
+	recoveryCodeSize     = 10
+	twoFactorLoginTTL    = 5 * time.Minute
+	maxTwoFactorAttempts = 5
+)
+
+// Between the password and the code, the user is kept in the session under
+// other keys than userIDKey, so the user is not logged in yet.
+const (
+	twoFactorUserIDKey    = "twoFactorUserID"
+	twoFactorUsernameKey  = "twoFactorUsername"
+	twoFactorExpiresAtKey = "twoFactorExpiresAt"
+	twoFactorAttemptsKey  = "twoFactorAttempts"
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func (s *Server) newTwoFactorSetupTmplData(
+	user spinusdb.SpinusUser, secret []byte,
+) (TwoFactorSetupTmplData, error) {
+	key := totp.Key{Secret: secret, Issuer: twoFactorIssuer, Account: user.Username}
+	qrCode, err := key.QRCode(twoFactorQRCodeSize)
+	if err != nil {
+		return TwoFactorSetupTmplData{}, err
+	}
+	return TwoFactorSetupTmplData{
+		Secret: key.EncodedSecret(),
+		QRCode: template.URL(
+			"data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCode)),
+	}, nil
+}
+
+func (s *Server) newTwoFactorTmplData(
+	ctx context.Context, userID int32,
+) (TwoFactorTmplData, error) {
+	var tmplData TwoFactorTmplData
+	userTotp, err := s.queries.GetUserTotp(ctx, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return tmplData, nil
+		}
+		return tmplData, fmt.Errorf("could not get TOTP: %w", err)
+	}
+	if !userTotp.EnabledAt.Valid {
+		return tmplData, nil
+	}
+	tmplData.Enabled = true
+	tmplData.RecoveryCodesLeft, err = s.queries.GetUnusedUserRecoveryCodeCount(ctx, userID)
+	if err != nil {
+		return tmplData, fmt.Errorf("could not count recovery codes: %w", err)
+	}
+	return tmplData, nil
+}
+
+func (s *Server) removeTwoFactorLogIn(ctx context.Context) {
+	s.sessionManager.Remove(ctx, twoFactorUserIDKey)
+	s.sessionManager.Remove(ctx, twoFactorUsernameKey)
+	s.sessionManager.Remove(ctx, twoFactorExpiresAtKey)
+	s.sessionManager.Remove(ctx, twoFactorAttemptsKey)
+}
+
+// replaceRecoveryCodes stores hashes of new recovery codes, which are shown to
+// the user only once.
+func replaceRecoveryCodes(
+	ctx context.Context, qtx *spinusdb.Queries, userID int32,
+) ([]string, error) {
+	if err := qtx.DeleteUserRecoveryCodes(ctx, userID); err != nil {
+		return nil, fmt.Errorf("could not delete recovery codes: %w", err)
+	}
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeSize*5/8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("could not read random bytes: %w", err)
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes[i] = code[:recoveryCodeSize/2] + "-" + code[recoveryCodeSize/2:]
+		err := qtx.CreateUserRecoveryCode(
+			ctx, spinusdb.CreateUserRecoveryCodeParams{FkUser: userID, CodeHash: hashToken(code)})
+		if err != nil {
+			return nil, fmt.Errorf("could not create recovery code: %w", err)
+		}
+	}
+	return codes, nil
+}
+
+// checkTwoFactorCode accepts each code of authenticator app and each recovery
+// code only once.
+func (s *Server) checkTwoFactorCode(
+	ctx context.Context, userTotp spinusdb.UserTotp, code TwoFactorCode,
+) (bool, error) {
+	if isTotpCode(code) {
+		counter, ok := totp.Validate(userTotp.Secret, string(code), time.Now())
+		if !ok {
+			return false, nil
+		}
+		n, err := s.queries.UpdateUserTotpCounter(
+			ctx,
+			spinusdb.UpdateUserTotpCounterParams{
+				FkUser: userTotp.FkUser, LastCounter: counter},
+		)
+		if err != nil {
+			return false, fmt.Errorf("could not update TOTP counter: %w", err)
+		}
+		return n == 1, nil
+	}
+	n, err := s.queries.UseUserRecoveryCode(
+		ctx,
+		spinusdb.UseUserRecoveryCodeParams{
+			FkUser: userTotp.FkUser, CodeHash: hashToken(string(code))},
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not use recovery code: %w", err)
+	}
+	return n == 1, nil
+}
+
+// isTotpCode reports whether the code is from authenticator app, other codes
+// are recovery codes.
+func isTotpCode(code TwoFactorCode) bool {
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	spinusdb "github.com/svoboond/spinus/internal/db/sqlc"
+	"github.com/svoboond/spinus/internal/wmbus"
+)
+
+// Receiver logs, like of rtl-wmbus or wmbusmeters, have a telegram in
+// hexadecimal and time it was received on each line.
+var (
+	telegramHexRegexp  = regexp.MustCompile(`(?i)\b(?:0x)?([0-9a-f]{24,})\b`)
+	telegramTimeRegexp = regexp.MustCompile(
+		`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?`)
+	telegramTimeLayouts = []string{
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02T15:04:05Z0700",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04Z0700",
+		"2006-01-02T15:04",
+	}
+)
+
+// WmbusLogStats counts telegrams of the log, receivers also log telegrams of
+// neighbours' meters.
+type WmbusLogStats struct {
+	Telegrams     int
+	Invalid       int
+	UnknownMeters int
+	Repeated      int
+}
+
+type wmbusDay struct {
+	subMeterID int32
+	date       string
+}
+
+// parseWmbusLog turns the first telegram of each sub meter and day into a row
+// of imported readings. Sub meter identification is the meter serial number.
+func parseWmbusLog(
+	s string, subMeters []spinusdb.ListSubMetersRow, keys map[int32][]byte,
+	energy spinusdb.Energy,
+) ([]*ReadingImportRow, WmbusLogStats) {
+	var rows []*ReadingImportRow
+	var stats WmbusLogStats
+	days := make(map[wmbusDay]*ReadingImportRow)
+	for i, line := range strings.Split(s, "\n") {
+		var telegramHex string
+		for _, match := range telegramHexRegexp.FindAllStringSubmatch(line, -1) {
+			if len(match[1]) > len(telegramHex) {
+				telegramHex = match[1]
+			}
+		}
+		if telegramHex == "" {
+			continue
+		}
+		stats.Telegrams++
+		data, err := hex.DecodeString(telegramHex)
+		if err != nil {
+			stats.Invalid++
+			continue
+		}
+		telegram, err := wmbus.Parse(data)
+		if err != nil {
+			stats.Invalid++
+			continue
+		}
+		var matches []spinusdb.ListSubMetersRow
+		for _, subMeter := range subMeters {
+			if subMeter.MeterID.Valid && matchWmbusMeterID(subMeter.MeterID.String, telegram) {
+				matches = append(matches, subMeter)
+			}
+		}
+		if len(matches) == 0 {
+			stats.UnknownMeters++
+			continue
+		}
+
+		row := &ReadingImportRow{Line: i + 1, SubMeter: telegram.ID}
+		if len(matches) > 1 {
+			row.Errors = append(
+				row.Errors, "More sub meters have the meter identification.")
+			rows = append(rows, row)
+			continue
+		}
+		subMeter := matches[0]
+		row.SubMeter, row.subMeterID = subMeter.MeterID.String, subMeter.ID
+		if t, err := parseTelegramTime(line); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		} else {
+			row.ReadingDate = t.Format(time.DateOnly)
+			row.readingTime = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		if value, err := wmbusReadingValue(telegram, keys[subMeter.ID], energy); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		} else {
+			row.ReadingValue = strconv.FormatFloat(value, 'f', -1, 64)
+			row.readingValue = value
+		}
+
+		if row.ReadingDate != "" {
+			day := wmbusDay{subMeterID: subMeter.ID, date: row.ReadingDate}
+			if dayRow, ok := days[day]; ok {
+				stats.Repeated++
+				// Invalid telegram is replaced by a later valid one.
+				if !dayRow.Valid() && row.Valid() {
+					*dayRow = *row
+				}
+				continue
+			}
+			days[day] = row
+		}
+		rows = append(rows, row)
+	}
+	return rows, stats
+}
+
+// Leading zeros and manufacturer code of sub meter identification are
+// optional.
+func matchWmbusMeterID(meterID string, telegram *wmbus.Telegram) bool {
+	meterID = strings.ToUpper(strings.TrimSpace(meterID))
+	meterID = strings.TrimLeft(strings.TrimPrefix(meterID, telegram.Manufacturer), "0")
+	return meterID != "" && meterID == strings.TrimLeft(telegram.ID, "0")
+}
+
+func parseTelegramTime(line string) (time.Time, error) {
+	s := telegramTimeRegexp.FindString(line)
+	s = strings.Replace(s, " ", "T", 1)
+	for _, layout := range telegramTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("Log line has no valid time.")
+}
+
+// wmbusReadingValue reads current volume of gas and water meters or energy of
+// electricity and heat meters.
+func wmbusReadingValue(
+	telegram *wmbus.Telegram, key []byte, energy spinusdb.Energy,
+) (float64, error) {
+	err := telegram.Decrypt(key)
+	switch {
+	case errors.Is(err, wmbus.ErrNoKey):
+		return 0, errors.New("Enter wM-Bus key of the sub meter.")
+	case errors.Is(err, wmbus.ErrWrongKey):
+		return 0, errors.New("Telegram cannot be decrypted with wM-Bus key of the sub meter.")
+	case err != nil:
+		return 0, fmt.Errorf("Telegram is not valid, %s.", err)
+	}
+	quantity := wmbus.QuantityEnergy
+	if energy == spinusdb.EnergyGas || energy == spinusdb.EnergyWater {
+		quantity = wmbus.QuantityVolume
+	}
+	record, ok := telegram.Current(quantity)
+	if !ok {
+		return 0, fmt.Errorf("Telegram has no current %s.", quantity)
+	}
+	if record.Value < 0 {
+		return 0, fmt.Errorf("Telegram has negative %s.", quantity)
+	}
+	return record.Value, nil
+}
+
+// listWmbusKeyFormData returns sub meters of the main meter with a meter
+// identification, as only those can be found in logs.
+func (s *Server) listWmbusKeyFormData(
+	ctx context.Context, mainMeterID int32,
+) ([]*WmbusKeyFormData, error) {
+	subMeters, err := s.queries.ListSubMeters(ctx, mainMeterID)
+	if err != nil {
+		return nil, fmt.Errorf("could not list sub meters: %w", err)
+	}
+	wmbusKeys, err := s.queries.ListSubMeterWmbusKeys(ctx, mainMeterID)
+	if err != nil {
+		return nil, fmt.Errorf("could not list wM-Bus keys: %w", err)
+	}
+	var keys []*WmbusKeyFormData
+	for _, subMeter := range subMeters {
+		if !subMeter.MeterID.Valid {
+			continue
+		}
+		keys = append(keys, &WmbusKeyFormData{
+			SubMeterID: subMeter.ID,
+			Subid:      subMeter.Subid,
+			MeterID:    subMeter.MeterID.String,
+			KeySet: slices.ContainsFunc(wmbusKeys, func(key spinusdb.SubMeterWmbusKey) bool {
+				return key.FkSubMeter == subMeter.ID
+			}),
+		})
+	}
+	return keys, nil
+}
@@ -0,0 +1,61 @@
+// Package spayd encodes payments in the Short Payment Descriptor format used
+// for QR payments by Czech banks.
+package spayd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	version          = "1.0"
+	maxMessageLength = 60
+)
+
+type Payment struct {
+	IBAN           string
+	BIC            string
+	Amount         float64
+	Currency       string
+	VariableSymbol string
+	Message        string
+}
+
+// Asterisks separate fields, so they have to be escaped inside values.
+var replacer = strings.NewReplacer("%", "%25", "*", "%2A")
+
+func (p Payment) String() string {
+	var b strings.Builder
+	b.WriteString("SPD*" + version)
+	b.WriteString("*ACC:" + replacer.Replace(p.IBAN))
+	if p.BIC != "" {
+		b.WriteString("+" + replacer.Replace(p.BIC))
+	}
+	b.WriteString("*AM:" + strconv.FormatFloat(p.Amount, 'f', 2, 64))
+	if p.Currency != "" {
+		b.WriteString("*CC:" + replacer.Replace(p.Currency))
+	}
+	if p.VariableSymbol != "" {
+		b.WriteString("*X-VS:" + replacer.Replace(p.VariableSymbol))
+	}
+	if p.Message != "" {
+		message := []rune(p.Message)
+		if len(message) > maxMessageLength {
+			message = message[:maxMessageLength]
+		}
+		b.WriteString("*MSG:" + replacer.Replace(string(message)))
+	}
+	return b.String()
+}
+
+// QRCode returns PNG image of the payment with given size in pixels.
+func (p Payment) QRCode(size int) ([]byte, error) {
+	png, err := qrcode.Encode(p.String(), qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode payment: %w", err)
+	}
+	return png, nil
+}
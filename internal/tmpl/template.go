@@ -12,7 +12,9 @@ type Template struct {
 }
 
 func NewTemplateRenderer(fs embed.FS, patterns ...string) (Template, error) {
-	funcMap := template.FuncMap{}
+	funcMap := template.FuncMap{
+		"mul": func(a, b float64) float64 { return a * b },
+	}
 
 	t, err := template.New("").Funcs(funcMap).ParseFS(fs, patterns...)
 	if err != nil {
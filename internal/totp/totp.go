@@ -0,0 +1,101 @@
+// Package totp generates and validates time-based one-time passwords of RFC
+// 6238 as used by authenticator apps, with their default parameters: HMAC-SHA1,
+// 6 digits and 30 seconds period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	Digits     = 6
+	Period     = 30 * time.Second
+	modulus    = 1_000_000 // 10^Digits
+	secretSize = 20
+	// Codes of adjacent periods are accepted, as clocks of phones drift.
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateSecret() ([]byte, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("could not read random bytes: %w", err)
+	}
+	return secret, nil
+}
+
+// Key is the secret with the names an authenticator app shows with codes.
+type Key struct {
+	Secret  []byte
+	Issuer  string
+	Account string
+}
+
+// EncodedSecret is entered to authenticator apps which cannot scan QR codes.
+func (k Key) EncodedSecret() string {
+	return encoding.EncodeToString(k.Secret)
+}
+
+func (k Key) URL() string {
+	u := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + k.Issuer + ":" + k.Account,
+		RawQuery: url.Values{
+			"secret": {k.EncodedSecret()},
+			"issuer": {k.Issuer},
+		}.Encode(),
+	}
+	return u.String()
+}
+
+// QRCode returns PNG image of the key URL with given size in pixels.
+func (k Key) QRCode(size int) ([]byte, error) {
+	png, err := qrcode.Encode(k.URL(), qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode key: %w", err)
+	}
+	return png, nil
+}
+
+// Counter is the number of the period of time t.
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+func Code(secret []byte, counter int64) string {
+	mac := hmac.New(sha1.New, secret)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%modulus)
+}
+
+// Validate returns counter of the period the code belongs to. Callers store the
+// counter and refuse codes with counter not greater than the stored one, so a
+// code cannot be used twice.
+func Validate(secret []byte, code string, t time.Time) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	counter := Counter(t)
+	for i := int64(-skew); i <= skew; i++ {
+		if subtle.ConstantTimeCompare([]byte(Code(secret, counter+i)), []byte(code)) == 1 {
+			return counter + i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,61 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of RFC 6238 appendix B.
+var rfcSecret = []byte("12345678901234567890")
+
+// Test vectors of RFC 6238 appendix B for SHA1, truncated to 6 digits.
+var rfcTests = []struct {
+	unix    int64
+	counter int64
+	code    string
+}{
+	{59, 0x1, "287082"},
+	{1111111109, 0x23523EC, "081804"},
+	{1111111111, 0x23523ED, "050471"},
+	{1234567890, 0x273EF07, "005924"},
+	{2000000000, 0x3F940AA, "279037"},
+	{20000000000, 0x27BC86AA, "353130"},
+}
+
+func TestCode(t *testing.T) {
+	for _, tt := range rfcTests {
+		counter := Counter(time.Unix(tt.unix, 0))
+		if counter != tt.counter {
+			t.Errorf("Counter(%d) = %X, want %X", tt.unix, counter, tt.counter)
+		}
+		if code := Code(rfcSecret, counter); code != tt.code {
+			t.Errorf("Code(%X) = %s, want %s", counter, code, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range rfcTests {
+		now := time.Unix(tt.unix, 0)
+		tests := []struct {
+			name    string
+			t       time.Time
+			valid   bool
+			counter int64
+		}{
+			{"same period", now, true, tt.counter},
+			{"previous period", now.Add(-Period), true, tt.counter},
+			{"next period", now.Add(Period), true, tt.counter},
+			{"two periods later", now.Add(2 * Period), false, 0},
+		}
+		for _, v := range tests {
+			counter, ok := Validate(rfcSecret, tt.code, v.t)
+			if ok != v.valid || counter != v.counter {
+				t.Errorf("Validate(%s) at %d %s = %X, %t", tt.code, tt.unix, v.name, counter, ok)
+			}
+		}
+	}
+	if _, ok := Validate(rfcSecret, "94287082", time.Unix(59, 0)); ok {
+		t.Error("Validate() accepted 8 digit code")
+	}
+}
@@ -0,0 +1,444 @@
+// Package wmbus decodes wireless M-Bus telegrams of EN 13757-4 with
+// application layer of EN 13757-3, as broadcast by water, heat and other
+// meters.
+package wmbus
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrNoKey    = errors.New("telegram is encrypted")
+	ErrWrongKey = errors.New("telegram cannot be decrypted with the key")
+)
+
+// Security modes of configuration field.
+const (
+	modeNone   = 0
+	modeAESCBC = 5
+)
+
+// Link layer header has length, control field, manufacturer and address.
+const linkHeaderSize = 10
+
+type Quantity string
+
+const (
+	QuantityEnergy Quantity = "energy"
+	QuantityVolume Quantity = "volume"
+)
+
+// Record is a data record with energy in kWh or volume in m3. Records of
+// other quantities are skipped.
+type Record struct {
+	Quantity Quantity
+	Value    float64
+	// Function is 0 for instantaneous value, 1 for maximum, 2 for minimum and
+	// 3 for value during error state.
+	Function      int
+	StorageNumber int
+	Tariff        int
+	Subunit       int
+	// Extended records have value information extensions changing their
+	// meaning, like values of backward flow.
+	Extended bool
+}
+
+type Telegram struct {
+	Manufacturer string
+	// ID is the meter serial number.
+	ID           string
+	Version      byte
+	DeviceType   byte
+	AccessNumber byte
+	Status       byte
+	Records      []Record
+
+	mode      int
+	encrypted int
+	iv        []byte
+	payload   []byte
+}
+
+// Encrypted reports whether Decrypt has to be called before records are read.
+func (t *Telegram) Encrypted() bool { return t.mode != modeNone && t.Records == nil }
+
+// Current returns current value of quantity, instantaneous value of the
+// storage 0 without tariff.
+func (t *Telegram) Current(quantity Quantity) (Record, bool) {
+	for _, record := range t.Records {
+		if record.Quantity == quantity && record.Function == 0 &&
+			record.StorageNumber == 0 && record.Tariff == 0 && record.Subunit == 0 &&
+			!record.Extended {
+
+			return record, true
+		}
+	}
+	return Record{}, false
+}
+
+// Parse parses telegram of frame format A, with or without CRCs already
+// removed by the receiver. Records of telegrams not encrypted are parsed
+// right away.
+func Parse(data []byte) (*Telegram, error) {
+	if len(data) < linkHeaderSize+1 {
+		return nil, errors.New("telegram too short")
+	}
+	length := int(data[0])
+	switch len(data) {
+	case length + 1:
+	case frameSizeWithCRCs(length):
+		var err error
+		data, err = removeCRCs(data)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("telegram has %d bytes, length field is %d", len(data), length)
+	}
+
+	t := &Telegram{}
+	t.readAddress(data[2:10])
+	ci := data[linkHeaderSize]
+	rest := data[linkHeaderSize+1:]
+	switch ci {
+	case 0x78:
+		// No header, no encryption.
+		t.payload = rest
+	case 0x7A:
+		if len(rest) < 4 {
+			return nil, errors.New("telegram too short")
+		}
+		t.readShortHeader(rest[:4])
+		t.iv = newIV(data[2:10], t.AccessNumber)
+		t.payload = rest[4:]
+	case 0x72:
+		if len(rest) < 12 {
+			return nil, errors.New("telegram too short")
+		}
+		// Meter address of the long header replaces address of the link layer,
+		// which is the address of a repeater or converter.
+		address := make([]byte, 8)
+		copy(address[:2], rest[4:6])
+		copy(address[2:6], rest[:4])
+		copy(address[6:], rest[6:8])
+		t.readAddress(address)
+		t.readShortHeader(rest[8:12])
+		t.iv = newIV(address, t.AccessNumber)
+		t.payload = rest[12:]
+	default:
+		return nil, fmt.Errorf("unsupported CI field %02X", ci)
+	}
+
+	switch t.mode {
+	case modeNone:
+		records, err := parseRecords(t.payload)
+		if err != nil {
+			return nil, err
+		}
+		t.Records = records
+	case modeAESCBC:
+	default:
+		return nil, fmt.Errorf("unsupported security mode %d", t.mode)
+	}
+	return t, nil
+}
+
+// Decrypt decrypts telegram with AES-128 key of the meter and parses its
+// records.
+func (t *Telegram) Decrypt(key []byte) error {
+	if !t.Encrypted() {
+		return nil
+	}
+	if len(key) == 0 {
+		return ErrNoKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	size := t.encrypted * aes.BlockSize
+	if size == 0 || size > len(t.payload) {
+		size = len(t.payload) - len(t.payload)%aes.BlockSize
+	}
+	if size == 0 {
+		return errors.New("no encrypted data")
+	}
+	data := make([]byte, len(t.payload))
+	cipher.NewCBCDecrypter(block, t.iv).CryptBlocks(data[:size], t.payload[:size])
+	copy(data[size:], t.payload[size:])
+	// Decrypted data starts with two idle fillers.
+	if data[0] != 0x2F || data[1] != 0x2F {
+		return ErrWrongKey
+	}
+	records, err := parseRecords(data)
+	if err != nil {
+		return err
+	}
+	if records == nil {
+		records = []Record{}
+	}
+	t.Records = records
+	return nil
+}
+
+// readAddress reads manufacturer, identification, version and device type.
+func (t *Telegram) readAddress(address []byte) {
+	m := binary.LittleEndian.Uint16(address[:2])
+	t.Manufacturer = string([]byte{
+		byte(m>>10&0x1F) + 64, byte(m>>5&0x1F) + 64, byte(m&0x1F) + 64})
+	t.ID = fmt.Sprintf("%02X%02X%02X%02X", address[5], address[4], address[3], address[2])
+	t.Version = address[6]
+	t.DeviceType = address[7]
+}
+
+// readShortHeader reads access number, status and configuration field.
+func (t *Telegram) readShortHeader(header []byte) {
+	t.AccessNumber = header[0]
+	t.Status = header[1]
+	configuration := binary.LittleEndian.Uint16(header[2:4])
+	t.mode = int(configuration >> 8 & 0x1F)
+	t.encrypted = int(configuration >> 4 & 0x0F)
+}
+
+// newIV returns initialization vector of security mode 5, which is the meter
+// address followed by the access number repeated.
+func newIV(address []byte, accessNumber byte) []byte {
+	iv := make([]byte, 0, aes.BlockSize)
+	iv = append(iv, address...)
+	for len(iv) < aes.BlockSize {
+		iv = append(iv, accessNumber)
+	}
+	return iv
+}
+
+// Frame format A has CRC after the first block of 10 bytes and every next
+// block of 16 bytes.
+func frameSizeWithCRCs(length int) int {
+	rest := length + 1 - linkHeaderSize
+	if rest < 0 {
+		return -1
+	}
+	return linkHeaderSize + 2 + rest + 2*((rest+15)/16)
+}
+
+func removeCRCs(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	blockSize := linkHeaderSize
+	for len(data) > 0 {
+		size := min(blockSize, len(data)-2)
+		block, crc := data[:size], binary.BigEndian.Uint16(data[size:size+2])
+		if crc16(block) != crc {
+			return nil, errors.New("CRC does not match")
+		}
+		out = append(out, block...)
+		data = data[size+2:]
+		blockSize = 16
+	}
+	return out, nil
+}
+
+// crc16 computes CRC of EN 13757-4 with polynomial 0x3D65.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x3D65
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+// Sizes of data by data field of DIF, variable length is -1.
+var dataSizes = [16]int{0, 1, 2, 3, 4, 4, 6, 8, 0, 1, 2, 3, 4, -1, 6, 0}
+
+// parseRecords parses data records until the end or manufacturer specific
+// data.
+func parseRecords(data []byte) ([]Record, error) {
+	var records []Record
+	i := 0
+	next := func() (byte, error) {
+		if i >= len(data) {
+			return 0, errors.New("data record truncated")
+		}
+		b := data[i]
+		i++
+		return b, nil
+	}
+	for i < len(data) {
+		dif := data[i]
+		i++
+		if dif == 0x2F {
+			continue
+		}
+		if dif&0x0F == 0x0F {
+			break
+		}
+		record := Record{
+			Function:      int(dif >> 4 & 0x03),
+			StorageNumber: int(dif >> 6 & 0x01),
+		}
+		storageShift, tariffShift, subunitShift := 1, 0, 0
+		for extension := dif&0x80 != 0; extension; {
+			dife, err := next()
+			if err != nil {
+				return nil, err
+			}
+			record.StorageNumber |= int(dife&0x0F) << storageShift
+			record.Tariff |= int(dife>>4&0x03) << tariffShift
+			record.Subunit |= int(dife>>6&0x01) << subunitShift
+			storageShift, tariffShift, subunitShift = storageShift+4, tariffShift+2, subunitShift+1
+			extension = dife&0x80 != 0
+		}
+
+		vif, err := next()
+		if err != nil {
+			return nil, err
+		}
+		var table byte
+		switch vif & 0x7F {
+		case 0x7C:
+			// Plain text unit.
+			size, err := next()
+			if err != nil {
+				return nil, err
+			}
+			i += int(size)
+		case 0x7B, 0x7D:
+			if vif&0x80 == 0 {
+				return nil, fmt.Errorf("VIF %02X without extension", vif)
+			}
+			table = vif
+			if vif, err = next(); err != nil {
+				return nil, err
+			}
+		}
+		for extension := vif&0x80 != 0; extension; {
+			vife, err := next()
+			if err != nil {
+				return nil, err
+			}
+			record.Extended = true
+			extension = vife&0x80 != 0
+		}
+
+		size := dataSizes[dif&0x0F]
+		if size == -1 {
+			lvar, err := next()
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case lvar < 0xC0:
+				size = int(lvar)
+			case lvar < 0xE0:
+				size = int(lvar & 0x0F)
+			case lvar < 0xF0:
+				size = int(lvar - 0xE0)
+			default:
+				return nil, fmt.Errorf("unsupported variable length %02X", lvar)
+			}
+		}
+		if i+size > len(data) {
+			return nil, errors.New("data record truncated")
+		}
+		value, err := decodeValue(dif&0x0F, data[i:i+size])
+		i += size
+		if err != nil {
+			return nil, err
+		}
+		quantity, multiplier, ok := quantityOf(table, vif&0x7F)
+		if !ok {
+			continue
+		}
+		record.Quantity = quantity
+		record.Value = value * multiplier
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// quantityOf returns quantity of VIF and multiplier converting value to kWh
+// or m3.
+func quantityOf(table, vif byte) (Quantity, float64, bool) {
+	n := float64(vif & 0x07)
+	switch table {
+	case 0:
+		switch vif & 0x78 {
+		case 0x00:
+			// Wh
+			return QuantityEnergy, math.Pow(10, n-3) / 1000, true
+		case 0x08:
+			// J
+			return QuantityEnergy, math.Pow(10, n) / 3.6e6, true
+		case 0x10:
+			// m3
+			return QuantityVolume, math.Pow(10, n-6), true
+		}
+	case 0xFB:
+		n = float64(vif & 0x01)
+		switch vif & 0x7E {
+		case 0x00:
+			// MWh
+			return QuantityEnergy, math.Pow(10, n-1) * 1000, true
+		case 0x08:
+			// GJ
+			return QuantityEnergy, math.Pow(10, n-1) * 1e9 / 3.6e6, true
+		case 0x10:
+			// m3
+			return QuantityVolume, math.Pow(10, n+2), true
+		}
+	}
+	return "", 0, false
+}
+
+// decodeValue decodes little endian integer, real or BCD number. Values of
+// other data fields are zero.
+func decodeValue(dataField byte, data []byte) (float64, error) {
+	switch dataField {
+	case 0x01, 0x02, 0x03, 0x04, 0x06, 0x07:
+		var v uint64
+		for i := len(data) - 1; i >= 0; i-- {
+			v = v<<8 | uint64(data[i])
+		}
+		// Sign extension of two's complement.
+		bits := uint(len(data) * 8)
+		if bits < 64 && v&(1<<(bits-1)) != 0 {
+			v |= ^uint64(0) << bits
+		}
+		return float64(int64(v)), nil
+	case 0x05:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), nil
+	case 0x09, 0x0A, 0x0B, 0x0C, 0x0E:
+		return decodeBCD(data)
+	default:
+		return 0, nil
+	}
+}
+
+// decodeBCD decodes little endian BCD, negative if the highest digit is F.
+func decodeBCD(data []byte) (float64, error) {
+	var v float64
+	sign := 1.0
+	for i := len(data) - 1; i >= 0; i-- {
+		high, low := data[i]>>4, data[i]&0x0F
+		if i == len(data)-1 && high == 0x0F {
+			sign, high = -1, 0
+		}
+		if high > 9 || low > 9 {
+			return 0, fmt.Errorf("invalid BCD %X", data)
+		}
+		v = v*100 + float64(high)*10 + float64(low)
+	}
+	return sign * v, nil
+}
@@ -0,0 +1,125 @@
+package wmbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// OMS specification volume 2, annex N.2.1: water meter telegram with short
+// header, encrypted in security mode 5.
+const (
+	omsTelegram = "2E4493157856341233037A2A0020055923C95AAA26D1B2E7493B013EC4A6F6" +
+		"D3529B520EDFF0EA6DEFC99D6D69EBF3"
+	omsKey = "0102030405060708090A0B0C0D0E0F11"
+	omsIV  = "93157856341233032A2A2A2A2A2A2A2A"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// withCRCs adds CRCs of frame format A to telegram.
+func withCRCs(data []byte) []byte {
+	var out []byte
+	blockSize := linkHeaderSize
+	for len(data) > 0 {
+		size := min(blockSize, len(data))
+		out = append(out, data[:size]...)
+		out = binary.BigEndian.AppendUint16(out, crc16(data[:size]))
+		data = data[size:]
+		blockSize = 16
+	}
+	return out
+}
+
+func TestCRC16(t *testing.T) {
+	// Check value of CRC-16/EN-13757.
+	if got := crc16([]byte("123456789")); got != 0xC2B7 {
+		t.Errorf("crc16() = %04X, want C2B7", got)
+	}
+}
+
+func TestNewIV(t *testing.T) {
+	data := decodeHex(t, omsTelegram)
+	if got, want := newIV(data[2:10], 0x2A), decodeHex(t, omsIV); !bytes.Equal(got, want) {
+		t.Errorf("newIV() = %X, want %X", got, want)
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	data := decodeHex(t, omsTelegram)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"without CRCs", data},
+		{"with CRCs", withCRCs(data)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telegram, err := Parse(tt.data)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if telegram.Manufacturer != "ELS" || telegram.ID != "12345678" ||
+				telegram.Version != 0x33 || telegram.DeviceType != 0x03 ||
+				telegram.AccessNumber != 0x2A {
+
+				t.Errorf("Parse() = %+v", telegram)
+			}
+			if !telegram.Encrypted() {
+				t.Fatal("Encrypted() = false")
+			}
+			if err := telegram.Decrypt(decodeHex(t, omsKey)); err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			record, ok := telegram.Current(QuantityVolume)
+			if !ok {
+				t.Fatal("Current() found no volume")
+			}
+			if record.Value != 28504.27 {
+				t.Errorf("Current() = %v, want 28504.27", record.Value)
+			}
+		})
+	}
+}
+
+func TestDecryptError(t *testing.T) {
+	wrongKey := decodeHex(t, omsKey)
+	wrongKey[15] = 0x10
+	tests := []struct {
+		name string
+		key  []byte
+		err  error
+	}{
+		{"no key", nil, ErrNoKey},
+		{"wrong key", wrongKey, ErrWrongKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telegram, err := Parse(decodeHex(t, omsTelegram))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if err := telegram.Decrypt(tt.key); !errors.Is(err, tt.err) {
+				t.Errorf("Decrypt() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseCRCError(t *testing.T) {
+	data := withCRCs(decodeHex(t, omsTelegram))
+	data[20] ^= 0x01
+	if _, err := Parse(data); err == nil {
+		t.Error("Parse() accepted telegram with wrong CRC")
+	}
+}
@@ -0,0 +1,211 @@
+// Package xlsx streams single sheet Office Open XML spreadsheets. Rows are
+// written to the underlying writer as they come, so large tables do not have
+// to be kept in memory.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+const maxDecimals = 3
+
+// Cell styles are defined in styles.xml. Numbers with N decimals use style
+// numberStyle + N.
+const (
+	dateStyle   = 1
+	numberStyle = 2
+)
+
+type cellKind int
+
+const (
+	emptyCell cellKind = iota
+	stringCell
+	numberCell
+)
+
+// Cell is a single spreadsheet cell. The zero value is an empty cell.
+type Cell struct {
+	kind   cellKind
+	text   string
+	number float64
+	style  int
+}
+
+func String(s string) Cell { return Cell{kind: stringCell, text: s} }
+
+// Number returns numeric cell displayed with given number of decimals. Cell
+// values stay numeric, so spreadsheet applications format them in the locale
+// of the reader.
+func Number(v float64, decimals int) Cell {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return Cell{}
+	}
+	decimals = max(0, min(decimals, maxDecimals))
+	return Cell{kind: numberCell, number: v, style: numberStyle + decimals}
+}
+
+// Spreadsheet dates are days since 1899-12-30.
+var epoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+func Date(t time.Time) Cell {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return Cell{
+		kind:   numberCell,
+		number: math.Round(date.Sub(epoch).Hours() / 24),
+		style:  dateStyle,
+	}
+}
+
+type Writer struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+	err   error
+}
+
+// NewWriter starts a spreadsheet with one sheet of given name. Close must be
+// called to finish the file.
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", relsXML},
+		{"xl/workbook.xml", fmt.Sprintf(workbookXML, escape(sheetName))},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML},
+		{"xl/styles.xml", stylesXML},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, fmt.Errorf("could not create %s: %w", part.name, err)
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, fmt.Errorf("could not write %s: %w", part.name, err)
+		}
+	}
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("could not create sheet: %w", err)
+	}
+	if _, err := io.WriteString(sheet, sheetHeaderXML); err != nil {
+		return nil, fmt.Errorf("could not write sheet: %w", err)
+	}
+	return &Writer{zw: zw, sheet: sheet}, nil
+}
+
+func (w *Writer) WriteRow(cells ...Cell) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.row++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, w.row)
+	for i, cell := range cells {
+		ref := columnName(i) + strconv.Itoa(w.row)
+		switch cell.kind {
+		case stringCell:
+			fmt.Fprintf(&b,
+				`<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`,
+				ref, escape(cell.text))
+		case numberCell:
+			fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`,
+				ref, cell.style, strconv.FormatFloat(cell.number, 'g', -1, 64))
+		}
+	}
+	b.WriteString("</row>")
+	if _, err := io.WriteString(w.sheet, b.String()); err != nil {
+		w.err = fmt.Errorf("could not write row: %w", err)
+	}
+	return w.err
+}
+
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if _, err := io.WriteString(w.sheet, sheetFooterXML); err != nil {
+		return fmt.Errorf("could not write sheet: %w", err)
+	}
+	if err := w.zw.Close(); err != nil {
+		return fmt.Errorf("could not close spreadsheet: %w", err)
+	}
+	w.err = errors.New("writer is closed")
+	return nil
+}
+
+// columnName returns spreadsheet column name for zero based index, e.g. A, Z,
+// AA.
+func columnName(i int) string {
+	var name []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = append([]byte{byte('A' + (i-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	// Writing to strings.Builder never fails.
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const contentTypesXML = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const relsXML = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const workbookXML = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const workbookRelsXML = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// Built-in number format 14 is a short date in the locale of the reader.
+const stylesXML = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="4">` +
+	`<numFmt numFmtId="164" formatCode="0"/>` +
+	`<numFmt numFmtId="165" formatCode="0.0"/>` +
+	`<numFmt numFmtId="166" formatCode="0.00"/>` +
+	`<numFmt numFmtId="167" formatCode="0.000"/>` +
+	`</numFmts>` +
+	`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="6">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="14" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="166" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="167" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+const sheetHeaderXML = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const sheetFooterXML = `</sheetData></worksheet>`
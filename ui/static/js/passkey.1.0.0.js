@@ -0,0 +1,112 @@
+// Passkey registration and login with WebAuthn. Options and responses are
+// exchanged with the server as JSON with binary values in base64url.
+"use strict";
+
+function base64urlToBuffer(s) {
+	const base64 = s.replace(/-/g, "+").replace(/_/g, "/");
+	const binary = atob(base64.padEnd(Math.ceil(base64.length / 4) * 4, "="));
+	return Uint8Array.from(binary, c => c.charCodeAt(0)).buffer;
+}
+
+function bufferToBase64url(buffer) {
+	const binary = String.fromCharCode(...new Uint8Array(buffer));
+	return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+async function postJSON(url, body) {
+	const response = await fetch(url, {
+		method: "POST",
+		headers: {"Content-Type": "application/json"},
+		body: JSON.stringify(body ?? {}),
+	});
+	if (response.status === 204) {
+		return null;
+	}
+	let data;
+	try {
+		data = await response.json();
+	} catch {
+		throw new Error("Unexpected response.");
+	}
+	if (!response.ok) {
+		throw new Error(data.error);
+	}
+	return data;
+}
+
+function checkSupport() {
+	if (!window.PublicKeyCredential) {
+		throw new Error("Browser does not support passkeys.");
+	}
+}
+
+async function registerPasskey(name) {
+	checkSupport();
+	const {publicKey} = await postJSON("/passkey/register/begin");
+	publicKey.challenge = base64urlToBuffer(publicKey.challenge);
+	publicKey.user.id = base64urlToBuffer(publicKey.user.id);
+	for (const credential of publicKey.excludeCredentials ?? []) {
+		credential.id = base64urlToBuffer(credential.id);
+	}
+	const credential = await navigator.credentials.create({publicKey});
+	await postJSON("/passkey/register/finish?" + new URLSearchParams({name}), {
+		id: credential.id,
+		rawId: bufferToBase64url(credential.rawId),
+		type: credential.type,
+		response: {
+			clientDataJSON: bufferToBase64url(credential.response.clientDataJSON),
+			attestationObject: bufferToBase64url(credential.response.attestationObject),
+			transports: credential.response.getTransports?.() ?? [],
+		},
+	});
+}
+
+async function logInWithPasskey() {
+	checkSupport();
+	const {publicKey} = await postJSON("/login/passkey/begin");
+	publicKey.challenge = base64urlToBuffer(publicKey.challenge);
+	const credential = await navigator.credentials.get({publicKey});
+	const {redirect} = await postJSON("/login/passkey/finish" + window.location.search, {
+		id: credential.id,
+		rawId: bufferToBase64url(credential.rawId),
+		type: credential.type,
+		response: {
+			clientDataJSON: bufferToBase64url(credential.response.clientDataJSON),
+			authenticatorData: bufferToBase64url(credential.response.authenticatorData),
+			signature: bufferToBase64url(credential.response.signature),
+			userHandle: credential.response.userHandle &&
+				bufferToBase64url(credential.response.userHandle),
+		},
+	});
+	window.location.assign(redirect);
+}
+
+function handleErrors(element, f) {
+	const error = document.getElementById("passkey-error");
+	element.addEventListener(element.tagName === "FORM" ? "submit" : "click", async event => {
+		event.preventDefault();
+		error.textContent = "";
+		try {
+			await f();
+		} catch (err) {
+			// Cancelled browser dialog is not an error to show.
+			if (err.name !== "NotAllowedError") {
+				error.textContent = "Error: " + err.message;
+			}
+		}
+	});
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+	const registerForm = document.getElementById("passkey-register");
+	if (registerForm) {
+		handleErrors(registerForm, async () => {
+			await registerPasskey(registerForm.elements.name.value);
+			window.location.reload();
+		});
+	}
+	const logInButton = document.getElementById("passkey-login");
+	if (logInButton) {
+		handleErrors(logInButton, logInWithPasskey);
+	}
+});